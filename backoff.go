@@ -0,0 +1,50 @@
+package igshelf
+
+import (
+	"math/rand"
+	"time"
+)
+
+// Backoff computes exponential retry delays with jitter, so the API client
+// and downloader retries share one tested implementation instead of
+// diverging over time.
+type Backoff struct {
+	// Base is the delay before the first retry (attempt 1).
+	Base time.Duration
+	// Max caps the computed delay, however high attempt climbs.
+	Max time.Duration
+	// Jitter is the maximum random deviation applied to the delay, as a
+	// fraction of it (e.g. 0.5 means +/-50%). Zero disables jitter.
+	Jitter float64
+}
+
+// Next returns the delay to wait before retry attempt (1-based): Base
+// doubled for every attempt past the first, capped at Max, with up to
+// +/-Jitter fraction of random deviation so many clients retrying at once
+// don't all wake up in lockstep.
+func (b Backoff) Next(attempt int) time.Duration {
+	if attempt < 1 {
+		attempt = 1
+	}
+
+	d := b.Base
+	for i := 1; i < attempt; i++ {
+		d *= 2
+		if b.Max > 0 && d > b.Max {
+			d = b.Max
+			break
+		}
+	}
+	if b.Max > 0 && d > b.Max {
+		d = b.Max
+	}
+
+	if b.Jitter > 0 {
+		delta := float64(d) * b.Jitter
+		d = time.Duration(float64(d) - delta + rand.Float64()*2*delta)
+		if d < 0 {
+			d = 0
+		}
+	}
+	return d
+}
@@ -0,0 +1,50 @@
+package igshelf
+
+import (
+	"testing"
+	"time"
+)
+
+func TestBackoffGrowsMonotonically(t *testing.T) {
+	b := Backoff{Base: 100 * time.Millisecond, Max: 10 * time.Second}
+
+	prev := time.Duration(0)
+	for attempt := 1; attempt <= 5; attempt++ {
+		d := b.Next(attempt)
+		if d < prev {
+			t.Fatalf("attempt %d: got %v, want at least %v", attempt, d, prev)
+		}
+		prev = d
+	}
+}
+
+func TestBackoffCapsAtMax(t *testing.T) {
+	b := Backoff{Base: time.Second, Max: 5 * time.Second}
+
+	if got, want := b.Next(20), 5*time.Second; got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestBackoffJitterStaysWithinBounds(t *testing.T) {
+	b := Backoff{Base: time.Second, Max: time.Minute, Jitter: 0.5}
+
+	base := Backoff{Base: b.Base, Max: b.Max}.Next(3)
+	min := time.Duration(float64(base) * 0.5)
+	max := time.Duration(float64(base) * 1.5)
+
+	for i := 0; i < 100; i++ {
+		d := b.Next(3)
+		if d < min || d > max {
+			t.Fatalf("got %v, want within [%v, %v]", d, min, max)
+		}
+	}
+}
+
+func TestBackoffMinimumAttempt(t *testing.T) {
+	b := Backoff{Base: time.Second, Max: time.Minute}
+
+	if got, want := b.Next(0), b.Next(1); got != want {
+		t.Errorf("got %v, want %v (attempt below 1 clamps to 1)", got, want)
+	}
+}
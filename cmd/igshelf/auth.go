@@ -0,0 +1,59 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"github.com/marselester/igshelf/internal/instagram"
+)
+
+// defaultAuthScopes are the permissions requested when -scopes is unset,
+// enough to list and download a user's own timeline.
+const defaultAuthScopes = "user_profile,user_media"
+
+// runAuth walks a user through Instagram's Basic Display authorization
+// code flow without them having to know the endpoints involved: run once
+// with -client-id (and -redirect-uri) to get a URL to open in a browser,
+// then again with -code set to the value Instagram redirects back with to
+// exchange it for an access token to pass to the download command's
+// -token flag.
+func runAuth(logger *slog.Logger, args []string) int {
+	fs := flag.NewFlagSet("igshelf auth", flag.ExitOnError)
+	var (
+		clientID     = fs.String("client-id", "", "Instagram app's client ID")
+		clientSecret = fs.String("client-secret", "", "Instagram app's client secret, required with -code")
+		redirectURI  = fs.String("redirect-uri", "", "redirect URI configured for the Instagram app")
+		scopes       = fs.String("scopes", defaultAuthScopes, "comma-separated permissions to request")
+		code         = fs.String("code", "", "authorization code from the redirect URI's \"code\" query param; exchanges it for an access token instead of printing an authorization URL")
+	)
+	if err := fs.Parse(args); err != nil {
+		logger.Error("failed to parse flags", "err", err)
+		return 1
+	}
+	if *clientID == "" || *redirectURI == "" {
+		logger.Error("-client-id and -redirect-uri are required")
+		return 1
+	}
+
+	if *code == "" {
+		authURL := instagram.AuthCodeURL(*clientID, *redirectURI, strings.Split(*scopes, ","))
+		fmt.Println("Open this URL, authorize the app, then re-run with -code set to the \"code\" query param it redirects back with:")
+		fmt.Println(authURL)
+		return 0
+	}
+
+	if *clientSecret == "" {
+		logger.Error("-client-secret is required with -code")
+		return 1
+	}
+	tok, err := instagram.ExchangeCode(context.Background(), *clientID, *clientSecret, *redirectURI, *code)
+	if err != nil {
+		logger.Error("failed to exchange the authorization code", "err", err)
+		return 1
+	}
+	fmt.Printf("Access token for user %d: %s\n", tok.UserID, tok.AccessToken)
+	return 0
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+	"testing"
+)
+
+// captureStdout redirects os.Stdout for the duration of fn and returns
+// whatever was written to it, since runAuth prints results a user is meant
+// to copy rather than logging them.
+func captureStdout(t *testing.T, fn func()) string {
+	t.Helper()
+
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	orig := os.Stdout
+	os.Stdout = w
+	defer func() { os.Stdout = orig }()
+
+	fn()
+
+	w.Close()
+	var buf bytes.Buffer
+	io.Copy(&buf, r)
+	return buf.String()
+}
+
+// TestRunAuthRequiresClientIDAndRedirectURI checks that runAuth fails
+// fast, without attempting a request, when the flags needed to build an
+// authorization URL are missing.
+func TestRunAuthRequiresClientIDAndRedirectURI(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	if got := runAuth(logger, []string{}); got != 1 {
+		t.Errorf("got exit code %d, want 1", got)
+	}
+}
+
+// TestRunAuthPrintsAuthorizationURL checks that omitting -code prints an
+// authorization URL to open in a browser, rather than attempting to
+// exchange a code.
+func TestRunAuthPrintsAuthorizationURL(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	var exitCode int
+	out := captureStdout(t, func() {
+		exitCode = runAuth(logger, []string{
+			"-client-id", "client-id",
+			"-redirect-uri", "https://example.com/callback",
+		})
+	})
+
+	if exitCode != 0 {
+		t.Errorf("got exit code %d, want 0", exitCode)
+	}
+	if !strings.Contains(out, "https://api.instagram.com/oauth/authorize?") {
+		t.Errorf("got %q, want it to contain an authorization URL", out)
+	}
+}
+
+// TestRunAuthRequiresClientSecretWithCode checks that -code without
+// -client-secret fails fast instead of attempting an exchange doomed to
+// be rejected by the token endpoint.
+func TestRunAuthRequiresClientSecretWithCode(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	got := runAuth(logger, []string{
+		"-client-id", "client-id",
+		"-redirect-uri", "https://example.com/callback",
+		"-code", "abc123",
+	})
+	if got != 1 {
+		t.Errorf("got exit code %d, want 1", got)
+	}
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"flag"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/peterbourgon/ff/v3"
+)
+
+func TestConfigFilename(t *testing.T) {
+	tt := map[string]struct {
+		args []string
+		want string
+	}{
+		"absent":            {args: []string{"-dst", "/tmp/gallery"}, want: ""},
+		"space separated":   {args: []string{"-config", "cfg.yaml"}, want: "cfg.yaml"},
+		"equals":            {args: []string{"-config=cfg.yaml"}, want: "cfg.yaml"},
+		"double dash":       {args: []string{"--config", "cfg.yaml"}, want: "cfg.yaml"},
+		"double dash equal": {args: []string{"--config=cfg.yaml"}, want: "cfg.yaml"},
+	}
+
+	for name, tc := range tt {
+		t.Run(name, func(t *testing.T) {
+			if got := configFilename(tc.args); got != tc.want {
+				t.Errorf("got %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestConfigFileParser(t *testing.T) {
+	tt := map[string]struct {
+		args []string
+		want string
+	}{
+		"plain by default":  {args: nil, want: "plain"},
+		"yaml":              {args: []string{"-config", "cfg.yaml"}, want: "yaml"},
+		"yml":               {args: []string{"-config", "cfg.yml"}, want: "yaml"},
+		"json":              {args: []string{"-config", "cfg.json"}, want: "json"},
+		"unknown extension": {args: []string{"-config", "cfg.conf"}, want: "plain"},
+	}
+
+	for name, tc := range tt {
+		t.Run(name, func(t *testing.T) {
+			p := configFileParser(tc.args)
+			var got string
+			switch {
+			case isSameParser(p, ff.PlainParser):
+				got = "plain"
+			case isSameParser(p, ff.JSONParser):
+				got = "json"
+			default:
+				got = "yaml"
+			}
+			if got != tc.want {
+				t.Errorf("got %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+// isSameParser compares two ff.ConfigFileParser funcs by pointer, since
+// they can't be compared directly.
+func isSameParser(a, b ff.ConfigFileParser) bool {
+	return reflect.ValueOf(a).Pointer() == reflect.ValueOf(b).Pointer()
+}
+
+func TestConfigFileYAMLPopulatesFlags(t *testing.T) {
+	fs := flag.NewFlagSet("igshelf", flag.ContinueOnError)
+	destination := fs.String("dst", "", "path to a directory where timeline is stored")
+	workerCount := fs.Int("worker", 10, "number of workers that copy media files")
+	user := fs.String("user", "me", "user whose timeline should be downloaded")
+	_ = fs.String("config", "", "config file")
+
+	filename := filepath.Join("testdata", "config.yaml")
+	args := []string{"-config", filename}
+	if err := ff.Parse(fs, args,
+		ff.WithConfigFileFlag("config"),
+		ff.WithConfigFileParser(configFileParser(args)),
+	); err != nil {
+		t.Fatal(err)
+	}
+
+	if got, want := *destination, "/tmp/gallery"; got != want {
+		t.Errorf("dst: got %q, want %q", got, want)
+	}
+	if got, want := *workerCount, 5; got != want {
+		t.Errorf("worker: got %d, want %d", got, want)
+	}
+	if got, want := *user, "someone"; got != want {
+		t.Errorf("user: got %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,25 @@
+package main
+
+import (
+	"os"
+	"testing"
+)
+
+func TestParseFileModes(t *testing.T) {
+	perm, err := parseFileModes("0644", "0755")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := perm.file, os.FileMode(0644); got != want {
+		t.Errorf("got file mode %v, want %v", got, want)
+	}
+	if got, want := perm.dir, os.FileMode(0755); got != want {
+		t.Errorf("got dir mode %v, want %v", got, want)
+	}
+}
+
+func TestParseFileModes_invalid(t *testing.T) {
+	if _, err := parseFileModes("not-octal", "0755"); err == nil {
+		t.Error("expected an error for an invalid -filemode value")
+	}
+}
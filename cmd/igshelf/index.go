@@ -0,0 +1,147 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"html/template"
+	"io/ioutil"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/marselester/igshelf/internal/jsonfile"
+)
+
+// gallery describes one subdirectory's timeline for the index page.
+type gallery struct {
+	// Title is the gallery's display name, taken from its profile.json,
+	// falling back to the directory name when there isn't one.
+	Title string
+	// Path is the gallery's directory name, relative to -root.
+	Path string
+	// Count is the number of top-level posts in the gallery.
+	Count int
+	// From and To are the earliest and latest TakenAt of the gallery's posts.
+	From, To time.Time
+}
+
+// profile is the subset of a gallery's profile.json this command reads.
+type profile struct {
+	Name string `json:"name"`
+}
+
+// runIndex scans -root for subdirectories containing a timeline.json, and
+// renders an index.html in -root linking to each one along with its post
+// count and date range, e.g. for a user who ran igshelf once per account
+// into separate directories and wants a single landing page.
+func runIndex(logger *slog.Logger, args []string) int {
+	fs := flag.NewFlagSet("igshelf index", flag.ExitOnError)
+	var (
+		root        = fs.String("root", "", "path to a directory containing per-account gallery subdirectories")
+		templateArg = fs.String("template", "", "path to a custom index template file, defaults to the embedded template")
+	)
+	if err := fs.Parse(args); err != nil {
+		logger.Error("failed to parse flags", "err", err)
+		return 1
+	}
+	if *root == "" {
+		logger.Error("-root is required")
+		return 1
+	}
+
+	galleries, err := scanGalleries(*root)
+	if err != nil {
+		logger.Error("failed to scan galleries", "root", *root, "err", err)
+		return 1
+	}
+
+	t, err := loadIndexTemplate(*templateArg)
+	if err != nil {
+		logger.Error("failed to parse the index template", "path", *templateArg, "err", err)
+		return 1
+	}
+
+	data := struct {
+		Galleries []gallery
+	}{galleries}
+	buf := bytes.Buffer{}
+	if err = t.Execute(&buf, data); err != nil {
+		logger.Error("failed to render the index", "err", err)
+		return 1
+	}
+	if err = ioutil.WriteFile(filepath.Join(*root, "index.html"), buf.Bytes(), 0600); err != nil {
+		logger.Error("failed to write index.html on disk", "err", err)
+		return 1
+	}
+	return 0
+}
+
+// scanGalleries reports one gallery per immediate subdirectory of root that
+// contains a timeline.json, in the order os.ReadDir lists them.
+func scanGalleries(root string) ([]gallery, error) {
+	entries, err := ioutil.ReadDir(root)
+	if err != nil {
+		return nil, err
+	}
+
+	var galleries []gallery
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		dir := filepath.Join(root, entry.Name())
+
+		db := jsonfile.NewMediaRepository(filepath.Join(dir, "timeline.json"))
+		timeline, err := db.List()
+		if err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				continue
+			}
+			return nil, err
+		}
+
+		g := gallery{
+			Title: entry.Name(),
+			Path:  entry.Name(),
+			Count: len(timeline),
+		}
+		if p, err := readProfile(filepath.Join(dir, "profile.json")); err == nil && p.Name != "" {
+			g.Title = p.Name
+		}
+		for _, m := range timeline {
+			if g.From.IsZero() || m.TakenAt.Before(g.From) {
+				g.From = m.TakenAt
+			}
+			if m.TakenAt.After(g.To) {
+				g.To = m.TakenAt
+			}
+		}
+		galleries = append(galleries, g)
+	}
+	return galleries, nil
+}
+
+// readProfile reads a gallery's optional profile.json, e.g. exported
+// alongside an Instagram archive. Its absence isn't an error, since a
+// gallery still gets listed under its directory name without one.
+func readProfile(filename string) (profile, error) {
+	var p profile
+	b, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return p, err
+	}
+	err = json.Unmarshal(b, &p)
+	return p, err
+}
+
+// loadIndexTemplate parses the index template at path, or the embedded
+// default template when path is empty.
+func loadIndexTemplate(path string) (*template.Template, error) {
+	if path == "" {
+		return template.New(filepath.Base(defaultIndexTemplateName)).Funcs(templateFuncs).ParseFS(embeddedTemplateFS, defaultIndexTemplateName)
+	}
+	return template.New(filepath.Base(path)).Funcs(templateFuncs).ParseFiles(path)
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/marselester/igshelf"
+	"github.com/marselester/igshelf/internal/jsonfile"
+)
+
+func TestScanGalleries(t *testing.T) {
+	root := t.TempDir()
+
+	writeGallery(t, root, "alice", nil, []*igshelf.Media{
+		{ID: "1", TakenAt: time.Date(2020, time.January, 1, 0, 0, 0, 0, time.UTC)},
+		{ID: "2", TakenAt: time.Date(2020, time.June, 1, 0, 0, 0, 0, time.UTC)},
+	})
+	writeGallery(t, root, "bob", &profile{Name: "Bob's trip"}, []*igshelf.Media{
+		{ID: "3", TakenAt: time.Date(2019, time.March, 5, 0, 0, 0, 0, time.UTC)},
+	})
+	// A subdirectory without a timeline.json shouldn't be listed.
+	if err := os.Mkdir(filepath.Join(root, "empty"), 0700); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := scanGalleries(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []gallery{
+		{
+			Title: "alice",
+			Path:  "alice",
+			Count: 2,
+			From:  time.Date(2020, time.January, 1, 0, 0, 0, 0, time.UTC),
+			To:    time.Date(2020, time.June, 1, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			Title: "Bob's trip",
+			Path:  "bob",
+			Count: 1,
+			From:  time.Date(2019, time.March, 5, 0, 0, 0, 0, time.UTC),
+			To:    time.Date(2019, time.March, 5, 0, 0, 0, 0, time.UTC),
+		},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf(diff)
+	}
+}
+
+func TestRunIndexLinksEachGallery(t *testing.T) {
+	root := t.TempDir()
+	writeGallery(t, root, "alice", nil, []*igshelf.Media{
+		{ID: "1", TakenAt: time.Date(2020, time.January, 1, 0, 0, 0, 0, time.UTC)},
+	})
+	writeGallery(t, root, "bob", nil, []*igshelf.Media{
+		{ID: "2", TakenAt: time.Date(2019, time.March, 5, 0, 0, 0, 0, time.UTC)},
+	})
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	if code := runIndex(logger, []string{"-root", root}); code != 0 {
+		t.Fatalf("got exit code %d, want 0", code)
+	}
+
+	b, err := ioutil.ReadFile(filepath.Join(root, "index.html"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	body := string(b)
+	for _, href := range []string{"alice/timeline.html", "bob/timeline.html"} {
+		if !strings.Contains(body, href) {
+			t.Errorf("got %q, want it to link to %q", body, href)
+		}
+	}
+}
+
+// writeGallery creates a gallery subdirectory of root with a timeline.json
+// (and an optional profile.json) built from the given timeline.
+func writeGallery(t *testing.T, root, name string, p *profile, timeline []*igshelf.Media) {
+	t.Helper()
+
+	dir := filepath.Join(root, name)
+	if err := os.Mkdir(dir, 0700); err != nil {
+		t.Fatal(err)
+	}
+
+	db := jsonfile.NewMediaRepository(filepath.Join(dir, "timeline.json"))
+	if err := db.Store(timeline); err != nil {
+		t.Fatal(err)
+	}
+
+	if p != nil {
+		b, err := json.Marshal(p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(filepath.Join(dir, "profile.json"), b, 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,32 @@
+package main
+
+import (
+	"log/slog"
+	"testing"
+)
+
+func TestParseLogLevel(t *testing.T) {
+	tt := map[string]slog.Level{
+		"debug": slog.LevelDebug,
+		"info":  slog.LevelInfo,
+		"error": slog.LevelError,
+	}
+
+	for name, want := range tt {
+		t.Run(name, func(t *testing.T) {
+			got, err := parseLogLevel(name)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != want {
+				t.Errorf("got level %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+func TestParseLogLevel_invalid(t *testing.T) {
+	if _, err := parseLogLevel("verbose"); err == nil {
+		t.Error("expected an error for an invalid -log-level value")
+	}
+}
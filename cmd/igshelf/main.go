@@ -8,17 +8,22 @@ package main
 import (
 	"bytes"
 	"context"
+	"errors"
 	"flag"
-	"html/template"
+	"fmt"
+	"io"
 	"io/ioutil"
+	"log/slog"
 	"os"
 	"os/signal"
 	"path/filepath"
+	"strconv"
 	"strings"
 	"syscall"
+	"time"
 
-	"github.com/go-kit/kit/log"
 	"github.com/peterbourgon/ff/v3"
+	"github.com/peterbourgon/ff/v3/ffyaml"
 
 	"github.com/marselester/igshelf"
 	"github.com/marselester/igshelf/internal/archive"
@@ -28,34 +33,94 @@ import (
 )
 
 func main() {
-	var logger log.Logger
-	{
-		logger = log.NewJSONLogger(log.NewSyncWriter(os.Stderr))
-		logger = log.With(logger, "ts", log.DefaultTimestampUTC)
-		logger = log.With(logger, "caller", log.DefaultCaller)
-	}
+	logger := slog.New(newJSONHandler(os.Stderr, slog.LevelInfo))
 
 	// By default an exit code is set to indicate a failure
 	// since there are more failure scenarios to begin with.
 	exitCode := 1
 	defer func() { os.Exit(exitCode) }()
 
+	if len(os.Args) > 1 && os.Args[1] == "serve" {
+		exitCode = runServe(logger, os.Args[2:])
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "index" {
+		exitCode = runIndex(logger, os.Args[2:])
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		exitCode = runMigrate(logger, os.Args[2:])
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "repair" {
+		exitCode = runRepair(logger, os.Args[2:])
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "auth" {
+		exitCode = runAuth(logger, os.Args[2:])
+		return
+	}
+
 	fs := flag.NewFlagSet("igshelf", flag.ExitOnError)
+	var sources sourceList
+	fs.Var(&sources, "src", `source(s) of the Instagram timeline: "api", or one or more paths to zip archives to merge, comma-separated or given as repeated -src flags`)
 	var (
-		source      = fs.String("src", "", `source of the Instagram timeline ("api" or path to a zip archive)`)
 		destination = fs.String("dst", "", "path to a directory where timeline is stored")
-		workerCount = fs.Int("worker", 10, "number of workers that copy media files")
+		contentDir  = fs.String("content-dir", "content", "name (or absolute path) of the directory media files are stored in, e.g. to merge into an existing media library")
+		workerCount = fs.Int("worker", 10, "number of workers that copy media files, 0 picks a count automatically")
 		token       = fs.String("token", "", "Instagram API access token")
+		tokenFile   = fs.String("token-file", "", "path to a file containing Instagram API access token")
 		user        = fs.String("user", "me", "user whose timeline should be downloaded")
+		fileMode    = fs.String("filemode", "0600", "octal file permissions for written media and timeline files")
+		dirMode     = fs.String("dirmode", "0700", "octal directory permissions for created directories")
+		templateArg = fs.String("template", "", "path to a custom gallery template file, defaults to the embedded template")
+		notifyURL   = fs.String("notify-url", "", "URL to POST a JSON run summary to once downloading finishes")
+		types       = fs.String("types", "", `comma-separated media types to download, e.g. "image,video"; all types if empty`)
+		ids         = fs.String("ids", "", "comma-separated media IDs to fetch instead of the whole timeline, e.g. to recover files missing from a prior run")
+		idsFile     = fs.String("ids-file", "", "path to a file with one media ID per line, instead of the whole timeline")
+		stories     = fs.Bool("stories", false, "also download and render an Instagram archive's stories, appended after the main timeline")
+		logLevel    = fs.String("log-level", "info", "log verbosity: debug, info, or error")
+		quiet       = fs.Bool("quiet", false, "only log errors, equivalent to -log-level=error")
 		_           = fs.String("config", "", "config file")
 	)
 	err := ff.Parse(fs, os.Args[1:],
 		ff.WithEnvVarPrefix("IGSHELF"),
 		ff.WithConfigFileFlag("config"),
-		ff.WithConfigFileParser(ff.PlainParser),
+		ff.WithConfigFileParser(configFileParser(os.Args[1:])),
 	)
 	if err != nil {
-		logger.Log("msg", "failed to parse flags", "err", err)
+		logger.Error("failed to parse flags", "err", err)
+		return
+	}
+
+	level, err := parseLogLevel(*logLevel)
+	if err != nil {
+		logger.Error("invalid log level", "err", err)
+		return
+	}
+	if *quiet {
+		level = slog.LevelError
+	}
+	logger = slog.New(newJSONHandler(os.Stderr, level))
+
+	// -token (and the IGSHELF_TOKEN env var it can come from) takes precedence
+	// over -token-file, so an explicit token always wins over a file on disk.
+	accessToken := *token
+	if accessToken == "" && *tokenFile != "" {
+		if accessToken, err = readTokenFile(*tokenFile); err != nil {
+			logger.Error("failed to read the token file", "err", err)
+			return
+		}
+	}
+
+	if err = validateConfig(sources, *destination, accessToken); err != nil {
+		logger.Error("invalid configuration", "err", err)
+		return
+	}
+
+	perm, err := parseFileModes(*fileMode, *dirMode)
+	if err != nil {
+		logger.Error("invalid file permissions", "err", err)
 		return
 	}
 
@@ -63,92 +128,462 @@ func main() {
 	defer cancel()
 	sig := make(chan os.Signal, 1)
 	signal.Notify(sig, syscall.SIGINT, syscall.SIGTERM)
+	// stopQueuing is closed on the first SIGINT, so in-flight downloads can
+	// finish instead of being corrupted mid-write; a second SIGINT (or any
+	// SIGTERM) cancels ctx to abort immediately.
+	stopQueuing := make(chan struct{})
 	go func() {
-		select {
-		case <-sig:
-			cancel()
-		case <-ctx.Done():
-			return
+		for {
+			select {
+			case got := <-sig:
+				if got == syscall.SIGTERM {
+					cancel()
+					return
+				}
+				select {
+				case <-stopQueuing:
+					cancel()
+					return
+				default:
+					logger.Info("stopping new downloads, interrupt again to cancel immediately")
+					close(stopQueuing)
+				}
+			case <-ctx.Done():
+				return
+			}
 		}
 	}()
 
+	if len(sources) == 1 && sources[0] == "api" {
+		if err = checkTokenScopes(ctx, accessToken); err != nil {
+			logger.Error("access token failed validation", "err", err)
+			return
+		}
+	}
+
+	contentDirPath, contentLink, err := resolveContentDir(*destination, *contentDir)
+	if err != nil {
+		logger.Error("failed to resolve -content-dir", "err", err)
+		return
+	}
+
 	var (
 		timelineJSONpath = filepath.Join(*destination, "timeline.json")
 		timelineHTMLpath = filepath.Join(*destination, "timeline.html")
-		contentDirPath   = filepath.Join(*destination, "content")
-		templatePath     = filepath.Join("template", "timeline.tpl")
 	)
 	// Create a directory to store media files.
 	_, err = os.Stat(contentDirPath)
 	if os.IsNotExist(err) {
-		if err = os.MkdirAll(contentDirPath, 0700); err != nil {
-			logger.Log("msg", "failed to create content dir", "path", contentDirPath, "err", err)
+		if err = os.MkdirAll(contentDirPath, perm.dir); err != nil {
+			logger.Error("failed to create content dir", "path", contentDirPath, "err", err)
 			return
 		}
 	}
 
-	db := jsonfile.NewMediaRepository(timelineJSONpath)
+	db := jsonfile.NewMediaRepository(timelineJSONpath, jsonfile.WithFileMode(perm.file))
 
 	// Prepare a media service in case a user decides to download the timeline
-	// from API or a zip archive.
-	var ig igshelf.MediaService
-	switch {
-	case *source == "api":
-		ig = instagram.NewService(
-			instagram.NewClient(*token),
-			*user,
-		)
-	case strings.HasSuffix(*source, ".zip"):
-		arch, err := archive.NewService(*source)
-		if err != nil {
-			logger.Log("msg", "failed to open Instagram archive", "err", err)
-			return
+	// from API or one or more zip archives.
+	ig, closeIG, err := newMediaService(sources, accessToken, *user, logger, *stories)
+	if err != nil {
+		logger.Error("failed to prepare a media service", "err", err)
+		return
+	}
+	if closeIG != nil {
+		defer closeIG.Close()
+	}
+	if *stories {
+		if sl, ok := ig.(storyLister); ok {
+			storyMedia, err := sl.Stories(ctx)
+			if err != nil {
+				logger.Warn("failed to read archive stories", "err", err)
+			} else if len(storyMedia) > 0 {
+				logger.Info("including archive stories", "count", len(storyMedia))
+				ig = &storyTimeline{MediaService: ig, stories: storyMedia}
+			}
 		}
-		defer arch.Close()
-		ig = arch
 	}
 
 	var timeline []*igshelf.Media
 	// Fetch user's timeline and store timeline.json in the destination directory
 	// along with downloaded media files (photos, videos).
 	if ig != nil {
-		d := downloader.NewService(ig, db,
+		if se, ok := ig.(sizeEstimator); ok {
+			if n, err := se.EstimatedSize(ctx); err != nil {
+				logger.Warn("failed to estimate download size", "err", err)
+			} else if n >= 0 {
+				logger.Info(fmt.Sprintf("about %s to download", formatBytes(n)))
+			}
+		}
+
+		opts := []downloader.ConfigOption{
 			downloader.WithMaxWorkers(*workerCount),
-			downloader.WithLogger(logger),
-		)
-		err = d.Download(ctx, contentDirPath)
+			downloader.WithSlog(logger),
+			downloader.WithFileMode(perm.file),
+			downloader.WithStopQueuing(stopQueuing),
+			downloader.WithDestination(*destination),
+		}
+		if tt := parseTypes(*types); len(tt) > 0 {
+			opts = append(opts, downloader.WithTypes(tt...))
+		}
+		d := downloader.NewService(ig, db, opts...)
+
+		targetIDs, err := loadIDs(*ids, *idsFile)
+		if err != nil {
+			logger.Error("failed to read -ids-file", "err", err)
+			return
+		}
+
+		start := time.Now()
+		var report downloader.Report
+		if len(targetIDs) > 0 {
+			report, err = d.DownloadIDs(ctx, contentDirPath, targetIDs)
+		} else {
+			report, err = d.Download(ctx, contentDirPath)
+		}
+		duration := time.Since(start)
 		if err != nil {
-			logger.Log("msg", "failed to download the timeline", "err", err)
+			logger.Error("failed to download the timeline", "err", err)
 			return
 		}
+		if !report.Complete {
+			logger.Warn("download was interrupted, rendering a partial gallery", "downloaded", report.Downloaded)
+		}
+		if len(report.Failures) > 0 {
+			reportPath := filepath.Join(*destination, "report.json")
+			if err := writeDownloadReport(reportPath, report.Failures, perm.file); err != nil {
+				logger.Error("failed to write the download report", "path", reportPath, "err", err)
+			}
+		}
+		if *notifyURL != "" {
+			if err := notifyRun(*notifyURL, report, duration); err != nil {
+				logger.Error("failed to notify -notify-url", "err", err)
+			}
+		}
 	}
 
 	// Read existing timeline.json from the destination directory.
 	if timeline, err = db.List(); err != nil {
-		logger.Log("msg", "failed to read the local timeline", "err", err)
+		logger.Error("failed to read the local timeline", "err", err)
 		return
 	}
+	// A duplicate ID would overwrite another media's file on disk, so it's
+	// worth flagging even though it doesn't stop the gallery from rendering.
+	if dupes := igshelf.ValidateUnique(timeline); len(dupes) > 0 {
+		logger.Warn("found duplicate media IDs, some files may have been overwritten", "ids", strings.Join(dupes, ","))
+	}
+	// A missing or corrupted file shouldn't render as a broken image, so
+	// the template falls back to a placeholder for whatever isn't there.
+	igshelf.MarkAvailable(timeline, contentDirPath)
 
 	// Render the timeline as html page.
-	t, err := template.ParseFiles(templatePath)
+	t, err := loadTemplate(*templateArg)
 	if err != nil {
-		logger.Log("msg", "failed to parse the template", "path", templatePath, "err", err)
+		logger.Error("failed to parse the template", "path", *templateArg, "err", err)
 		return
 	}
 
 	data := struct {
-		Posts []*igshelf.Media
-	}{timeline}
+		Posts      []postView
+		ContentDir string
+	}{buildPostViews(timeline), contentLink}
 	buf := bytes.Buffer{}
 	if err = t.Execute(&buf, data); err != nil {
-		logger.Log("msg", "failed to render the timeline", "err", err)
+		logger.Error("failed to render the timeline", "err", err)
 		return
 	}
-	if err = ioutil.WriteFile(timelineHTMLpath, buf.Bytes(), 0600); err != nil {
-		logger.Log("msg", "failed to write timeline.html on disk", "err", err)
+	if err = ioutil.WriteFile(timelineHTMLpath, buf.Bytes(), perm.file); err != nil {
+		logger.Error("failed to write timeline.html on disk", "err", err)
 		return
 	}
 
 	// The program terminates successfully.
 	exitCode = 0
 }
+
+// configFileParser picks a ff.ConfigFileParser based on the -config file's
+// extension, so YAML/JSON config files work in addition to the default
+// key=value plain format. args is scanned directly for -config's value
+// since the flag itself isn't parsed yet at this point.
+func configFileParser(args []string) ff.ConfigFileParser {
+	switch strings.ToLower(filepath.Ext(configFilename(args))) {
+	case ".yaml", ".yml":
+		return ffyaml.Parser
+	case ".json":
+		return ff.JSONParser
+	default:
+		return ff.PlainParser
+	}
+}
+
+// configFilename extracts the -config flag's value from args, handling both
+// "-config value" and "-config=value" forms (with either one or two leading
+// dashes), or "" if it's not present.
+func configFilename(args []string) string {
+	for i, arg := range args {
+		arg = strings.TrimPrefix(strings.TrimPrefix(arg, "-"), "-")
+		switch {
+		case arg == "config" && i+1 < len(args):
+			return args[i+1]
+		case strings.HasPrefix(arg, "config="):
+			return strings.TrimPrefix(arg, "config=")
+		}
+	}
+	return ""
+}
+
+// readTokenFile reads the Instagram API access token from a file,
+// trimming surrounding whitespace such as a trailing newline.
+func readTokenFile(filename string) (string, error) {
+	b, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(b)), nil
+}
+
+// requiredTokenScope is the permission a timeline download can't proceed
+// without; it corresponds to the "user_media" scope requested by "igshelf
+// auth" (see defaultAuthScopes).
+const requiredTokenScope = "user_media"
+
+// checkTokenScopes fails fast if the API access token is expired, revoked,
+// or missing requiredTokenScope, so a long download run doesn't get
+// partway through before hitting an authorization error on every request.
+func checkTokenScopes(ctx context.Context, accessToken string) error {
+	c := instagram.NewClient(accessToken, instagram.WithTimeout(30*time.Second))
+	info, err := c.DebugToken(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to check access token: %w", err)
+	}
+	if !info.Valid {
+		return errors.New("access token is expired or revoked")
+	}
+	for _, s := range info.Scopes {
+		if s == requiredTokenScope {
+			return nil
+		}
+	}
+	return fmt.Errorf("access token is missing the %q permission", requiredTokenScope)
+}
+
+// newMediaService builds the igshelf.MediaService a user's -src selects:
+// the Instagram API for "api", or one or more zip archives merged
+// together. includeStories makes an archive-backed service also parse its
+// stories/ directory (see -stories); it's ignored for the API, which has
+// no notion of an archived story. closer is non-nil for an archive-backed
+// service and must be closed once the caller is done with it; it's nil for
+// the API, which holds no resources needing a close. It's shared by the
+// main download flow and the repair subcommand so both resolve -src the
+// same way.
+func newMediaService(sources sourceList, accessToken, user string, logger *slog.Logger, includeStories bool) (ig igshelf.MediaService, closer io.Closer, err error) {
+	switch {
+	case len(sources) == 1 && sources[0] == "api":
+		ig = instagram.NewService(
+			instagram.NewClient(accessToken, instagram.WithTimeout(30*time.Second)),
+			user,
+			instagram.WithSlog(logger),
+		)
+	case len(sources) == 1:
+		arch, err := archive.NewService(sources[0], archive.WithStories(includeStories))
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to open Instagram archive: %w", err)
+		}
+		ig, closer = arch, arch
+	case len(sources) > 1:
+		arch, err := archive.NewMultiService(sources, archive.WithStories(includeStories))
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to open Instagram archives: %w", err)
+		}
+		ig, closer = arch, arch
+	}
+	return ig, closer, nil
+}
+
+// storyLister is implemented by media services that can additionally
+// surface Instagram stories, e.g. archive.MediaService reading them from
+// an export's stories/ directory, see -stories.
+type storyLister interface {
+	Stories(ctx context.Context) ([]*igshelf.Media, error)
+}
+
+// storyTimeline wraps an igshelf.MediaService, appending an archive's
+// stories after its regular timeline, so a -stories run downloads and
+// renders them through the exact same pipeline as everything else instead
+// of needing a parallel code path.
+type storyTimeline struct {
+	igshelf.MediaService
+	stories []*igshelf.Media
+}
+
+// List returns the wrapped service's timeline, followed by stories.
+func (s *storyTimeline) List(ctx context.Context, since time.Time) igshelf.MediaIter {
+	return &appendedMediaIter{inner: s.MediaService.List(ctx, since), extra: s.stories}
+}
+
+// EstimatedSize forwards to the wrapped service if it supports estimating
+// download size, so wrapping in storyTimeline doesn't hide that from
+// main's sizeEstimator check.
+func (s *storyTimeline) EstimatedSize(ctx context.Context) (int64, error) {
+	se, ok := s.MediaService.(sizeEstimator)
+	if !ok {
+		return -1, nil
+	}
+	return se.EstimatedSize(ctx)
+}
+
+// appendedMediaIter walks inner to exhaustion, then walks extra, so a
+// caller sees one continuous timeline instead of having to combine two.
+type appendedMediaIter struct {
+	inner   igshelf.MediaIter
+	extra   []*igshelf.Media
+	i       int
+	current *igshelf.Media
+}
+
+func (it *appendedMediaIter) Next() bool {
+	if it.inner != nil {
+		if it.inner.Next() {
+			it.current = it.inner.Media()
+			return true
+		}
+		if it.inner.Err() != nil {
+			return false
+		}
+		it.inner = nil
+	}
+	if it.i >= len(it.extra) {
+		return false
+	}
+	it.current = it.extra[it.i]
+	it.i++
+	return true
+}
+
+func (it *appendedMediaIter) Media() *igshelf.Media { return it.current }
+func (it *appendedMediaIter) Err() error {
+	if it.inner != nil {
+		return it.inner.Err()
+	}
+	return nil
+}
+
+// sizeEstimator is implemented by media services that can report how many
+// bytes their timeline will take to download, e.g. archive.MediaService
+// reading it straight from the zip index, so main can print an estimate
+// without every igshelf.MediaService needing to support it. A negative
+// estimate (e.g. from the Instagram API, which doesn't expose sizes upfront)
+// means it's unknown and nothing is printed.
+type sizeEstimator interface {
+	EstimatedSize(ctx context.Context) (int64, error)
+}
+
+// formatBytes renders n bytes as a human-readable size using decimal
+// (1000-based) units, matching how Instagram itself reports export sizes.
+func formatBytes(n int64) string {
+	const unit = 1000
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %cB", float64(n)/float64(div), "kMGTPE"[exp])
+}
+
+// loadIDs merges -ids and -ids-file into a single list of media IDs to
+// pass to downloader.Service.DownloadIDs, e.g. to recover the "four missing
+// files" scenario from the package doc without a full run. ids-file has
+// one ID per line; blank lines are ignored. It returns nil if neither flag
+// is given, meaning a caller should fall back to a full Download.
+func loadIDs(ids, idsFile string) ([]string, error) {
+	var all []string
+	for _, id := range strings.Split(ids, ",") {
+		if id = strings.TrimSpace(id); id != "" {
+			all = append(all, id)
+		}
+	}
+
+	if idsFile != "" {
+		b, err := ioutil.ReadFile(idsFile)
+		if err != nil {
+			return nil, err
+		}
+		for _, line := range strings.Split(string(b), "\n") {
+			if line = strings.TrimSpace(line); line != "" {
+				all = append(all, line)
+			}
+		}
+	}
+
+	return all, nil
+}
+
+// resolveContentDir turns -content-dir into an absolute-or-destination-relative
+// path media files are stored under, and the gallery-relative link
+// timeline.html uses to reach it, so a user can merge downloads into an
+// existing media library that lives outside -dst, or just rename it. dir
+// is resolved against destination unless it's already absolute.
+func resolveContentDir(destination, dir string) (path, link string, err error) {
+	path = dir
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(destination, path)
+	}
+	link, err = filepath.Rel(destination, path)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to resolve %q relative to %q: %w", path, destination, err)
+	}
+	return path, filepath.ToSlash(link), nil
+}
+
+// parseTypes splits -types' comma-separated value into igshelf.Media Type
+// values (IMAGE, VIDEO), e.g. "image,video" becomes ["IMAGE", "VIDEO"].
+// It returns nil if s is empty.
+func parseTypes(s string) []string {
+	var types []string
+	for _, t := range strings.Split(s, ",") {
+		if t = strings.TrimSpace(t); t != "" {
+			types = append(types, strings.ToUpper(t))
+		}
+	}
+	return types
+}
+
+// sourceList collects -src values into a flag.Value, so -src can be given
+// multiple times or as a single comma-separated list, e.g. to merge several
+// Instagram exports into one gallery.
+type sourceList []string
+
+func (l *sourceList) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *sourceList) Set(v string) error {
+	for _, s := range strings.Split(v, ",") {
+		if s = strings.TrimSpace(s); s != "" {
+			*l = append(*l, s)
+		}
+	}
+	return nil
+}
+
+// filePerm holds the permission bits used for files and directories written by igshelf.
+type filePerm struct {
+	file os.FileMode
+	dir  os.FileMode
+}
+
+// parseFileModes parses the -filemode and -dirmode octal flag values.
+func parseFileModes(fileMode, dirMode string) (filePerm, error) {
+	file, err := strconv.ParseUint(fileMode, 8, 32)
+	if err != nil {
+		return filePerm{}, fmt.Errorf("invalid -filemode %q: %w", fileMode, err)
+	}
+	dir, err := strconv.ParseUint(dirMode, 8, 32)
+	if err != nil {
+		return filePerm{}, fmt.Errorf("invalid -dirmode %q: %w", dirMode, err)
+	}
+	return filePerm{file: os.FileMode(file), dir: os.FileMode(dir)}, nil
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/marselester/igshelf"
+)
+
+func TestReadTokenFile(t *testing.T) {
+	dir := t.TempDir()
+	filename := filepath.Join(dir, "token")
+	if err := ioutil.WriteFile(filename, []byte("IGQVJ...\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := readTokenFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "IGQVJ..."; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestReadTokenFile_missing(t *testing.T) {
+	_, err := readTokenFile(filepath.Join(t.TempDir(), "missing"))
+	if !os.IsNotExist(err) {
+		t.Errorf("got err %v, want a not-exist error", err)
+	}
+}
+
+func TestResolveContentDir(t *testing.T) {
+	tt := map[string]struct {
+		destination string
+		dir         string
+		wantPath    string
+		wantLink    string
+	}{
+		"default name": {
+			destination: "/gallery",
+			dir:         "content",
+			wantPath:    "/gallery/content",
+			wantLink:    "content",
+		},
+		"custom name": {
+			destination: "/gallery",
+			dir:         "media",
+			wantPath:    "/gallery/media",
+			wantLink:    "media",
+		},
+		"absolute path outside destination": {
+			destination: "/gallery",
+			dir:         "/library",
+			wantPath:    "/library",
+			wantLink:    "../library",
+		},
+	}
+
+	for name, tc := range tt {
+		t.Run(name, func(t *testing.T) {
+			path, link, err := resolveContentDir(tc.destination, tc.dir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if path != tc.wantPath {
+				t.Errorf("got path %q, want %q", path, tc.wantPath)
+			}
+			if link != tc.wantLink {
+				t.Errorf("got link %q, want %q", link, tc.wantLink)
+			}
+		})
+	}
+}
+
+// fakeMediaIter is a minimal igshelf.MediaIter used to exercise
+// appendedMediaIter without a real media service.
+type fakeMediaIter struct {
+	items []*igshelf.Media
+	i     int
+	err   error
+}
+
+func (it *fakeMediaIter) Next() bool {
+	if it.err != nil || it.i >= len(it.items) {
+		return false
+	}
+	it.i++
+	return true
+}
+func (it *fakeMediaIter) Media() *igshelf.Media { return it.items[it.i-1] }
+func (it *fakeMediaIter) Err() error            { return it.err }
+
+// TestAppendedMediaIter checks that it walks the inner iterator to
+// exhaustion before falling through to extra, so a -stories run's
+// downloaded gallery lists the main timeline first and stories after.
+func TestAppendedMediaIter(t *testing.T) {
+	inner := &fakeMediaIter{items: []*igshelf.Media{{ID: "1"}, {ID: "2"}}}
+	extra := []*igshelf.Media{{ID: "3"}}
+
+	it := &appendedMediaIter{inner: inner, extra: extra}
+	var got []string
+	for it.Next() {
+		got = append(got, it.Media().ID)
+	}
+	if it.Err() != nil {
+		t.Fatal(it.Err())
+	}
+
+	want := []string{"1", "2", "3"}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("media IDs mismatch (-want +got):\n%s", diff)
+	}
+}
+
+// TestAppendedMediaIterInnerError checks that a failing inner iterator
+// stops the iteration and surfaces its error, instead of silently falling
+// through to extra as if inner had simply run out of media.
+func TestAppendedMediaIterInnerError(t *testing.T) {
+	inner := &fakeMediaIter{err: errors.New("boom")}
+	it := &appendedMediaIter{inner: inner, extra: []*igshelf.Media{{ID: "3"}}}
+
+	if it.Next() {
+		t.Fatal("expected Next to stop on the inner error instead of falling through to extra")
+	}
+	if it.Err() == nil {
+		t.Error("expected Err to surface the inner error")
+	}
+}
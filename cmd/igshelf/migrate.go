@@ -0,0 +1,70 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"log/slog"
+	"os"
+	"path/filepath"
+
+	"github.com/marselester/igshelf/internal/jsonfile"
+)
+
+// runMigrate upgrades a destination directory's timeline.json from a legacy
+// bare array to the current versioned envelope, so a gallery downloaded by
+// an older igshelf release doesn't need to be re-downloaded from scratch to
+// pick up newer Media fields. The original file is kept as timeline.json.bak.
+func runMigrate(logger *slog.Logger, args []string) int {
+	fs := flag.NewFlagSet("igshelf migrate", flag.ExitOnError)
+	destination := fs.String("dst", "", "path to a directory where timeline is stored")
+	if err := fs.Parse(args); err != nil {
+		logger.Error("failed to parse flags", "err", err)
+		return 1
+	}
+	if *destination == "" {
+		logger.Error("-dst is required")
+		return 1
+	}
+
+	timelineJSONPath := filepath.Join(*destination, "timeline.json")
+	if err := migrateTimeline(timelineJSONPath); err != nil {
+		logger.Error("failed to migrate the local timeline", "path", timelineJSONPath, "err", err)
+		return 1
+	}
+
+	logger.Info("migrated the local timeline", "path", timelineJSONPath, "backup", timelineJSONPath+".bak")
+	return 0
+}
+
+// migrateTimeline reads timeline.json at path (legacy array or current
+// envelope, jsonfile.MediaRepository.List reads both), backs up the
+// original bytes to path+".bak", then atomically rewrites path in the
+// current envelope version.
+func migrateTimeline(path string) error {
+	original, err := ioutil.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read timeline: %w", err)
+	}
+
+	db := jsonfile.NewMediaRepository(path)
+	timeline, err := db.List()
+	if err != nil {
+		return fmt.Errorf("failed to parse timeline: %w", err)
+	}
+
+	if err := ioutil.WriteFile(path+".bak", original, 0600); err != nil {
+		return fmt.Errorf("failed to write timeline backup: %w", err)
+	}
+
+	// Store into a temp file first and rename it over path, so a crash
+	// mid-write can't leave a truncated timeline.json behind.
+	tmpPath := path + ".tmp"
+	if err := jsonfile.NewMediaRepository(tmpPath).Store(timeline); err != nil {
+		return fmt.Errorf("failed to write migrated timeline: %w", err)
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		return fmt.Errorf("failed to replace timeline with migrated version: %w", err)
+	}
+	return nil
+}
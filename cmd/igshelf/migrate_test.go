@@ -0,0 +1,45 @@
+package main
+
+import (
+	"io"
+	"io/ioutil"
+	"log/slog"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/marselester/igshelf"
+	"github.com/marselester/igshelf/internal/jsonfile"
+)
+
+func TestRunMigrateLegacyTimeline(t *testing.T) {
+	dir := t.TempDir()
+	timelineJSONPath := filepath.Join(dir, "timeline.json")
+	legacy := []byte(`[{"ID": "1"}, {"ID": "2"}]`)
+	if err := ioutil.WriteFile(timelineJSONPath, legacy, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	if code := runMigrate(logger, []string{"-dst", dir}); code != 0 {
+		t.Fatalf("got exit code %d, want 0", code)
+	}
+
+	backup, err := ioutil.ReadFile(timelineJSONPath + ".bak")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(string(legacy), string(backup)); diff != "" {
+		t.Errorf("backup mismatch (-want +got):\n%s", diff)
+	}
+
+	timeline, err := jsonfile.NewMediaRepository(timelineJSONPath).List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []*igshelf.Media{{ID: "1"}, {ID: "2"}}
+	if diff := cmp.Diff(want, timeline); diff != "" {
+		t.Errorf("timeline mismatch (-want +got):\n%s", diff)
+	}
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/marselester/igshelf/internal/downloader"
+)
+
+// runSummary is the JSON body POSTed to -notify-url once a run finishes.
+type runSummary struct {
+	Downloaded int64                    `json:"downloaded"`
+	Skipped    int64                    `json:"skipped"`
+	Failed     int64                    `json:"failed"`
+	TotalBytes int64                    `json:"total_bytes"`
+	Complete   bool                     `json:"complete"`
+	Duration   float64                  `json:"duration_seconds"`
+	Failures   []downloader.FailedMedia `json:"failures,omitempty"`
+}
+
+// notifyRun POSTs a small JSON summary of report to url, so a scheduled
+// backup can alert on completion (or failures) without tailing logs.
+func notifyRun(url string, report downloader.Report, duration time.Duration) error {
+	summary := runSummary{
+		Downloaded: report.Downloaded,
+		Skipped:    report.Skipped,
+		Failed:     report.Failed,
+		TotalBytes: report.TotalBytes,
+		Complete:   report.Complete,
+		Duration:   duration.Seconds(),
+		Failures:   report.Failures,
+	}
+	b, err := json.Marshal(summary)
+	if err != nil {
+		return fmt.Errorf("failed to marshal run summary: %w", err)
+	}
+
+	resp, err := http.Post(url, "application/json", bytes.NewReader(b))
+	if err != nil {
+		return fmt.Errorf("failed to post run summary: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("notify url responded with status %d", resp.StatusCode)
+	}
+	return nil
+}
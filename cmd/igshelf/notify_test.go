@@ -0,0 +1,51 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/marselester/igshelf/internal/downloader"
+)
+
+func TestNotifyRun(t *testing.T) {
+	var got runSummary
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := json.NewDecoder(r.Body).Decode(&got); err != nil {
+			t.Fatal(err)
+		}
+	}))
+	defer srv.Close()
+
+	report := downloader.Report{
+		Stats: downloader.Stats{
+			Downloaded: 3,
+			Skipped:    1,
+			Failed:     1,
+			TotalBytes: 1024,
+		},
+		Complete: true,
+		Failures: []downloader.FailedMedia{{ID: "1", Reason: "boom"}},
+	}
+
+	if err := notifyRun(srv.URL, report, 2*time.Second); err != nil {
+		t.Fatal(err)
+	}
+
+	want := runSummary{
+		Downloaded: 3,
+		Skipped:    1,
+		Failed:     1,
+		TotalBytes: 1024,
+		Complete:   true,
+		Duration:   2,
+		Failures:   []downloader.FailedMedia{{ID: "1", Reason: "boom"}},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("run summary mismatch (-want +got):\n%s", diff)
+	}
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"log/slog"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+	"time"
+
+	"github.com/marselester/igshelf"
+	"github.com/marselester/igshelf/internal/downloader"
+	"github.com/marselester/igshelf/internal/jsonfile"
+)
+
+// runRepair re-downloads only the media missing from a destination
+// directory's content dir, using the existing timeline.json as the list of
+// what should be there. It's the recovery tool for the "four missing
+// photos/videos" scenario from the package doc: rather than repeating a
+// whole Download, it fetches just the gaps.
+func runRepair(logger *slog.Logger, args []string) int {
+	fs := flag.NewFlagSet("igshelf repair", flag.ExitOnError)
+	var sources sourceList
+	fs.Var(&sources, "src", `source(s) of the Instagram timeline: "api", or one or more paths to zip archives to merge, comma-separated or given as repeated -src flags`)
+	var (
+		destination = fs.String("dst", "", "path to a directory where timeline is stored")
+		workerCount = fs.Int("worker", 10, "number of workers that copy media files, 0 picks a count automatically")
+		token       = fs.String("token", "", "Instagram API access token")
+		tokenFile   = fs.String("token-file", "", "path to a file containing Instagram API access token")
+		user        = fs.String("user", "me", "user whose timeline should be downloaded")
+		fileMode    = fs.String("filemode", "0600", "octal file permissions for written media files")
+		dirMode     = fs.String("dirmode", "0700", "octal directory permissions for created directories")
+	)
+	if err := fs.Parse(args); err != nil {
+		logger.Error("failed to parse flags", "err", err)
+		return 1
+	}
+
+	accessToken := *token
+	if accessToken == "" && *tokenFile != "" {
+		var err error
+		if accessToken, err = readTokenFile(*tokenFile); err != nil {
+			logger.Error("failed to read the token file", "err", err)
+			return 1
+		}
+	}
+	if err := validateConfig(sources, *destination, accessToken); err != nil {
+		logger.Error("invalid configuration", "err", err)
+		return 1
+	}
+
+	perm, err := parseFileModes(*fileMode, *dirMode)
+	if err != nil {
+		logger.Error("invalid file permissions", "err", err)
+		return 1
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, syscall.SIGINT, syscall.SIGTERM)
+	go func() {
+		select {
+		case <-sig:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	var (
+		timelineJSONPath = filepath.Join(*destination, "timeline.json")
+		contentDirPath   = filepath.Join(*destination, "content")
+	)
+	db := jsonfile.NewMediaRepository(timelineJSONPath, jsonfile.WithFileMode(perm.file))
+	timeline, err := db.List()
+	if err != nil {
+		logger.Error("failed to read the local timeline", "err", err)
+		return 1
+	}
+
+	missing := missingMediaIDs(timeline, contentDirPath)
+	if len(missing) == 0 {
+		logger.Info("no missing files found, nothing to repair")
+		return 0
+	}
+	logger.Info("found missing files", "count", len(missing))
+
+	// Stories aren't fetched here: repair works by re-downloading specific
+	// IDs already recorded in timeline.json via Get, which doesn't consult
+	// an archive's stories.json.
+	ig, closeIG, err := newMediaService(sources, accessToken, *user, logger, false)
+	if err != nil {
+		logger.Error("failed to prepare a media service", "err", err)
+		return 1
+	}
+	if closeIG != nil {
+		defer closeIG.Close()
+	}
+
+	d := downloader.NewService(ig, db,
+		downloader.WithMaxWorkers(*workerCount),
+		downloader.WithSlog(logger),
+		downloader.WithFileMode(perm.file),
+	)
+	start := time.Now()
+	report, err := d.DownloadIDs(ctx, contentDirPath, missing)
+	if err != nil {
+		logger.Error("failed to repair the timeline", "err", err)
+		return 1
+	}
+	logger.Info("repaired the local timeline",
+		"downloaded", report.Downloaded,
+		"failed", report.Failed,
+		"duration", time.Since(start),
+	)
+	if len(report.Failures) > 0 {
+		reportPath := filepath.Join(*destination, "report.json")
+		if err := writeDownloadReport(reportPath, report.Failures, perm.file); err != nil {
+			logger.Error("failed to write the download report", "path", reportPath, "err", err)
+		}
+	}
+
+	return 0
+}
+
+// missingMediaIDs walks the timeline, including album Children, and
+// returns the IDs of media whose Filename isn't present under
+// contentDirPath, e.g. because a prior Download run was interrupted or a
+// zip archive was missing a file the JSON index referenced. Unavailable
+// media (e.g. a copyright-flagged album child) is excluded, since it has
+// no content to fetch and would otherwise be reported missing forever.
+func missingMediaIDs(timeline []*igshelf.Media, contentDirPath string) []string {
+	var missing []string
+	var walk func(mm []*igshelf.Media)
+	walk = func(mm []*igshelf.Media) {
+		for _, m := range mm {
+			if m.Filename != "" && !m.Unavailable {
+				if _, err := os.Stat(filepath.Join(contentDirPath, m.Filename)); os.IsNotExist(err) {
+					missing = append(missing, m.ID)
+				}
+			}
+			walk(m.Children)
+		}
+	}
+	walk(timeline)
+	return missing
+}
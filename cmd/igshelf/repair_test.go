@@ -0,0 +1,47 @@
+package main
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/marselester/igshelf"
+)
+
+// TestMissingMediaIDs checks that only media whose Filename is absent from
+// the content dir is reported as missing, including inside a
+// CAROUSEL_ALBUM's Children, so a repair run doesn't re-fetch files that
+// already made it to disk.
+func TestMissingMediaIDs(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"1.jpg", "3.jpg"} {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte("x"), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	timeline := []*igshelf.Media{
+		{ID: "1", Filename: "1.jpg"},
+		{ID: "2", Filename: "2.jpg"},
+		{
+			ID: "album",
+			Children: []*igshelf.Media{
+				{ID: "3", Filename: "3.jpg"},
+				{ID: "4", Filename: "4.jpg"},
+				// A copyright-flagged child has no content to fetch, so it
+				// shouldn't be reported missing even though its file is absent.
+				{ID: "5", Filename: "5.jpg", Unavailable: true},
+			},
+		},
+	}
+
+	got := missingMediaIDs(timeline, dir)
+	sort.Strings(got)
+	want := []string{"2", "4"}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("missing IDs mismatch (-want +got):\n%s", diff)
+	}
+}
@@ -0,0 +1,21 @@
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+
+	"github.com/marselester/igshelf/internal/downloader"
+)
+
+// writeDownloadReport writes report.json listing media that were missing or
+// failed to download, so they aren't lost once stderr scrolls past.
+func writeDownloadReport(path string, failures []downloader.FailedMedia, mode os.FileMode) error {
+	b, err := json.MarshalIndent(struct {
+		Failures []downloader.FailedMedia `json:"failures"`
+	}{failures}, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, b, mode)
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/marselester/igshelf/internal/downloader"
+)
+
+func TestWriteDownloadReport(t *testing.T) {
+	failures := []downloader.FailedMedia{
+		{ID: "1", Reason: "file not found in archive", TakenAt: time.Date(2020, time.October, 7, 15, 55, 33, 0, time.UTC)},
+	}
+	path := filepath.Join(t.TempDir(), "report.json")
+
+	if err := writeDownloadReport(path, failures, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `{
+  "failures": [
+    {
+      "id": "1",
+      "reason": "file not found in archive",
+      "taken_at": "2020-10-07T15:55:33Z"
+    }
+  ]
+}`
+	if diff := cmp.Diff(want, string(got)); diff != "" {
+		t.Errorf(diff)
+	}
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"flag"
+	"html/template"
+	"log/slog"
+	"net/http"
+	"path/filepath"
+	"strconv"
+
+	"github.com/marselester/igshelf"
+	"github.com/marselester/igshelf/internal/jsonfile"
+)
+
+// defaultPageSize is a number of posts rendered per page in serve mode.
+const defaultPageSize = 20
+
+// runServe starts an HTTP server that browses a previously downloaded
+// gallery, rendering pages of the timeline on demand instead of a single
+// static timeline.html.
+func runServe(logger *slog.Logger, args []string) int {
+	fs := flag.NewFlagSet("igshelf serve", flag.ExitOnError)
+	var (
+		destination = fs.String("dst", "", "path to a directory where timeline is stored")
+		addr        = fs.String("addr", ":8080", "address to listen on")
+		templateArg = fs.String("template", "", "path to a custom gallery template file, defaults to the embedded template")
+	)
+	if err := fs.Parse(args); err != nil {
+		logger.Error("failed to parse flags", "err", err)
+		return 1
+	}
+	if *destination == "" {
+		logger.Error("-dst is required")
+		return 1
+	}
+
+	t, err := loadTemplate(*templateArg)
+	if err != nil {
+		logger.Error("failed to parse the template", "path", *templateArg, "err", err)
+		return 1
+	}
+
+	db := jsonfile.NewMediaRepository(filepath.Join(*destination, "timeline.json"))
+	contentDirPath := filepath.Join(*destination, "content")
+
+	mux := http.NewServeMux()
+	mux.Handle("/content/", http.StripPrefix("/content/", http.FileServer(http.Dir(contentDirPath))))
+	mux.Handle("/", galleryHandler(logger, t, db, contentDirPath))
+
+	logger.Info("listening", "addr", *addr)
+	if err := http.ListenAndServe(*addr, mux); err != nil {
+		logger.Error("server stopped", "err", err)
+		return 1
+	}
+	return 0
+}
+
+// galleryHandler renders a page of the timeline, optionally narrowed by the
+// "q" query param (case-insensitive caption substring match) and paginated
+// with the 1-based "page" query param.
+func galleryHandler(logger *slog.Logger, t *template.Template, db igshelf.MediaRepository, contentDirPath string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		timeline, err := db.List()
+		if err != nil {
+			logger.Error("failed to read the local timeline", "err", err)
+			http.Error(w, "failed to read the local timeline", http.StatusInternalServerError)
+			return
+		}
+
+		if q := r.URL.Query().Get("q"); q != "" {
+			timeline = igshelf.Filter{CaptionContains: q}.Apply(timeline)
+		}
+
+		page := 1
+		if v := r.URL.Query().Get("page"); v != "" {
+			if n, err := strconv.Atoi(v); err == nil && n > 0 {
+				page = n
+			}
+		}
+		timeline = paginate(timeline, page, defaultPageSize)
+		igshelf.MarkAvailable(timeline, contentDirPath)
+
+		data := struct {
+			Posts      []postView
+			ContentDir string
+		}{buildPostViews(timeline), "content"}
+		if err := t.Execute(w, data); err != nil {
+			logger.Error("failed to render the timeline", "err", err)
+			http.Error(w, "failed to render the timeline", http.StatusInternalServerError)
+		}
+	}
+}
+
+// paginate returns the page-th (1-based) slice of timeline, pageSize items at a time.
+func paginate(timeline []*igshelf.Media, page, pageSize int) []*igshelf.Media {
+	start := (page - 1) * pageSize
+	if start >= len(timeline) {
+		return nil
+	}
+	end := start + pageSize
+	if end > len(timeline) {
+		end = len(timeline)
+	}
+	return timeline[start:end]
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"html/template"
+	"io"
+	"log/slog"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/marselester/igshelf"
+	"github.com/marselester/igshelf/internal/mock"
+)
+
+func TestGalleryHandlerSearch(t *testing.T) {
+	timeline := []*igshelf.Media{
+		{ID: "1", Caption: "Still jumping"},
+		{ID: "2", Caption: "Sunset walk"},
+	}
+	db := mock.MediaRepository{ListFn: func() ([]*igshelf.Media, error) {
+		return timeline, nil
+	}}
+	tpl := template.Must(template.New("timeline").Parse(`{{range .Posts}}{{.Caption}}
+{{end}}`))
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	h := galleryHandler(logger, tpl, &db, t.TempDir())
+
+	req := httptest.NewRequest("GET", "/?q=jumping", nil)
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+
+	body := w.Body.String()
+	if !strings.Contains(body, "Still jumping") {
+		t.Errorf("got %q, want it to contain the matching caption", body)
+	}
+	if strings.Contains(body, "Sunset walk") {
+		t.Errorf("got %q, didn't want the non-matching caption", body)
+	}
+}
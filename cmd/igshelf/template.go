@@ -0,0 +1,83 @@
+package main
+
+import (
+	"embed"
+	"fmt"
+	"html/template"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/marselester/igshelf"
+)
+
+// embeddedTemplateFS holds the default gallery and index templates baked
+// into the binary, so igshelf works standalone without a template directory
+// next to it.
+//
+//go:embed template/timeline.tpl template/index.tpl
+var embeddedTemplateFS embed.FS
+
+// defaultTemplateName is the embedded template served when -template is unset.
+const defaultTemplateName = "template/timeline.tpl"
+
+// defaultIndexTemplateName is the embedded index template served when the
+// index command's -template is unset.
+const defaultIndexTemplateName = "template/index.tpl"
+
+// templateFuncs are helpers available to gallery templates.
+var templateFuncs = template.FuncMap{
+	"date": func(layout string, t time.Time) string {
+		return t.Format(layout)
+	},
+	"linkify": func(url, text string) template.HTML {
+		return template.HTML(fmt.Sprintf(`<a href="%s">%s</a>`, template.HTMLEscapeString(url), template.HTMLEscapeString(text)))
+	},
+}
+
+// loadTemplate parses the gallery template at path, or the embedded default
+// template when path is empty.
+func loadTemplate(path string) (*template.Template, error) {
+	if path == "" {
+		return template.New(filepath.Base(defaultTemplateName)).Funcs(templateFuncs).ParseFS(embeddedTemplateFS, defaultTemplateName)
+	}
+	return template.New(filepath.Base(path)).Funcs(templateFuncs).ParseFiles(path)
+}
+
+// titleMaxLen is how many runes postTitle keeps before truncating with an
+// ellipsis.
+const titleMaxLen = 80
+
+// postView augments a Media with fields computed for display, so a gallery
+// template doesn't have to do its own string manipulation.
+type postView struct {
+	*igshelf.Media
+	// Title is a single-line, truncated version of Caption, see postTitle.
+	Title string
+}
+
+// buildPostViews wraps each media in timeline with its computed presentation fields.
+func buildPostViews(timeline []*igshelf.Media) []postView {
+	views := make([]postView, len(timeline))
+	for i, m := range timeline {
+		views[i] = postView{Media: m, Title: postTitle(m.Caption)}
+	}
+	return views
+}
+
+// postTitle derives a display title from a possibly multiline caption: only
+// the first line is kept, trimmed of surrounding whitespace, and truncated
+// to titleMaxLen runes with a trailing "…" if it's longer.
+func postTitle(caption string) string {
+	line := caption
+	if i := strings.IndexByte(caption, '\n'); i >= 0 {
+		line = caption[:i]
+	}
+	line = strings.TrimSpace(line)
+
+	r := []rune(line)
+	if len(r) <= titleMaxLen {
+		return line
+	}
+	return string(r[:titleMaxLen]) + "…"
+}
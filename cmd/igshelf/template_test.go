@@ -0,0 +1,189 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/marselester/igshelf"
+)
+
+func TestLoadTemplateEmbeddedDefault(t *testing.T) {
+	tpl, err := loadTemplate("")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	data := struct{ Posts []interface{} }{}
+	if err := tpl.Execute(&buf, data); err != nil {
+		t.Fatal(err)
+	}
+	if buf.Len() == 0 {
+		t.Error("expected the embedded template to render something")
+	}
+}
+
+// TestLoadTemplateEmbeddedDefaultWithoutTemplateDir checks that the
+// embedded template is used even when run from a directory that has no
+// template/timeline.tpl on disk, e.g. a go-installed binary run from $PATH.
+func TestLoadTemplateEmbeddedDefaultWithoutTemplateDir(t *testing.T) {
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(t.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Chdir(wd) })
+
+	tpl, err := loadTemplate("")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	data := struct{ Posts []interface{} }{}
+	if err := tpl.Execute(&buf, data); err != nil {
+		t.Fatal(err)
+	}
+	if buf.Len() == 0 {
+		t.Error("expected the embedded template to render something")
+	}
+}
+
+func TestPostTitle(t *testing.T) {
+	tt := map[string]struct {
+		caption string
+		want    string
+	}{
+		"empty": {
+			caption: "",
+			want:    "",
+		},
+		"single line": {
+			caption: "Still jumping",
+			want:    "Still jumping",
+		},
+		"multiline keeps first line": {
+			caption: "Still jumping\n#vacation #beach",
+			want:    "Still jumping",
+		},
+		"first line trimmed": {
+			caption: "  Still jumping  \nmore text",
+			want:    "Still jumping",
+		},
+		"long caption is truncated": {
+			caption: strings.Repeat("a", titleMaxLen+10),
+			want:    strings.Repeat("a", titleMaxLen) + "…",
+		},
+	}
+	for name, tc := range tt {
+		t.Run(name, func(t *testing.T) {
+			if got := postTitle(tc.caption); got != tc.want {
+				t.Errorf("got %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+// TestTimelineTemplateContentDir checks that the embedded gallery template
+// links to media through the custom directory named by ContentDir, so a
+// -content-dir override (see resolveContentDir) is reflected in
+// timeline.html, not just on disk.
+func TestTimelineTemplateContentDir(t *testing.T) {
+	tpl, err := loadTemplate("")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	timeline := []*igshelf.Media{
+		{ID: "1", Filename: "1.jpg", Available: true, TakenAt: time.Date(2020, time.October, 7, 0, 0, 0, 0, time.UTC)},
+	}
+	data := struct {
+		Posts      []postView
+		ContentDir string
+	}{buildPostViews(timeline), "media"}
+
+	var buf bytes.Buffer
+	if err := tpl.Execute(&buf, data); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := buf.String(); !strings.Contains(got, `src="media/1.jpg"`) {
+		t.Errorf("expected the rendered gallery to link through the custom content dir, got:\n%s", got)
+	}
+	if strings.Contains(buf.String(), `"content/1.jpg"`) {
+		t.Error("rendered gallery still links through the default content dir")
+	}
+}
+
+// TestTimelineTemplateVideoPoster is a golden-file test asserting that a
+// video post renders a <video> element with a preload="none" attribute
+// (so the file isn't fetched until the visitor hits play) and its
+// ThumbnailFilename as the poster, matching testdata/timeline_video.golden.html.
+func TestTimelineTemplateVideoPoster(t *testing.T) {
+	tpl, err := loadTemplate("")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	timeline := []*igshelf.Media{
+		{
+			ID:                "1",
+			Type:              igshelf.MediaTypeVideo,
+			Caption:           "Skateboarding",
+			Filename:          "1.mp4",
+			ThumbnailFilename: "1_cover.jpg",
+			Available:         true,
+			TakenAt:           time.Date(2020, time.October, 7, 15, 55, 33, 0, time.UTC),
+		},
+	}
+	data := struct {
+		Posts      []postView
+		ContentDir string
+	}{buildPostViews(timeline), "content"}
+
+	var buf bytes.Buffer
+	if err := tpl.Execute(&buf, data); err != nil {
+		t.Fatal(err)
+	}
+
+	want, err := ioutil.ReadFile(filepath.Join("testdata", "timeline_video.golden.html"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := buf.String(); got != string(want) {
+		t.Errorf("rendered gallery doesn't match the golden file (-want +got):\n%s", cmp.Diff(string(want), got))
+	}
+}
+
+func TestLoadTemplateCustomPath(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "custom.tpl")
+	content := `{{date "02 Jan 2006" .Now}}{{linkify "https://example.com" "here"}}`
+	if err := ioutil.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	tpl, err := loadTemplate(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	data := struct{ Now time.Time }{Now: time.Date(2020, time.October, 7, 15, 55, 33, 0, time.UTC)}
+	if err := tpl.Execute(&buf, data); err != nil {
+		t.Fatal(err)
+	}
+
+	want := `07 Oct 2020<a href="https://example.com">here</a>`
+	if got := buf.String(); got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
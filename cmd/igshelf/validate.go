@@ -0,0 +1,40 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// validateConfig checks the resolved flags before any work starts,
+// so a missing destination or token fails fast instead of deep inside
+// pagination or file I/O.
+func validateConfig(sources []string, destination, accessToken string) error {
+	if destination == "" {
+		return errors.New("-dst is required")
+	}
+	if len(sources) == 0 {
+		return nil
+	}
+
+	for _, source := range sources {
+		if source == "api" {
+			if len(sources) > 1 {
+				return errors.New(`-src=api can't be combined with other sources`)
+			}
+			if accessToken == "" {
+				return errors.New("-token or -token-file is required when -src=api")
+			}
+			continue
+		}
+		if strings.HasSuffix(source, ".zip") {
+			if _, err := os.Stat(source); err != nil {
+				return fmt.Errorf("archive %s is not accessible: %w", source, err)
+			}
+			continue
+		}
+		return fmt.Errorf(`invalid -src %q: must be "api" or a path to a zip archive`, source)
+	}
+	return nil
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestValidateConfig(t *testing.T) {
+	archive := filepath.Join("testdata", "archive.zip")
+
+	tt := map[string]struct {
+		sources     []string
+		destination string
+		accessToken string
+		wantErr     bool
+	}{
+		"missing dst": {
+			sources:     []string{archive},
+			destination: "",
+			wantErr:     true,
+		},
+		"api without token": {
+			sources:     []string{"api"},
+			destination: "gallery",
+			accessToken: "",
+			wantErr:     true,
+		},
+		"api with token": {
+			sources:     []string{"api"},
+			destination: "gallery",
+			accessToken: "IGQVJ...",
+			wantErr:     false,
+		},
+		"missing archive": {
+			sources:     []string{"missing.zip"},
+			destination: "gallery",
+			wantErr:     true,
+		},
+		"existing archive": {
+			sources:     []string{archive},
+			destination: "gallery",
+			wantErr:     false,
+		},
+		"multiple archives": {
+			sources:     []string{archive, archive},
+			destination: "gallery",
+			wantErr:     false,
+		},
+		"api combined with archive": {
+			sources:     []string{"api", archive},
+			destination: "gallery",
+			accessToken: "IGQVJ...",
+			wantErr:     true,
+		},
+		"invalid source": {
+			sources:     []string{"not-a-zip"},
+			destination: "gallery",
+			wantErr:     true,
+		},
+	}
+	for name, tc := range tt {
+		t.Run(name, func(t *testing.T) {
+			err := validateConfig(tc.sources, tc.destination, tc.accessToken)
+			if (err != nil) != tc.wantErr {
+				t.Errorf("got err %v, wantErr %v", err, tc.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,57 @@
+package igshelf
+
+import (
+	"strings"
+	"time"
+)
+
+// Filter narrows a timeline by publish date, media type, and caption text.
+// A zero Filter matches everything. It's shared by the CLI and serve mode
+// so both filter a timeline the same way.
+type Filter struct {
+	// Since matches media taken on or after this time. Zero means no lower bound.
+	Since time.Time
+	// Until matches media taken on or before this time. Zero means no upper bound.
+	Until time.Time
+	// Types restricts media to these types (e.g., MediaTypeImage). Empty means any type.
+	Types []string
+	// CaptionContains matches media whose Caption contains this text,
+	// case-insensitively. Empty means no caption filtering.
+	CaptionContains string
+}
+
+// Apply returns the media in timeline that match f, preserving order.
+func (f Filter) Apply(timeline []*Media) []*Media {
+	var out []*Media
+	for _, m := range timeline {
+		if f.matches(m) {
+			out = append(out, m)
+		}
+	}
+	return out
+}
+
+func (f Filter) matches(m *Media) bool {
+	if !f.Since.IsZero() && m.TakenAt.Before(f.Since) {
+		return false
+	}
+	if !f.Until.IsZero() && m.TakenAt.After(f.Until) {
+		return false
+	}
+	if len(f.Types) > 0 && !containsType(f.Types, m.Type) {
+		return false
+	}
+	if f.CaptionContains != "" && !strings.Contains(strings.ToLower(m.Caption), strings.ToLower(f.CaptionContains)) {
+		return false
+	}
+	return true
+}
+
+func containsType(types []string, t string) bool {
+	for _, v := range types {
+		if v == t {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,86 @@
+package igshelf
+
+import (
+	"testing"
+	"time"
+)
+
+func TestFilterApply(t *testing.T) {
+	timeline := []*Media{
+		{
+			ID:      "1",
+			Type:    MediaTypeImage,
+			Caption: "Starting another two-wheeled hobby.\n\nЯ буду долго гнать велосипед.",
+			TakenAt: time.Date(2019, time.January, 1, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			ID:      "2",
+			Type:    MediaTypeVideo,
+			Caption: "Still jumping",
+			TakenAt: time.Date(2020, time.October, 7, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			ID:      "3",
+			Type:    MediaTypeImage,
+			Caption: "Sunset walk",
+			TakenAt: time.Date(2020, time.October, 8, 0, 0, 0, 0, time.UTC),
+		},
+	}
+
+	tt := map[string]struct {
+		f    Filter
+		want []string
+	}{
+		"no filter matches everything": {
+			f:    Filter{},
+			want: []string{"1", "2", "3"},
+		},
+		"type": {
+			f:    Filter{Types: []string{MediaTypeImage}},
+			want: []string{"1", "3"},
+		},
+		"since and until": {
+			f: Filter{
+				Since: time.Date(2020, time.October, 1, 0, 0, 0, 0, time.UTC),
+				Until: time.Date(2020, time.October, 7, 23, 59, 59, 0, time.UTC),
+			},
+			want: []string{"2"},
+		},
+		"caption contains, case-insensitive": {
+			f:    Filter{CaptionContains: "SUNSET"},
+			want: []string{"3"},
+		},
+		"caption contains cyrillic": {
+			f:    Filter{CaptionContains: "велосипед"},
+			want: []string{"1"},
+		},
+		"combined type and date and substring": {
+			f: Filter{
+				Types:           []string{MediaTypeImage},
+				Since:           time.Date(2020, time.January, 1, 0, 0, 0, 0, time.UTC),
+				CaptionContains: "sunset",
+			},
+			want: []string{"3"},
+		},
+	}
+
+	for name, tc := range tt {
+		t.Run(name, func(t *testing.T) {
+			got := tc.f.Apply(timeline)
+
+			var gotIDs []string
+			for _, m := range got {
+				gotIDs = append(gotIDs, m.ID)
+			}
+			if len(gotIDs) != len(tc.want) {
+				t.Fatalf("got %v, want %v", gotIDs, tc.want)
+			}
+			for i, id := range gotIDs {
+				if id != tc.want[i] {
+					t.Errorf("got %v, want %v", gotIDs, tc.want)
+					break
+				}
+			}
+		})
+	}
+}
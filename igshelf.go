@@ -4,7 +4,12 @@ package igshelf
 
 import (
 	"context"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
 	"time"
+	"unicode"
 )
 
 const (
@@ -14,6 +19,11 @@ const (
 	MediaTypeVideo = "VIDEO"
 	// MediaTypeAlbum indicates the media is a carousel album (images and videos published together).
 	MediaTypeAlbum = "CAROUSEL_ALBUM"
+
+	// ProductTypeStory marks media recovered from an Instagram archive's
+	// ephemeral stories, as opposed to a permanent feed post. See
+	// Media.ProductType.
+	ProductTypeStory = "STORY"
 )
 
 // Media represents an image, video, or album.
@@ -44,17 +54,181 @@ type Media struct {
 	Permalink string
 	// TakenAt is the media's publish date.
 	TakenAt time.Time
+	// Checksum is a SHA-256 hex digest of the downloaded media content.
+	// It lets a subsequent run tell a corrupted or truncated local file
+	// from one that was fully downloaded, instead of trusting filename presence alone.
+	Checksum string
 	// Children is a list of media that belong to this media album (CAROUSEL_ALBUM media type).
 	// Note, archive doesn't have a notion of album, so igshelf groups photos/videos in albums by their date and caption.
 	Children []*Media
+	// Unavailable marks media whose content can't be fetched, e.g. an
+	// album child Instagram flagged for copyright and omitted media_url
+	// from. A downloader should skip it rather than attempt a GET on an
+	// empty Location.
+	Unavailable bool
+	// ArchiveIndex is this media's position within an Instagram archive's
+	// media.json, used to break ties when two entries share a TakenAt.
+	// It's zero (and not meaningful) for media that didn't come from an
+	// archive.
+	ArchiveIndex int
+	// ProductType distinguishes ephemeral content from a permanent feed
+	// post, e.g. ProductTypeStory for media recovered from an Instagram
+	// archive's stories/ directory. It's empty for an ordinary post.
+	ProductType string
+	// Available reports whether this media's Filename was found on disk
+	// the last time MarkAvailable ran, e.g. a download that failed or was
+	// interrupted leaves it false so a template can render a placeholder
+	// and the Permalink instead of a broken image or video. It's false
+	// until MarkAvailable is called, so a caller that doesn't render
+	// content availability can simply ignore it. Unlike Unavailable, this
+	// isn't sourced from Instagram; it reflects the local filesystem.
+	Available bool
+	// Likes is the media's like count, populated only when the Instagram
+	// service was asked for insights, e.g. instagram.WithInsights, and the
+	// account supports them (business/creator, not personal). It's zero
+	// when unpopulated.
+	Likes int
+	// Comments is the media's comment count, see Likes.
+	Comments int
+}
+
+// ContentPath returns the media's path relative to a gallery's root
+// (e.g. content/17841752650018177.mp4), given dir, the gallery-relative
+// name (or path) of the content directory a template links to. It returns
+// an empty string if the media has no Filename.
+func (m *Media) ContentPath(dir string) string {
+	if m.Filename == "" {
+		return ""
+	}
+	return path.Join(dir, m.Filename)
+}
+
+// ThumbnailPath returns the media's thumbnail path relative to a
+// gallery's root, given dir, the gallery-relative name (or path) of the
+// content directory a template links to. It returns an empty string if
+// the media has no thumbnail.
+func (m *Media) ThumbnailPath(dir string) string {
+	if m.ThumbnailFilename == "" {
+		return ""
+	}
+	return path.Join(dir, m.ThumbnailFilename)
+}
+
+// ChildCount returns the number of album children, or 0 for non-album media,
+// so a template can render a "1/5" style indicator without reaching into
+// Children itself.
+func (m *Media) ChildCount() int {
+	return len(m.Children)
+}
+
+// IsVideo reports whether the media is a video, so a template can decide
+// whether to render a video element (with its poster) instead of an image
+// without comparing Type strings itself.
+func (m *Media) IsVideo() bool {
+	return m.Type == MediaTypeVideo
+}
+
+// Cover returns the media a template should show as an album's thumbnail:
+// its first child, or m itself if it has no children (e.g. non-album media,
+// or an empty album). It never returns nil for a non-nil m.
+func (m *Media) Cover() *Media {
+	if len(m.Children) == 0 {
+		return m
+	}
+	return m.Children[0]
+}
+
+// CountMedia counts the media a downloader will actually attempt to fetch:
+// top-level media plus album children, each counted only if it has a
+// Filename (media without one, e.g. an empty album, has no file to copy).
+// It's meant for the total in progress reporting, since children are
+// flattened into individual downloads at download time.
+func CountMedia(timeline []*Media) int {
+	var n int
+	for _, m := range timeline {
+		if m.Filename != "" {
+			n++
+		}
+		for _, c := range m.Children {
+			if c.Filename != "" {
+				n++
+			}
+		}
+	}
+	return n
+}
+
+// ValidateUnique reports IDs that appear more than once in timeline,
+// counting album children alongside top-level media. Since ID determines
+// where a media file is written on disk, a duplicate would cause one
+// download to silently overwrite another.
+func ValidateUnique(timeline []*Media) []string {
+	seen := make(map[string]int, len(timeline))
+	for _, m := range timeline {
+		seen[m.ID]++
+		for _, c := range m.Children {
+			seen[c.ID]++
+		}
+	}
+
+	var dupes []string
+	for id, n := range seen {
+		if n > 1 {
+			dupes = append(dupes, id)
+		}
+	}
+	return dupes
+}
+
+// MarkAvailable walks timeline, including album Children, and sets each
+// media's Available to whether its Filename exists under contentDir.
+// Media without a Filename (e.g. an empty album) is left unavailable,
+// since it has no content to render either way. Call it after Download,
+// right before rendering a gallery, so a file missing or corrupted by an
+// interrupted or partially failed run gets a placeholder instead of a
+// broken image or video link.
+func MarkAvailable(timeline []*Media, contentDir string) {
+	for _, m := range timeline {
+		if m.Filename != "" {
+			_, err := os.Stat(filepath.Join(contentDir, m.Filename))
+			m.Available = err == nil
+		}
+		MarkAvailable(m.Children, contentDir)
+	}
+}
+
+// SanitizeCaption strips control characters (other than newline and tab)
+// from a caption, e.g. stray null bytes some Instagram archives include,
+// which would otherwise break HTML rendering or round-trip oddly through
+// JSON. Emoji and non-Latin text are left untouched, since they aren't
+// control characters.
+func SanitizeCaption(s string) string {
+	return strings.Map(func(r rune) rune {
+		if r == '\n' || r == '\t' {
+			return r
+		}
+		if unicode.IsControl(r) {
+			return -1
+		}
+		return r
+	}, s)
 }
 
 // MediaService provides access to Instagram timeline so one can get a copy of own content.
 type MediaService interface {
 	// List returns an iterator that yields media in reverse chronological order (newest first).
-	List(ctx context.Context) (iter MediaIter)
+	// When since is non-zero, only media taken after it is yielded, which lets
+	// a caller fetch just what's new since a prior run.
+	List(ctx context.Context, since time.Time) (iter MediaIter)
 	// Download copies the media file and video thumbnail from their location.
+	// A nil content with a nil error means the content is unchanged from a
+	// prior Download (e.g. a conditional request got a 304), and the caller
+	// should keep whatever's already stored locally instead of overwriting it.
 	Download(ctx context.Context, m *Media) (content, thumbnail []byte, err error)
+	// Get fetches a single media (or album child) by ID, e.g. to
+	// re-resolve an expired URL or re-download one item without pulling
+	// the whole timeline.
+	Get(ctx context.Context, id string) (*Media, error)
 }
 
 // MediaIter is an iterator which yields media in reverse chronological order (newest first).
@@ -70,6 +244,31 @@ type MediaIter interface {
 	Err() error
 }
 
+// Iterate adapts a MediaIter to the shape of the standard library's
+// iter.Seq2[*Media, error]: func(yield func(*Media, error) bool). yield is
+// called once per media, then once more with a nil media and the
+// iterator's error (if any) once it's exhausted; it stops early if yield
+// returns false.
+//
+// This module targets an older Go release than the one range-over-func
+// shipped in, so the result can't be spelled as iter.Seq2 or consumed with
+// a plain "for range" yet. Once the go directive reaches 1.23, this
+// signature can be retyped to iter.Seq2[*Media, error] with no change to
+// callers that already invoke it manually, and
+// for m, err := range Iterate(it) { ... } becomes available.
+func Iterate(it MediaIter) func(yield func(*Media, error) bool) {
+	return func(yield func(*Media, error) bool) {
+		for it.Next() {
+			if !yield(it.Media(), nil) {
+				return
+			}
+		}
+		if err := it.Err(); err != nil {
+			yield(nil, err)
+		}
+	}
+}
+
 // MediaRepository is used to store Instagram timeline with assumption that
 // a user doesn't have a lot of content (timeline is loaded and stored all at once).
 type MediaRepository interface {
@@ -78,3 +277,18 @@ type MediaRepository interface {
 	// Store persists the media timeline, e.g., as a JSON file.
 	Store(timeline []*Media) error
 }
+
+// NoopRepository is a MediaRepository that discards Store and always
+// returns an empty timeline from List, for a downloader.Service run that
+// should only copy media files without writing an index alongside them.
+type NoopRepository struct{}
+
+// List always returns an empty timeline.
+func (NoopRepository) List() ([]*Media, error) {
+	return nil, nil
+}
+
+// Store discards timeline.
+func (NoopRepository) Store(timeline []*Media) error {
+	return nil
+}
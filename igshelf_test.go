@@ -0,0 +1,326 @@
+package igshelf
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestCountMedia(t *testing.T) {
+	timeline := []*Media{
+		{ID: "1", Filename: "1.jpg"},
+		{
+			ID:       "2",
+			Filename: "2.jpg",
+			Children: []*Media{
+				{ID: "2a", Filename: "2a.jpg"},
+				{ID: "2b", Filename: "2b.mp4"},
+			},
+		},
+		// An album with no filename of its own (archive doesn't have one),
+		// but whose children still count.
+		{
+			ID: "3",
+			Children: []*Media{
+				{ID: "3a", Filename: "3a.jpg"},
+			},
+		},
+	}
+
+	if got, want := CountMedia(timeline), 5; got != want {
+		t.Errorf("got %d, want %d", got, want)
+	}
+}
+
+func TestMediaContentPath(t *testing.T) {
+	cases := map[string]struct {
+		m    Media
+		dir  string
+		want string
+	}{
+		"with filename": {
+			m:    Media{Filename: "17863188140095492.mp4"},
+			dir:  "content",
+			want: "content/17863188140095492.mp4",
+		},
+		"custom dir": {
+			m:    Media{Filename: "17863188140095492.mp4"},
+			dir:  "media",
+			want: "media/17863188140095492.mp4",
+		},
+		"no filename": {
+			m:    Media{},
+			dir:  "content",
+			want: "",
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			if got := tc.m.ContentPath(tc.dir); got != tc.want {
+				t.Errorf("got %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestMediaThumbnailPath(t *testing.T) {
+	cases := map[string]struct {
+		m    Media
+		dir  string
+		want string
+	}{
+		"with thumbnail": {
+			m:    Media{ThumbnailFilename: "17863188140095492_cover.jpg"},
+			dir:  "content",
+			want: "content/17863188140095492_cover.jpg",
+		},
+		"custom dir": {
+			m:    Media{ThumbnailFilename: "17863188140095492_cover.jpg"},
+			dir:  "media",
+			want: "media/17863188140095492_cover.jpg",
+		},
+		"without thumbnail": {
+			m:    Media{},
+			dir:  "content",
+			want: "",
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			if got := tc.m.ThumbnailPath(tc.dir); got != tc.want {
+				t.Errorf("got %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestMediaChildCount(t *testing.T) {
+	cases := map[string]struct {
+		m    Media
+		want int
+	}{
+		"album": {
+			m:    Media{Type: MediaTypeAlbum, Children: []*Media{{ID: "1"}, {ID: "2"}}},
+			want: 2,
+		},
+		"empty album": {
+			m:    Media{Type: MediaTypeAlbum},
+			want: 0,
+		},
+		"non-album": {
+			m:    Media{Type: MediaTypeImage},
+			want: 0,
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			if got := tc.m.ChildCount(); got != tc.want {
+				t.Errorf("got %d, want %d", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestMediaCover(t *testing.T) {
+	child := &Media{ID: "1a"}
+	album := Media{ID: "1", Type: MediaTypeAlbum, Children: []*Media{child, {ID: "1b"}}}
+	emptyAlbum := Media{ID: "2", Type: MediaTypeAlbum}
+	image := Media{ID: "3", Type: MediaTypeImage}
+
+	if got := album.Cover(); got != child {
+		t.Errorf("got cover %v, want the first child %v", got, child)
+	}
+	if got := emptyAlbum.Cover(); got != &emptyAlbum {
+		t.Errorf("got cover %v, want the empty album itself", got)
+	}
+	if got := image.Cover(); got != &image {
+		t.Errorf("got cover %v, want the non-album media itself", got)
+	}
+}
+
+// TestSanitizeCaption checks that control characters other than newline
+// and tab are stripped, while emoji and non-Latin text survive untouched.
+func TestSanitizeCaption(t *testing.T) {
+	cases := map[string]struct {
+		caption string
+		want    string
+	}{
+		"null byte":       {caption: "Still jumping\x00", want: "Still jumping"},
+		"control chars":   {caption: "one\x01two\x1fthree", want: "onetwothree"},
+		"newline and tab": {caption: "line one\nline\ttwo", want: "line one\nline\ttwo"},
+		"emoji and cyrillic": {
+			caption: "Я буду долго гнать велосипед. 🚲",
+			want:    "Я буду долго гнать велосипед. 🚲",
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			if got := SanitizeCaption(tc.caption); got != tc.want {
+				t.Errorf("got %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestNoopRepository(t *testing.T) {
+	var r NoopRepository
+
+	if err := r.Store([]*Media{{ID: "1"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	timeline, err := r.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(timeline) != 0 {
+		t.Errorf("got %v, want an empty timeline", timeline)
+	}
+}
+
+func TestValidateUnique(t *testing.T) {
+	timeline := []*Media{
+		{ID: "1"},
+		{
+			ID: "2",
+			Children: []*Media{
+				{ID: "1"},
+				{ID: "2a"},
+			},
+		},
+		{ID: "2"},
+	}
+
+	got := ValidateUnique(timeline)
+	sort.Strings(got)
+
+	want := []string{"1", "2"}
+	if len(got) != len(want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("got %v, want %v", got, want)
+		}
+	}
+}
+
+// TestMarkAvailable checks that MarkAvailable flags a media whose Filename
+// exists under contentDir as available, one whose file is missing (e.g. an
+// interrupted download) as unavailable, and reaches into album Children,
+// while leaving media without a Filename (e.g. an empty album) unavailable
+// since it has nothing to render either way.
+func TestMarkAvailable(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "present.jpg"), []byte("x"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	timeline := []*Media{
+		{ID: "1", Filename: "present.jpg"},
+		{ID: "2", Filename: "absent.jpg"},
+		{ID: "3"},
+		{
+			ID: "4",
+			Children: []*Media{
+				{ID: "4a", Filename: "present.jpg"},
+				{ID: "4b", Filename: "absent.jpg"},
+			},
+		},
+	}
+	MarkAvailable(timeline, dir)
+
+	want := map[string]bool{
+		"1": true, "2": false, "3": false,
+		"4": false, "4a": true, "4b": false,
+	}
+	got := make(map[string]bool, len(want))
+	for _, m := range timeline {
+		got[m.ID] = m.Available
+		for _, c := range m.Children {
+			got[c.ID] = c.Available
+		}
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("availability mismatch (-want +got):\n%s", diff)
+	}
+}
+
+// fakeMediaIter is a minimal MediaIter, standing in for a mock media
+// service's iterator in tests below.
+type fakeMediaIter struct {
+	batch   []*Media
+	err     error
+	cursor  int
+	current *Media
+}
+
+func (it *fakeMediaIter) Next() bool {
+	if it.cursor >= len(it.batch) {
+		return false
+	}
+	it.current = it.batch[it.cursor]
+	it.cursor++
+	return true
+}
+func (it *fakeMediaIter) Media() *Media { return it.current }
+func (it *fakeMediaIter) Err() error    { return it.err }
+
+// TestIterate checks that Iterate's yield callback sees the same media, in
+// the same order, that a manual Next/Media loop over the same iterator
+// would, followed by a final call carrying the iterator's error.
+func TestIterate(t *testing.T) {
+	batch := []*Media{
+		{ID: "1"},
+		{ID: "2"},
+		{ID: "3"},
+	}
+	wantErr := errors.New("boom")
+
+	it := &fakeMediaIter{batch: batch, err: wantErr}
+
+	var (
+		got     []*Media
+		gotErrs []error
+	)
+	Iterate(it)(func(m *Media, err error) bool {
+		got = append(got, m)
+		gotErrs = append(gotErrs, err)
+		return true
+	})
+
+	wantMedia := append(append([]*Media{}, batch...), nil)
+	if diff := cmp.Diff(wantMedia, got); diff != "" {
+		t.Errorf("media mismatch (-want +got):\n%s", diff)
+	}
+
+	want := []error{nil, nil, nil, wantErr}
+	if diff := cmp.Diff(want, gotErrs, cmp.Comparer(func(a, b error) bool { return a == b })); diff != "" {
+		t.Errorf("errors mismatch (-want +got):\n%s", diff)
+	}
+}
+
+// TestIterateStopsEarly checks that yield returning false stops Iterate
+// from calling Next again, mirroring "break" in a manual loop.
+func TestIterateStopsEarly(t *testing.T) {
+	it := &fakeMediaIter{batch: []*Media{{ID: "1"}, {ID: "2"}, {ID: "3"}}}
+
+	var got []*Media
+	Iterate(it)(func(m *Media, err error) bool {
+		got = append(got, m)
+		return len(got) < 2
+	})
+
+	want := []*Media{{ID: "1"}, {ID: "2"}}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("media mismatch (-want +got):\n%s", diff)
+	}
+}
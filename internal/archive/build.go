@@ -0,0 +1,122 @@
+package archive
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"path/filepath"
+	"strings"
+
+	"github.com/marselester/igshelf"
+)
+
+// rawMediaAt returns the raw media and its type at position i of the
+// flattened (photos then videos) nomenclature.
+func rawMediaAt(nom nomenclature, i int) (raw *media, mediaType string) {
+	if i < len(nom.Photos) {
+		return nom.Photos[i], igshelf.MediaTypeImage
+	}
+	return nom.Videos[i-len(nom.Photos)], igshelf.MediaTypeVideo
+}
+
+// defaultFilenamePrefixLayout is the time.Format layout prepended to a
+// filename unless overridden with WithFilenamePrefix.
+const defaultFilenamePrefixLayout = "200601_"
+
+// buildMedia maps a raw archive media entry to an igshelf.Media, assigning
+// the filename (and thumbnail filename for videos) it should be given
+// after extracting it from the archive. prefixLayout is a time.Format
+// layout prepended to the filename to help explore files (empty disables
+// the prefix), see WithFilenamePrefix. index is the entry's position in
+// the flattened (photos then videos) nomenclature, i.e. its order in
+// media.json, recorded as ArchiveIndex to break TakenAt ties
+// deterministically.
+func buildMedia(raw *media, mediaType string, index int, prefixLayout string) *igshelf.Media {
+	loc := normalizePath(raw.path())
+	m := igshelf.Media{
+		Caption:      igshelf.SanitizeCaption(raw.Caption),
+		Type:         mediaType,
+		Location:     loc,
+		TakenAt:      raw.TakenAt,
+		ArchiveIndex: index,
+	}
+	_, fname := filepath.Split(loc)
+	m.ID = fname[:len(fname)-len(filepath.Ext(fname))]
+	prefix := ""
+	if prefixLayout != "" {
+		prefix = m.TakenAt.Format(prefixLayout)
+	}
+	m.Filename = prefix + fname
+	if mediaType == igshelf.MediaTypeVideo {
+		m.ThumbnailFilename = prefix + m.ID + "_cover.jpg"
+	}
+	return &m
+}
+
+// entryFilename computes the local filename buildMedia would assign to
+// raw, without building a full igshelf.Media, so a duplicate-filename scan
+// doesn't need one for every entry just to compare names.
+func entryFilename(raw *media, prefixLayout string) string {
+	_, fname := filepath.Split(normalizePath(raw.path()))
+	prefix := ""
+	if prefixLayout != "" {
+		prefix = raw.TakenAt.Format(prefixLayout)
+	}
+	return prefix + fname
+}
+
+// disambiguateFilenames scans every raw entry's computed Filename for
+// duplicates, e.g. two archived photos from different months that happen
+// to share the same base name, and returns a replacement Filename for
+// each colliding entry, keyed by its index in the flattened (photos then
+// videos) nomenclature. Entries with a unique Filename aren't present in
+// the returned map. Without this, two different files would compute the
+// same output Filename and one would silently overwrite the other on disk.
+func disambiguateFilenames(nom nomenclature, prefixLayout string) map[int]string {
+	total := len(nom.Photos) + len(nom.Videos)
+	seen := make(map[string][]int, total)
+	for i := 0; i < total; i++ {
+		raw, _ := rawMediaAt(nom, i)
+		name := entryFilename(raw, prefixLayout)
+		seen[name] = append(seen[name], i)
+	}
+
+	overrides := make(map[int]string)
+	for name, idxs := range seen {
+		if len(idxs) < 2 {
+			continue
+		}
+		for _, i := range idxs {
+			raw, _ := rawMediaAt(nom, i)
+			overrides[i] = disambiguatedFilename(name, raw.path())
+		}
+	}
+	return overrides
+}
+
+// disambiguatedFilename appends an 8 hex character hash of path (the
+// entry's original archive path, unique even when two entries share a
+// base name) to filename, just before its extension, e.g.
+// "202010_abc.jpg" becomes "202010_abc_1a2b3c4d.jpg".
+func disambiguatedFilename(filename, path string) string {
+	sum := sha256.Sum256([]byte(path))
+	suffix := hex.EncodeToString(sum[:])[:8]
+
+	ext := filepath.Ext(filename)
+	return filename[:len(filename)-len(ext)] + "_" + suffix + ext
+}
+
+// buildStory maps a raw stories.json entry to an igshelf.Media tagged with
+// igshelf.ProductTypeStory, reusing buildMedia for filename and ID
+// derivation. Unlike media.json, stories.json doesn't separate entries
+// into photos and videos, so the type is inferred from the file extension
+// instead.
+func buildStory(raw *media, index int, prefixLayout string) *igshelf.Media {
+	mediaType := igshelf.MediaTypeImage
+	if strings.HasSuffix(strings.ToLower(raw.path()), ".mp4") {
+		mediaType = igshelf.MediaTypeVideo
+	}
+
+	m := buildMedia(raw, mediaType, index, prefixLayout)
+	m.ProductType = igshelf.ProductTypeStory
+	return m
+}
@@ -0,0 +1,169 @@
+package archive
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/marselester/igshelf"
+)
+
+func TestBuildMedia(t *testing.T) {
+	nom := syntheticNomenclature(3)
+
+	for i := range nom.Photos {
+		raw, mediaType := rawMediaAt(nom, i)
+		m := buildMedia(raw, mediaType, i, defaultFilenamePrefixLayout)
+		if got, want := m.Type, igshelf.MediaTypeImage; got != want {
+			t.Errorf("photo %d: got type %q, want %q", i, got, want)
+		}
+		if m.Caption != nom.Photos[i].Caption || !m.TakenAt.Equal(nom.Photos[i].TakenAt) {
+			t.Errorf("photo %d: got %+v, want caption/date from %+v", i, m, nom.Photos[i])
+		}
+	}
+	for i := range nom.Videos {
+		raw, mediaType := rawMediaAt(nom, len(nom.Photos)+i)
+		m := buildMedia(raw, mediaType, len(nom.Photos)+i, defaultFilenamePrefixLayout)
+		if got, want := m.Type, igshelf.MediaTypeVideo; got != want {
+			t.Errorf("video %d: got type %q, want %q", i, got, want)
+		}
+		if m.ThumbnailFilename == "" {
+			t.Errorf("video %d: expected a thumbnail filename", i)
+		}
+		if m.Caption != raw.Caption || !m.TakenAt.Equal(raw.TakenAt) {
+			t.Errorf("video %d: got %+v, want caption/date from %+v", i, m, raw)
+		}
+	}
+}
+
+// TestBuildMediaSanitizesCaption checks that a caption with embedded
+// control bytes has them stripped while legitimate text is preserved.
+func TestBuildMediaSanitizesCaption(t *testing.T) {
+	raw := &media{
+		Caption: "Still jumping\x00\x07",
+		TakenAt: time.Date(2020, time.October, 7, 0, 0, 0, 0, time.UTC),
+		Path:    "photos/202010/0.jpg",
+	}
+	m := buildMedia(raw, igshelf.MediaTypeImage, 0, defaultFilenamePrefixLayout)
+	if got, want := m.Caption, "Still jumping"; got != want {
+		t.Errorf("got caption %q, want %q", got, want)
+	}
+}
+
+// TestBuildMediaFilenamePrefix checks that buildMedia uses a custom
+// time.Format layout for the filename prefix, and that an empty layout
+// disables the prefix entirely.
+func TestBuildMediaFilenamePrefix(t *testing.T) {
+	raw := &media{
+		TakenAt: time.Date(2020, time.October, 7, 0, 0, 0, 0, time.UTC),
+		Path:    "photos/202010/0.jpg",
+	}
+
+	cases := map[string]struct {
+		layout string
+		want   string
+	}{
+		"custom layout": {layout: "2006-01-02_", want: "2020-10-07_0.jpg"},
+		"no prefix":     {layout: "", want: "0.jpg"},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			m := buildMedia(raw, igshelf.MediaTypeImage, 0, tc.layout)
+			if got := m.Filename; got != tc.want {
+				t.Errorf("got filename %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+// TestSortedOrderTiebreak checks that entries sharing a TakenAt are ordered
+// by their original position in media.json (photos then videos), not left
+// to sort.Slice's arbitrary tie handling.
+func TestSortedOrderTiebreak(t *testing.T) {
+	takenAt := time.Date(2020, time.October, 7, 0, 0, 0, 0, time.UTC)
+	nom := nomenclature{
+		Photos: []*media{
+			{Caption: "photo 0", TakenAt: takenAt, Path: "photos/202010/0.jpg"},
+			{Caption: "photo 1", TakenAt: takenAt, Path: "photos/202010/1.jpg"},
+		},
+		Videos: []*media{
+			{Caption: "video 0", TakenAt: takenAt, Path: "videos/202010/0.mp4"},
+		},
+	}
+
+	got := sortedOrder(nom)
+	want := []int{0, 1, 2}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf(diff)
+	}
+}
+
+// TestDisambiguateFilenames checks that two entries whose computed
+// Filename collides (here, two photos from different months named
+// "0.jpg") are both given distinct, stable replacement filenames, while an
+// entry with a unique Filename is left out of the result entirely.
+func TestDisambiguateFilenames(t *testing.T) {
+	nom := nomenclature{
+		Photos: []*media{
+			{Path: "photos/202009/0.jpg"},
+			{Path: "photos/202010/0.jpg"},
+			{Path: "photos/202010/1.jpg"},
+		},
+	}
+
+	got := disambiguateFilenames(nom, "")
+	if _, ok := got[2]; ok {
+		t.Errorf("got an override for the unique entry: %v", got)
+	}
+	if len(got) != 2 {
+		t.Fatalf("got %d overrides, want 2: %v", len(got), got)
+	}
+	if got[0] == got[1] {
+		t.Errorf("got the same override for both colliding entries: %q", got[0])
+	}
+	for i, name := range got {
+		if name == "0.jpg" {
+			t.Errorf("entry %d: override %q wasn't disambiguated", i, name)
+		}
+	}
+}
+
+// TestDisambiguateFilenamesDeterministic checks that disambiguatedFilename
+// derives its suffix from path, so the same archive disambiguates the same
+// way across runs instead of depending on map iteration order.
+func TestDisambiguateFilenamesDeterministic(t *testing.T) {
+	got := disambiguatedFilename("0.jpg", "photos/202010/0.jpg")
+	want := disambiguatedFilename("0.jpg", "photos/202010/0.jpg")
+	if got != want {
+		t.Errorf("got %q and %q for the same path, want them equal", got, want)
+	}
+	if got == "0.jpg" {
+		t.Errorf("got %q, want a disambiguated filename", got)
+	}
+}
+
+// syntheticNomenclature builds a nomenclature with n photos and n videos,
+// used to exercise buildMedia without a real zip archive.
+func syntheticNomenclature(n int) nomenclature {
+	nom := nomenclature{
+		Photos: make([]*media, n),
+		Videos: make([]*media, n),
+	}
+	base := time.Date(2020, time.October, 7, 0, 0, 0, 0, time.UTC)
+	for i := 0; i < n; i++ {
+		nom.Photos[i] = &media{
+			Caption: fmt.Sprintf("photo %d", i),
+			TakenAt: base.Add(time.Duration(i) * time.Minute),
+			Path:    fmt.Sprintf("photos/202010/%d.jpg", i),
+		}
+		nom.Videos[i] = &media{
+			Caption: fmt.Sprintf("video %d", i),
+			TakenAt: base.Add(time.Duration(i) * time.Minute),
+			Path:    fmt.Sprintf("videos/202010/%d.mp4", i),
+		}
+	}
+	return nom
+}
@@ -0,0 +1,87 @@
+package archive
+
+// ConfigOption configures the archive media service.
+type ConfigOption func(*MediaService)
+
+// WithThumbnailer sets a thumbnailer that generates video cover images,
+// since zip archives don't include them.
+func WithThumbnailer(t Thumbnailer) ConfigOption {
+	return func(s *MediaService) {
+		s.thumbnailer = t
+	}
+}
+
+// WithGroupFunc overrides the predicate used to group adjacent media into
+// carousel albums. It defaults to GroupByCaptionAndTimestamp; use
+// GroupByTimestamp for exports where album children carry distinct
+// per-child captions under a shared timestamp.
+func WithGroupFunc(fn GroupFunc) ConfigOption {
+	return func(s *MediaService) {
+		s.groupFunc = fn
+	}
+}
+
+// WithExtensions broadens the table of contents to include every file
+// whose name ends in one of exts (e.g. ".jpg", ".png"), instead of just the
+// ".jpg" and ".mp4" media files indexed by default. This is useful for
+// tools built on top of MediaService that need to see other files bundled
+// in the archive, like profile pictures or stories, via Files.
+func WithExtensions(exts ...string) ConfigOption {
+	return func(s *MediaService) {
+		s.extensions = exts
+	}
+}
+
+// WithFilenamePrefix overrides the time.Format layout prepended to
+// assigned filenames, e.g. "2006-01-02_" for a full date instead of the
+// default "200601_" year/month prefix. An empty layout disables the
+// prefix entirely, so a file keeps its original archive name.
+func WithFilenamePrefix(layout string) ConfigOption {
+	return func(s *MediaService) {
+		s.filenamePrefixLayout = layout
+	}
+}
+
+// WithStories opts into parsing an archive's stories.json (see Stories),
+// tagging its entries with igshelf.ProductTypeStory. It defaults to false,
+// since not every export includes stories and decoding it is an extra
+// pass a caller who only wants the main timeline doesn't need.
+func WithStories(enabled bool) ConfigOption {
+	return func(s *MediaService) {
+		s.stories = enabled
+	}
+}
+
+// WithChecksums makes List precompute each media's SHA-256 Checksum by
+// reading and hashing its zip entry with a small worker pool, instead of
+// leaving it for a downloader to hash after copying the file. Since an
+// archive's files are already local, this front-loads the read/hash work
+// and lets a caller dedup by Checksum before a single byte is downloaded.
+// It defaults to false, since hashing every file is wasted work for a
+// caller that doesn't need pre-download dedup. See WithChecksumWorkers to
+// control how much of it happens concurrently.
+func WithChecksums(enabled bool) ConfigOption {
+	return func(s *MediaService) {
+		s.checksums = enabled
+	}
+}
+
+// WithChecksumWorkers overrides how many zip entries are read and hashed
+// concurrently when WithChecksums is enabled. It defaults to 4.
+func WithChecksumWorkers(n int) ConfigOption {
+	return func(s *MediaService) {
+		s.checksumWorkers = n
+	}
+}
+
+// WithAlbums toggles grouping media into carousel albums. It defaults to
+// true; pass false to make MediaIter yield every media individually, since
+// an archive's true album boundaries can't be recovered from timestamps and
+// captions alone and some users would rather not risk a wrong grouping.
+func WithAlbums(enabled bool) ConfigOption {
+	return func(s *MediaService) {
+		if !enabled {
+			s.groupFunc = GroupNone
+		}
+	}
+}
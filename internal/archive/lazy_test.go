@@ -0,0 +1,108 @@
+package archive
+
+import (
+	"context"
+	"encoding/json"
+	"path/filepath"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/marselester/igshelf"
+)
+
+// TestListLazyMatchesEagerBuild asserts that List's lazily-built iterator
+// yields exactly the same media as building the full timeline eagerly up
+// front (the approach List used before it started building media on demand),
+// so switching to a lazy source didn't change what callers see.
+func TestListLazyMatchesEagerBuild(t *testing.T) {
+	filename := filepath.Join("testdata", "marselester_20201007.zip")
+	arch, err := NewService(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer arch.Close()
+
+	f := arch.toc[tocFilename]
+	rc, err := f.Open()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rc.Close()
+
+	var nom nomenclature
+	if err = json.NewDecoder(rc).Decode(&nom); err != nil {
+		t.Fatal(err)
+	}
+
+	// eagerTimeline reproduces the pre-lazy List: build every media up
+	// front, then sort the whole slice by date.
+	n := len(nom.Photos) + len(nom.Videos)
+	eagerTimeline := make([]*igshelf.Media, n)
+	for i := range eagerTimeline {
+		raw, mediaType := rawMediaAt(nom, i)
+		eagerTimeline[i] = buildMedia(raw, mediaType, i, defaultFilenamePrefixLayout)
+	}
+	sort.Slice(eagerTimeline, func(i, j int) bool {
+		ti, tj := eagerTimeline[i].TakenAt, eagerTimeline[j].TakenAt
+		if !ti.Equal(tj) {
+			return ti.After(tj)
+		}
+		return eagerTimeline[i].ArchiveIndex < eagerTimeline[j].ArchiveIndex
+	})
+
+	eagerIter := MediaIter{
+		ctx:       context.Background(),
+		source:    sliceSource(eagerTimeline),
+		groupFunc: arch.groupFunc,
+	}
+	var want []*igshelf.Media
+	for eagerIter.Next() {
+		want = append(want, eagerIter.Media())
+	}
+	if eagerIter.Err() != nil {
+		t.Fatal(eagerIter.Err())
+	}
+
+	lazyIter := arch.List(context.Background(), time.Time{})
+	var got []*igshelf.Media
+	for lazyIter.Next() {
+		got = append(got, lazyIter.Media())
+	}
+	if lazyIter.Err() != nil {
+		t.Fatal(lazyIter.Err())
+	}
+
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf(diff)
+	}
+}
+
+// TestListLazyBoundedCache checks that the lazy source built by List only
+// ever caches media for the current grouping run, not the whole timeline,
+// which is the whole point of building media on demand instead of up front.
+func TestListLazyBoundedCache(t *testing.T) {
+	filename := filepath.Join("testdata", "marselester_20201007.zip")
+	arch, err := NewService(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer arch.Close()
+
+	iter := arch.List(context.Background(), time.Time{}).(*MediaIter)
+	src, ok := iter.source.(*nomSource)
+	if !ok {
+		t.Fatalf("expected a *nomSource, got %T", iter.source)
+	}
+
+	for iter.Next() {
+		if got, max := len(src.built), 8; got > max {
+			t.Fatalf("cache grew to %d entries, want at most %d", got, max)
+		}
+	}
+	if iter.Err() != nil {
+		t.Fatal(iter.Err())
+	}
+}
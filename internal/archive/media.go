@@ -5,46 +5,203 @@ package archive
 import (
 	"archive/zip"
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
+	"io"
 	"io/ioutil"
-	"path/filepath"
+	"net/url"
+	"path"
 	"sort"
 	"strings"
+	"sync"
 	"time"
 
+	"golang.org/x/sync/errgroup"
+
 	"github.com/marselester/igshelf"
 )
 
 // tocFilename is a JSON file that describes archived media files (table of contents).
 const tocFilename = "media.json"
 
+// storiesFilename is the JSON file listing archived Instagram stories,
+// found under a stories/ directory in newer exports, see WithStories.
+const storiesFilename = "stories.json"
+
+// defaultChecksumWorkers is how many zip entries WithChecksums reads and
+// hashes concurrently unless overridden with WithChecksumWorkers.
+const defaultChecksumWorkers = 4
+
+// token is sent on a semaphore channel to reserve a worker slot, and
+// received to release it.
+type token struct{}
+
+// Thumbnailer extracts a cover image (JPEG) from video content,
+// e.g., by grabbing its first keyframe.
+type Thumbnailer interface {
+	Thumbnail(content []byte) (jpeg []byte, err error)
+}
+
+// GroupFunc reports whether two adjacent media in a timeline sorted by
+// TakenAt descending belong in the same carousel album.
+type GroupFunc func(a, b *igshelf.Media) bool
+
+// GroupByCaptionAndTimestamp is the default GroupFunc: media taken at the
+// same instant with the same caption are grouped into one album.
+func GroupByCaptionAndTimestamp(a, b *igshelf.Media) bool {
+	return a.TakenAt.Equal(b.TakenAt) && a.Caption == b.Caption
+}
+
+// GroupByTimestamp groups media taken at the same instant into one album,
+// regardless of caption. It's meant for exports where album children carry
+// distinct per-child captions under a shared timestamp, which would
+// otherwise defeat GroupByCaptionAndTimestamp.
+func GroupByTimestamp(a, b *igshelf.Media) bool {
+	return a.TakenAt.Equal(b.TakenAt)
+}
+
+// GroupNone never groups media into albums, so every entry surfaces as its
+// own post. See WithAlbums.
+func GroupNone(a, b *igshelf.Media) bool {
+	return false
+}
+
+// albumID derives a stable ID for a synthesized carousel album from a hash
+// of all its children's IDs, sorted so the result doesn't depend on which
+// child happens to come first. Deriving the ID from the first child's ID
+// (as before) meant a single new photo added ahead of an existing album
+// shifted which child was "first" and changed the album's ID on every
+// re-run, causing needless timeline.json churn.
+func albumID(children []*igshelf.Media) string {
+	ids := make([]string, len(children))
+	for i, c := range children {
+		ids[i] = c.ID
+	}
+	sort.Strings(ids)
+
+	sum := sha256.Sum256([]byte(strings.Join(ids, ",")))
+	return "album-" + hex.EncodeToString(sum[:])[:16]
+}
+
 // NewService creates a media service that provides access to Instagram timeline from zip archive.
 // It opens an archive and maps paths to corresponding media files.
-func NewService(filename string) (*MediaService, error) {
+// It's a wrapper around NewServiceContext with a context.Background, for
+// callers that don't need to cancel a large archive's indexing.
+func NewService(filename string, options ...ConfigOption) (*MediaService, error) {
+	return NewServiceContext(context.Background(), filename, options...)
+}
+
+// NewServiceContext is like NewService, but checks ctx while building the
+// table of contents, so opening a large archive can be aborted (e.g. by a
+// request deadline or a user cancellation) instead of always indexing
+// every entry before returning.
+func NewServiceContext(ctx context.Context, filename string, options ...ConfigOption) (*MediaService, error) {
 	r, err := zip.OpenReader(filename)
 	if err != nil {
 		return nil, err
 	}
+	if !looksLikeExport(r.File) {
+		r.Close()
+		return nil, fmt.Errorf("%s doesn't look like an Instagram export: no %s or content/ directory found", filename, tocFilename)
+	}
 
 	s := MediaService{
-		r:   r,
-		toc: make(map[string]*zip.File, len(r.File)),
+		r:                    r,
+		groupFunc:            GroupByCaptionAndTimestamp,
+		extensions:           []string{".jpg", ".mp4"},
+		filenamePrefixLayout: defaultFilenamePrefixLayout,
+		checksumWorkers:      defaultChecksumWorkers,
 	}
-	for _, f := range r.File {
-		if f.Name == tocFilename || strings.HasSuffix(f.Name, ".jpg") || strings.HasSuffix(f.Name, ".mp4") {
-			s.toc[f.Name] = f
+	for _, opt := range options {
+		opt(&s)
+	}
+
+	s.toc = make(map[string]*zip.File, len(r.File))
+	for i, f := range r.File {
+		// Checking every entry would make cancellation too slow to matter
+		// for archives with only a handful of huge files, and checking
+		// every entry would add per-entry overhead for archives with
+		// thousands of tiny ones; a fixed interval is a reasonable
+		// middle ground for both.
+		if i%1024 == 0 {
+			if err := ctx.Err(); err != nil {
+				r.Close()
+				return nil, fmt.Errorf("canceled while indexing %s: %w", filename, err)
+			}
+		}
+
+		name := normalizePath(f.Name)
+		switch {
+		case name == tocFilename:
+			s.toc[name] = f
+		case strings.HasSuffix(name, storiesFilename):
+			s.toc[name] = f
+			// Several exports could in principle each ship their own
+			// stories.json under a different path; the first one found
+			// wins, since MultiMediaService already merges per-archive
+			// results by opening one MediaService per file.
+			if s.storiesFile == nil {
+				s.storiesFile = f
+			}
+		case hasAnySuffix(f.Name, s.extensions):
+			s.toc[name] = f
 		}
 	}
 
 	return &s, nil
 }
 
+// looksLikeExport reports whether files, a zip's raw entries, resemble an
+// Instagram data export: either media.json is present at the root, or a
+// content/ directory exists (the layout a newer export unzips into). It's
+// checked up front so opening an unrelated zip fails immediately with a
+// clear error instead of surfacing a confusing "media.json not found" the
+// first time List is called.
+func looksLikeExport(files []*zip.File) bool {
+	for _, f := range files {
+		name := normalizePath(f.Name)
+		if name == tocFilename || strings.HasPrefix(name, "content/") {
+			return true
+		}
+	}
+	return false
+}
+
+// hasAnySuffix reports whether name ends with one of suffixes.
+func hasAnySuffix(name string, suffixes []string) bool {
+	for _, suffix := range suffixes {
+		if strings.HasSuffix(name, suffix) {
+			return true
+		}
+	}
+	return false
+}
+
 // MediaService represents a service to work with an Instagram archive.
 type MediaService struct {
 	r *zip.ReadCloser
 	// toc maps paths to corresponding media files in archive r.
 	toc map[string]*zip.File
+	// thumbnailer generates video covers, since zip archives don't include them.
+	thumbnailer Thumbnailer
+	// groupFunc decides which adjacent media form a carousel album, see WithGroupFunc.
+	groupFunc GroupFunc
+	// extensions lists the file suffixes indexed into toc, see WithExtensions.
+	extensions []string
+	// filenamePrefixLayout is prepended to assigned filenames, see WithFilenamePrefix.
+	filenamePrefixLayout string
+	// stories makes Stories parse storiesFile, see WithStories.
+	stories bool
+	// storiesFile is the archive's stories.json entry, or nil if the
+	// export doesn't include one.
+	storiesFile *zip.File
+	// checksums makes List precompute Media.Checksum, see WithChecksums.
+	checksums bool
+	// checksumWorkers limits how many zip entries are hashed concurrently
+	// when checksums is enabled, see WithChecksumWorkers.
+	checksumWorkers int
 }
 
 // Close closes the underlying zip file.
@@ -53,7 +210,8 @@ func (s *MediaService) Close() error {
 }
 
 // Download copies the media file from its location in archive.
-// Note, thumbnail is not available.
+// Note, thumbnail is nil unless a Thumbnailer was configured with WithThumbnailer,
+// since archives themselves don't include video covers.
 func (s *MediaService) Download(ctx context.Context, m *igshelf.Media) (content, thumbnail []byte, err error) {
 	f, ok := s.toc[m.Location]
 	if !ok {
@@ -66,10 +224,84 @@ func (s *MediaService) Download(ctx context.Context, m *igshelf.Media) (content,
 	}
 	defer rc.Close()
 
-	if content, err = ioutil.ReadAll(rc); err != nil {
+	if content, err = ioutil.ReadAll(&ctxReader{ctx: ctx, r: rc}); err != nil {
 		return nil, nil, fmt.Errorf("failed to read content: %w", err)
 	}
-	return content, nil, nil
+
+	// Generating a thumbnail is best effort: a video whose cover couldn't be
+	// extracted is still worth keeping, so failures here are not fatal.
+	if s.thumbnailer != nil && m.Type == igshelf.MediaTypeVideo {
+		thumbnail, _ = s.thumbnailer.Thumbnail(content)
+	}
+	return content, thumbnail, nil
+}
+
+// EstimatedSize sums the uncompressed size of every media file in the
+// archive's table of contents, so a caller can show "About 3.2 GB to
+// download" before starting. Unlike Download, this doesn't need to open or
+// decompress anything, since the zip index already records uncompressed
+// sizes.
+func (s *MediaService) EstimatedSize(ctx context.Context) (int64, error) {
+	var total int64
+	for name, f := range s.toc {
+		if name == tocFilename {
+			continue
+		}
+		total += int64(f.UncompressedSize64)
+	}
+	return total, nil
+}
+
+// Files returns every path in the archive's table of contents (media.json
+// plus whatever extensions were indexed, see WithExtensions), sorted for a
+// deterministic order. It lets a caller inspect the raw file list, e.g. to
+// find non-media files like stories or profile pics, without reopening the
+// zip itself.
+func (s *MediaService) Files() []string {
+	names := make([]string, 0, len(s.toc))
+	for name := range s.toc {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// ctxReader wraps an io.Reader so each Read call is aborted with ctx's
+// error once the context is canceled, letting Download stop promptly
+// while reading a large file instead of always running to EOF.
+type ctxReader struct {
+	ctx context.Context
+	r   io.Reader
+}
+
+func (r *ctxReader) Read(p []byte) (int, error) {
+	if err := r.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return r.r.Read(p)
+}
+
+// Get looks up a single media (or album child) by ID by scanning the
+// archive's timeline, since a zip archive has no index besides media.json
+// itself. It's a building block for re-downloading one item without
+// re-copying the whole archive.
+func (s *MediaService) Get(ctx context.Context, id string) (*igshelf.Media, error) {
+	iter := s.List(ctx, time.Time{})
+	for iter.Next() {
+		m := iter.Media()
+		if m.ID == id {
+			return m, nil
+		}
+		for _, c := range m.Children {
+			if c.ID == id {
+				return c, nil
+			}
+		}
+	}
+	if err := iter.Err(); err != nil {
+		return nil, err
+	}
+	return nil, fmt.Errorf("media %s not found in archive", id)
 }
 
 // media represents an image or video (album is not available).
@@ -80,6 +312,29 @@ type media struct {
 	TakenAt time.Time `json:"taken_at"`
 	// Path is a relative path to a media file, e.g., videos/202010/8c996aa535f0f7a322d4dbaef9cfd266.mp4.
 	Path string `json:"path"`
+	// URI is what newer archives use instead of Path, e.g.,
+	// media/posts/202010/8c996aa535f0f7a322d4dbaef9cfd266.mp4, sometimes URL-encoded.
+	URI string `json:"uri"`
+}
+
+// path returns the media's file path, preferring the legacy Path field and
+// falling back to URI for newer archives that only set that one.
+func (m *media) path() string {
+	if m.Path != "" {
+		return m.Path
+	}
+	return m.URI
+}
+
+// normalizePath makes a media.json path reference and a zip entry name
+// comparable: it URL-decodes percent-escapes (newer archives sometimes
+// URL-encode the uri field), then cleans the path, e.g. stripping a leading
+// "./", so a lookup by one succeeds against a toc keyed by the other.
+func normalizePath(p string) string {
+	if decoded, err := url.QueryUnescape(p); err == nil {
+		p = decoded
+	}
+	return path.Clean(p)
 }
 
 // nomenclature represents content of media.json found in a zip archive.
@@ -88,11 +343,61 @@ type nomenclature struct {
 	Photos []*media `json:"photos"`
 }
 
+// storyNomenclature represents content of stories.json found in a zip
+// archive's stories/ directory. Unlike media.json, it doesn't separate
+// entries into photos and videos.
+type storyNomenclature struct {
+	Stories []*media `json:"stories"`
+}
+
+// Stories returns the archive's Instagram stories in reverse chronological
+// order (newest first), tagged with igshelf.ProductTypeStory, if WithStories
+// was enabled. It returns nil, nil if WithStories wasn't enabled or the
+// archive doesn't include a stories.json.
+//
+// Unlike List, stories aren't grouped into carousel albums: Instagram
+// doesn't group them that way, so there's no GroupFunc to apply.
+func (s *MediaService) Stories(ctx context.Context) ([]*igshelf.Media, error) {
+	if !s.stories || s.storiesFile == nil {
+		return nil, nil
+	}
+
+	rc, err := s.storiesFile.Open()
+	if err != nil {
+		return nil, fmt.Errorf("failed to open archived %s: %w", storiesFilename, err)
+	}
+	defer rc.Close()
+
+	var raw storyNomenclature
+	if err := json.NewDecoder(&ctxReader{ctx: ctx, r: rc}).Decode(&raw); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal archived %s: %w", storiesFilename, err)
+	}
+
+	stories := make([]*igshelf.Media, len(raw.Stories))
+	for i, entry := range raw.Stories {
+		stories[i] = buildStory(entry, i, s.filenamePrefixLayout)
+	}
+	sort.Slice(stories, func(i, j int) bool {
+		return stories[i].TakenAt.After(stories[j].TakenAt)
+	})
+	return stories, nil
+}
+
 // List returns a collection of media in reverse chronological order (newest first).
 // Note, media is not sorted in zip archive, so the order is restored based on date and caption.
-func (s *MediaService) List(ctx context.Context) igshelf.MediaIter {
+// When since is non-zero, media taken at or before it is dropped.
+//
+// media.json itself is still fully decoded into memory, since a correct
+// chronological order can't be known without seeing every entry's date.
+// But unlike an earlier version of this method, the returned iterator no
+// longer eagerly builds an igshelf.Media for every entry up front: it only
+// tracks a sorted list of indices into the decoded nomenclature and builds
+// media lazily as Next is called, which avoids doubling memory usage on a
+// timeline with tens of thousands of media.
+func (s *MediaService) List(ctx context.Context, since time.Time) igshelf.MediaIter {
 	iter := MediaIter{
-		ctx: ctx,
+		ctx:       ctx,
+		groupFunc: s.groupFunc,
 	}
 	f, ok := s.toc[tocFilename]
 	if !ok {
@@ -113,55 +418,217 @@ func (s *MediaService) List(ctx context.Context) igshelf.MediaIter {
 		return &iter
 	}
 
-	timeline := make([]*igshelf.Media, 0, len(nom.Videos)+len(nom.Photos))
-	for _, raw := range nom.Photos {
-		m := igshelf.Media{
-			Caption:  raw.Caption,
-			Type:     igshelf.MediaTypeImage,
-			Location: raw.Path,
-			TakenAt:  raw.TakenAt,
-		}
-		// Assign file names which should be used after extracting the files from archive.
-		// Year/month prefix helps to explore files.
-		_, fname := filepath.Split(raw.Path)
-		m.ID = fname[:len(fname)-len(filepath.Ext(fname))]
-		m.Filename = m.TakenAt.Format("200601_") + fname
-		timeline = append(timeline, &m)
-	}
-	for _, raw := range nom.Videos {
-		m := igshelf.Media{
-			Caption:  raw.Caption,
-			Type:     igshelf.MediaTypeVideo,
-			Location: raw.Path,
-			TakenAt:  raw.TakenAt,
-		}
-		_, fname := filepath.Split(raw.Path)
-		m.ID = fname[:len(fname)-len(filepath.Ext(fname))]
-		m.Filename = m.TakenAt.Format("200601_") + fname
-		timeline = append(timeline, &m)
-	}
-
-	// Sort all the media by date to allow grouping by caption.
-	// This helps to create albums in MediaIter.
-	sort.Slice(timeline, func(i, j int) bool {
-		return timeline[i].TakenAt.After(timeline[j].TakenAt)
-	})
+	order := sortedOrder(nom)
+
+	if !since.IsZero() {
+		n := 0
+		for _, idx := range order {
+			if takenAtAt(nom, idx).After(since) {
+				order[n] = idx
+				n++
+			}
+		}
+		order = order[:n]
+	}
+
+	var checksums map[string]string
+	if s.checksums {
+		if checksums, err = s.computeChecksums(ctx); err != nil {
+			iter.err = fmt.Errorf("failed to precompute checksums: %w", err)
+			return &iter
+		}
+	}
 
-	iter.timeline = timeline
+	iter.source = &nomSource{
+		nom:                  nom,
+		order:                order,
+		built:                make(map[int]*igshelf.Media),
+		filenamePrefixLayout: s.filenamePrefixLayout,
+		checksums:            checksums,
+		filenameOverrides:    disambiguateFilenames(nom, s.filenamePrefixLayout),
+	}
 	return &iter
 }
 
+// computeChecksums hashes every media file indexed in s.toc with a small
+// worker pool, so List can populate Media.Checksum before any content is
+// downloaded. Since an archive's files are already local, this front-loads
+// the read/hash work and lets a caller dedup by Checksum before a single
+// byte is fetched over the network, instead of only after a downloader
+// copies each file. media.json and stories.json aren't media files and
+// are skipped. The returned map is keyed by the same normalized path
+// stored in Media.Location.
+func (s *MediaService) computeChecksums(ctx context.Context) (map[string]string, error) {
+	g, ctx := errgroup.WithContext(ctx)
+	sem := make(chan token, s.checksumWorkers)
+
+	var (
+		mu        sync.Mutex
+		checksums = make(map[string]string, len(s.toc))
+	)
+	for name, f := range s.toc {
+		if name == tocFilename || strings.HasSuffix(name, storiesFilename) {
+			continue
+		}
+
+		name, f := name, f
+		sem <- token{}
+		g.Go(func() error {
+			defer func() { <-sem }()
+
+			sum, err := checksumZipFile(ctx, f)
+			if err != nil {
+				return fmt.Errorf("failed to checksum %s: %w", name, err)
+			}
+
+			mu.Lock()
+			checksums[name] = sum
+			mu.Unlock()
+			return nil
+		})
+	}
+	if err := g.Wait(); err != nil {
+		return nil, err
+	}
+	return checksums, nil
+}
+
+// checksumZipFile hashes a zip entry's content, aborting early if ctx is
+// canceled while reading a large file.
+func checksumZipFile(ctx context.Context, f *zip.File) (string, error) {
+	rc, err := f.Open()
+	if err != nil {
+		return "", err
+	}
+	defer rc.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, &ctxReader{ctx: ctx, r: rc}); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// sortedOrder lists indices into the flattened (photos then videos) nom,
+// sorted by date to allow grouping by caption. This helps to create albums
+// in MediaIter without having to build every igshelf.Media first.
+// Ties (same TakenAt) are broken by each entry's original position in
+// media.json, so the reconstructed order is deterministic and closer to
+// export order instead of depending on sort.Slice's arbitrary tie handling.
+func sortedOrder(nom nomenclature) []int {
+	order := make([]int, len(nom.Photos)+len(nom.Videos))
+	for i := range order {
+		order[i] = i
+	}
+	sort.Slice(order, func(i, j int) bool {
+		ti, tj := takenAtAt(nom, order[i]), takenAtAt(nom, order[j])
+		if !ti.Equal(tj) {
+			return ti.After(tj)
+		}
+		return order[i] < order[j]
+	})
+	return order
+}
+
+// takenAtAt reports the publish date of the raw media at position i of the
+// flattened nomenclature, without building a full igshelf.Media for it.
+func takenAtAt(nom nomenclature, i int) time.Time {
+	raw, _ := rawMediaAt(nom, i)
+	return raw.TakenAt
+}
+
+// mediaSource is what a MediaIter walks to produce media, letting it either
+// build media lazily from an archive's nomenclature (nomSource) or replay a
+// slice already in memory (sliceSource, handy in tests).
+type mediaSource interface {
+	// len reports how many media are available.
+	len() int
+	// at returns the media at position i. It may build it on demand.
+	at(i int) *igshelf.Media
+	// evictBefore discards anything cached strictly before cursor, since
+	// Next never revisits earlier positions.
+	evictBefore(cursor int)
+}
+
+// nomSource lazily builds an igshelf.Media for a position in order only
+// when it's asked for, instead of eagerly building the whole timeline
+// up front. A multi-gigabyte export can list tens of thousands of media,
+// so this keeps List from doubling their memory footprint.
+type nomSource struct {
+	// nom is the decoded table of contents media are built from.
+	nom nomenclature
+	// order lists indices into the flattened (photos then videos) nom,
+	// sorted by date. Otherwise grouping in albums won't work.
+	order []int
+	// built caches media already constructed while looking ahead for
+	// album grouping, keyed by their order slice position.
+	built map[int]*igshelf.Media
+	// filenamePrefixLayout is forwarded to buildMedia, see WithFilenamePrefix.
+	filenamePrefixLayout string
+	// checksums maps a media's Location to its precomputed SHA-256 digest,
+	// see WithChecksums. It's nil unless WithChecksums was enabled.
+	checksums map[string]string
+	// filenameOverrides maps a flattened nom index to the disambiguated
+	// Filename it should be given instead of buildMedia's default, see
+	// disambiguateFilenames. Entries whose Filename doesn't collide with
+	// another aren't present here.
+	filenameOverrides map[int]string
+}
+
+func (s *nomSource) len() int { return len(s.order) }
+
+func (s *nomSource) at(i int) *igshelf.Media {
+	if m, ok := s.built[i]; ok {
+		return m
+	}
+	raw, mediaType := rawMediaAt(s.nom, s.order[i])
+	m := buildMedia(raw, mediaType, s.order[i], s.filenamePrefixLayout)
+	if s.checksums != nil {
+		m.Checksum = s.checksums[m.Location]
+	}
+	if name, ok := s.filenameOverrides[s.order[i]]; ok {
+		m.Filename = name
+		// ID is derived from the same base name as Filename, so it collides
+		// the same way; disambiguate it too, or the second colliding entry
+		// would stay permanently unreachable by ID (Get, DownloadIDs, repair).
+		m.ID = disambiguatedFilename(m.ID, raw.path())
+		if mediaType == igshelf.MediaTypeVideo {
+			m.ThumbnailFilename = disambiguatedFilename(m.ThumbnailFilename, raw.path())
+		}
+	}
+	s.built[i] = m
+	return m
+}
+
+func (s *nomSource) evictBefore(cursor int) {
+	for i := range s.built {
+		if i < cursor {
+			delete(s.built, i)
+		}
+	}
+}
+
+// sliceSource walks a timeline that's already built, e.g. in tests that
+// exercise MediaIter's grouping logic directly.
+type sliceSource []*igshelf.Media
+
+func (s sliceSource) len() int                { return len(s) }
+func (s sliceSource) at(i int) *igshelf.Media { return s[i] }
+func (s sliceSource) evictBefore(int)         {}
+
 // MediaIter is an iterator for media timeline.
 type MediaIter struct {
 	err error
 	ctx context.Context
-	// cursor is a current cursor position in the timeline slice.
+	// cursor is a current cursor position in the source.
 	cursor int
 	// current is a current media returned by this iterator.
 	current *igshelf.Media
-	// timeline is a flat Instagram timeline that must be ordered by date.
+	// source produces media in chronological order (newest first).
 	// Otherwise grouping in albums won't work.
-	timeline []*igshelf.Media
+	source mediaSource
+	// groupFunc decides which adjacent media form a carousel album.
+	groupFunc GroupFunc
 }
 
 // Next prepares the next media for reading with the Media method.
@@ -183,28 +650,34 @@ func (it *MediaIter) Next() bool {
 		}
 	}
 
-	if it.cursor >= len(it.timeline) {
+	if it.cursor >= it.source.len() {
 		return false
 	}
 
+	it.source.evictBefore(it.cursor)
+
 	// When the next few media belong to the same album (dates and captions match), a carousel album is created.
 	// Note, ID of this album media is given a suffix to make sure all media IDs are unique.
-	m = it.timeline[it.cursor]
-	offset := 0
-	for i := it.cursor + 1; i < len(it.timeline); i++ {
-		if !m.TakenAt.Equal(it.timeline[i].TakenAt) || m.Caption != it.timeline[i].Caption {
+	m = it.source.at(it.cursor)
+	offset := it.cursor
+	for i := it.cursor + 1; i < it.source.len(); i++ {
+		if !it.groupFunc(m, it.source.at(i)) {
 			break
 		}
 		offset = i
 	}
 
 	if offset > it.cursor {
+		children := make([]*igshelf.Media, offset-it.cursor+1)
+		for i := it.cursor; i <= offset; i++ {
+			children[i-it.cursor] = it.source.at(i)
+		}
 		it.current = &igshelf.Media{
-			ID:       m.ID + "album",
+			ID:       albumID(children),
 			Type:     igshelf.MediaTypeAlbum,
 			Caption:  m.Caption,
 			TakenAt:  m.TakenAt,
-			Children: it.timeline[it.cursor : offset+1],
+			Children: children,
 		}
 	} else {
 		it.current = m
@@ -1,8 +1,13 @@
 package archive
 
 import (
+	"archive/zip"
 	"context"
+	"errors"
+	"fmt"
+	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 	"time"
 
@@ -11,6 +16,80 @@ import (
 	"github.com/marselester/igshelf"
 )
 
+// TestNewServiceRejectsNonExport checks that NewService fails immediately
+// with a descriptive error when given a zip that has neither media.json nor
+// a content/ directory, instead of succeeding and only failing later on List.
+func TestNewServiceRejectsNonExport(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "not-instagram.zip")
+	f, err := os.Create(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w := zip.NewWriter(f)
+	fw, err := w.Create("readme.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := fw.Write([]byte("hello")); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = NewService(filename)
+	if err == nil {
+		t.Fatal("expected an error for a non-Instagram zip, got none")
+	}
+	if !strings.Contains(err.Error(), "doesn't look like an Instagram export") {
+		t.Errorf("got error %q, want it to mention the export isn't recognized", err.Error())
+	}
+}
+
+// cancelAfterChecks is a context.Context whose Err method returns
+// context.Canceled once it's been checked more than limit times, letting a
+// test cancel partway through a loop that polls ctx.Err() repeatedly
+// without racing a real timer against how fast the loop runs.
+type cancelAfterChecks struct {
+	context.Context
+	checks int
+	limit  int
+}
+
+func (c *cancelAfterChecks) Err() error {
+	c.checks++
+	if c.checks > c.limit {
+		return context.Canceled
+	}
+	return nil
+}
+
+// TestNewServiceContextCancel checks that NewServiceContext stops indexing
+// a large archive and returns an error once ctx is canceled, instead of
+// always walking every entry before returning.
+func TestNewServiceContextCancel(t *testing.T) {
+	files := make(map[string][]byte, 4096)
+	for i := 0; i < 4096; i++ {
+		files[fmt.Sprintf("photos/202010/%d.jpg", i)] = []byte("x")
+	}
+	filename := buildTestArchive(t, `{"photos":[]}`, files)
+
+	ctx := &cancelAfterChecks{Context: context.Background(), limit: 1}
+	_, err := NewServiceContext(ctx, filename)
+	if err == nil {
+		t.Fatal("expected an error for a canceled context, got none")
+	}
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("got error %q, want it to wrap context.Canceled", err)
+	}
+	if ctx.checks <= 1 {
+		t.Errorf("got %d ctx.Err checks, want indexing to have started before canceling", ctx.checks)
+	}
+}
+
 func TestMediaList(t *testing.T) {
 	filename := filepath.Join("testdata", "marselester_20201007.zip")
 	arch, err := NewService(filename)
@@ -19,7 +98,7 @@ func TestMediaList(t *testing.T) {
 	}
 	defer arch.Close()
 
-	iter := arch.List(context.Background())
+	iter := arch.List(context.Background(), time.Time{})
 	var got []*igshelf.Media
 	for iter.Next() {
 		got = append(got, iter.Media())
@@ -30,28 +109,409 @@ func TestMediaList(t *testing.T) {
 
 	want := []*igshelf.Media{
 		{
-			ID:       "8c996aa535f0f7a322d4dbaef9cfd266",
-			Caption:  "Still jumping",
-			Type:     "VIDEO",
-			Location: "videos/202010/8c996aa535f0f7a322d4dbaef9cfd266.mp4",
-			Filename: "202010_8c996aa535f0f7a322d4dbaef9cfd266.mp4",
-			TakenAt:  time.Date(2020, time.October, 7, 15, 55, 33, 0, time.UTC),
+			ID:                "8c996aa535f0f7a322d4dbaef9cfd266",
+			Caption:           "Still jumping",
+			Type:              "VIDEO",
+			Location:          "videos/202010/8c996aa535f0f7a322d4dbaef9cfd266.mp4",
+			Filename:          "202010_8c996aa535f0f7a322d4dbaef9cfd266.mp4",
+			ThumbnailFilename: "202010_8c996aa535f0f7a322d4dbaef9cfd266_cover.jpg",
+			TakenAt:           time.Date(2020, time.October, 7, 15, 55, 33, 0, time.UTC),
+			ArchiveIndex:      1,
 		},
 		{
-			ID:       "d8612ffa060b392077322ccf2e953f35",
-			Caption:  "Starting another two-wheeled hobby.\n\nЯ буду долго гнать велосипед.",
-			Type:     "IMAGE",
-			Location: "photos/202006/d8612ffa060b392077322ccf2e953f35.jpg",
-			Filename: "202006_d8612ffa060b392077322ccf2e953f35.jpg",
-			TakenAt:  time.Date(2020, time.June, 21, 1, 12, 14, 0, time.UTC),
+			ID:           "d8612ffa060b392077322ccf2e953f35",
+			Caption:      "Starting another two-wheeled hobby.\n\nЯ буду долго гнать велосипед.",
+			Type:         "IMAGE",
+			Location:     "photos/202006/d8612ffa060b392077322ccf2e953f35.jpg",
+			Filename:     "202006_d8612ffa060b392077322ccf2e953f35.jpg",
+			TakenAt:      time.Date(2020, time.June, 21, 1, 12, 14, 0, time.UTC),
+			ArchiveIndex: 0,
+		},
+	}
+
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf(diff)
+	}
+}
+
+// TestMediaListDisambiguatesIDs checks that two entries whose computed ID
+// (and Filename) would otherwise collide, e.g. two photos from different
+// months both named "0.jpg", are given distinct IDs too, not just distinct
+// Filenames. Otherwise the second entry would compute the same ID as the
+// first and become permanently unreachable by ID (Get, DownloadIDs, repair
+// all resolve the first match and never see the second).
+func TestMediaListDisambiguatesIDs(t *testing.T) {
+	nom := nomenclature{
+		Photos: []*media{
+			{Path: "photos/202009/0.jpg", TakenAt: time.Date(2020, time.September, 1, 0, 0, 0, 0, time.UTC)},
+			{Path: "photos/202010/0.jpg", TakenAt: time.Date(2020, time.October, 1, 0, 0, 0, 0, time.UTC)},
 		},
 	}
+	source := &nomSource{
+		nom:               nom,
+		order:             []int{0, 1},
+		built:             make(map[int]*igshelf.Media),
+		filenameOverrides: disambiguateFilenames(nom, ""),
+	}
+
+	m0 := source.at(0)
+	m1 := source.at(1)
 
+	if m0.ID == "0" || m1.ID == "0" {
+		t.Errorf("expected disambiguated IDs, got %q and %q", m0.ID, m1.ID)
+	}
+	if m0.ID == m1.ID {
+		t.Errorf("got the same ID for both colliding entries: %q", m0.ID)
+	}
+	if m0.Filename == m1.Filename {
+		t.Errorf("got the same Filename for both colliding entries: %q", m0.Filename)
+	}
+}
+
+// TestArchiveStories checks that Stories parses a stories/stories.json
+// entry into media tagged with igshelf.ProductTypeStory, when WithStories
+// is enabled.
+func TestArchiveStories(t *testing.T) {
+	filename := buildTestArchive(t, `{"photos": [], "videos": []}`, map[string][]byte{
+		"stories/stories.json": []byte(`{
+			"stories": [
+				{"taken_at": "2020-10-07T15:55:33+00:00", "uri": "stories/202010/8c996aa535f0f7a322d4dbaef9cfd266.jpg"},
+				{"taken_at": "2020-10-06T09:00:00+00:00", "uri": "stories/202010/d8612ffa060b392077322ccf2e953f35.mp4"}
+			]
+		}`),
+	})
+
+	arch, err := NewService(filename, WithStories(true))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer arch.Close()
+
+	got, err := arch.Stories(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []*igshelf.Media{
+		{
+			ID:           "8c996aa535f0f7a322d4dbaef9cfd266",
+			Type:         igshelf.MediaTypeImage,
+			ProductType:  igshelf.ProductTypeStory,
+			Location:     "stories/202010/8c996aa535f0f7a322d4dbaef9cfd266.jpg",
+			Filename:     "202010_8c996aa535f0f7a322d4dbaef9cfd266.jpg",
+			TakenAt:      time.Date(2020, time.October, 7, 15, 55, 33, 0, time.UTC),
+			ArchiveIndex: 0,
+		},
+		{
+			ID:                "d8612ffa060b392077322ccf2e953f35",
+			Type:              igshelf.MediaTypeVideo,
+			ProductType:       igshelf.ProductTypeStory,
+			Location:          "stories/202010/d8612ffa060b392077322ccf2e953f35.mp4",
+			Filename:          "202010_d8612ffa060b392077322ccf2e953f35.mp4",
+			ThumbnailFilename: "202010_d8612ffa060b392077322ccf2e953f35_cover.jpg",
+			TakenAt:           time.Date(2020, time.October, 6, 9, 0, 0, 0, time.UTC),
+			ArchiveIndex:      1,
+		},
+	}
 	if diff := cmp.Diff(want, got); diff != "" {
 		t.Errorf(diff)
 	}
 }
 
+// TestArchiveStoriesDisabledByDefault checks that Stories returns nil
+// unless WithStories was passed to NewService, even when the archive
+// includes a stories.json, so a caller who doesn't ask for stories doesn't
+// pay for decoding them either.
+func TestArchiveStoriesDisabledByDefault(t *testing.T) {
+	filename := buildTestArchive(t, `{"photos": [], "videos": []}`, map[string][]byte{
+		"stories/stories.json": []byte(`{"stories": [{"taken_at": "2020-10-07T15:55:33+00:00", "uri": "stories/202010/a.jpg"}]}`),
+	})
+
+	arch, err := NewService(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer arch.Close()
+
+	got, err := arch.Stories(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != nil {
+		t.Errorf("got %v, want nil since WithStories wasn't enabled", got)
+	}
+}
+
+// TestMediaListChecksums checks that WithChecksums populates each media's
+// Checksum with the SHA-256 digest of its actual file content, computed
+// against known values for the testdata archive.
+func TestMediaListChecksums(t *testing.T) {
+	filename := filepath.Join("testdata", "marselester_20201007.zip")
+	arch, err := NewService(filename, WithChecksums(true))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer arch.Close()
+
+	iter := arch.List(context.Background(), time.Time{})
+	got := make(map[string]string)
+	for iter.Next() {
+		m := iter.Media()
+		got[m.ID] = m.Checksum
+	}
+	if iter.Err() != nil {
+		t.Fatal(iter.Err())
+	}
+
+	want := map[string]string{
+		"8c996aa535f0f7a322d4dbaef9cfd266": "c3ece55134d010aff2e239bec0079cf913dc9bb30ed261a86d0bc3fd39e82b9e",
+		"d8612ffa060b392077322ccf2e953f35": "e5dba011e3d84aede1ef889896ba6950368c0e542d708f61e49bc2b899ea11e8",
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("checksum mismatch (-want +got):\n%s", diff)
+	}
+}
+
+// TestMediaListChecksumsDisabledByDefault checks that List leaves
+// Checksum empty unless WithChecksums was enabled, since hashing every
+// file is wasted work most callers don't need.
+func TestMediaListChecksumsDisabledByDefault(t *testing.T) {
+	filename := filepath.Join("testdata", "marselester_20201007.zip")
+	arch, err := NewService(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer arch.Close()
+
+	iter := arch.List(context.Background(), time.Time{})
+	for iter.Next() {
+		if got := iter.Media().Checksum; got != "" {
+			t.Errorf("got checksum %q, want empty since WithChecksums wasn't enabled", got)
+		}
+	}
+	if iter.Err() != nil {
+		t.Fatal(iter.Err())
+	}
+}
+
+// BenchmarkMediaListChecksums measures the cost of precomputing checksums
+// for the testdata archive's media files, to gauge WithChecksums' worker
+// pool against a larger export.
+func BenchmarkMediaListChecksums(b *testing.B) {
+	filename := filepath.Join("testdata", "marselester_20201007.zip")
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		arch, err := NewService(filename, WithChecksums(true))
+		if err != nil {
+			b.Fatal(err)
+		}
+
+		iter := arch.List(context.Background(), time.Time{})
+		for iter.Next() {
+		}
+		if iter.Err() != nil {
+			b.Fatal(iter.Err())
+		}
+		arch.Close()
+	}
+}
+
+func TestMediaServiceGet(t *testing.T) {
+	filename := filepath.Join("testdata", "marselester_20201007.zip")
+	arch, err := NewService(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer arch.Close()
+
+	got, err := arch.Get(context.Background(), "d8612ffa060b392077322ccf2e953f35")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := &igshelf.Media{
+		ID:           "d8612ffa060b392077322ccf2e953f35",
+		Caption:      "Starting another two-wheeled hobby.\n\nЯ буду долго гнать велосипед.",
+		Type:         "IMAGE",
+		Location:     "photos/202006/d8612ffa060b392077322ccf2e953f35.jpg",
+		Filename:     "202006_d8612ffa060b392077322ccf2e953f35.jpg",
+		TakenAt:      time.Date(2020, time.June, 21, 1, 12, 14, 0, time.UTC),
+		ArchiveIndex: 0,
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf(diff)
+	}
+}
+
+func TestMediaServiceEstimatedSize(t *testing.T) {
+	filename := filepath.Join("testdata", "marselester_20201007.zip")
+	arch, err := NewService(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer arch.Close()
+
+	got, err := arch.EstimatedSize(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got <= 0 {
+		t.Errorf("got estimated size %d, want a positive number", got)
+	}
+}
+
+func TestMediaServiceFiles(t *testing.T) {
+	filename := filepath.Join("testdata", "marselester_20201007.zip")
+	arch, err := NewService(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer arch.Close()
+
+	want := []string{
+		"media.json",
+		"photos/202006/d8612ffa060b392077322ccf2e953f35.jpg",
+		"videos/202010/8c996aa535f0f7a322d4dbaef9cfd266.mp4",
+	}
+	if diff := cmp.Diff(want, arch.Files()); diff != "" {
+		t.Errorf(diff)
+	}
+}
+
+// TestDownloadCanceledContext checks that Download stops reading and
+// returns a context error once ctx is canceled, instead of reading the
+// whole file.
+func TestDownloadCanceledContext(t *testing.T) {
+	filename := buildTestArchive(t, `{
+		"photos": [
+			{"caption": "test", "taken_at": "2020-10-07T15:55:33+00:00", "path": "photos/202010/big.jpg"}
+		],
+		"videos": []
+	}`, map[string][]byte{
+		"photos/202010/big.jpg": []byte(strings.Repeat("x", 1<<20)),
+	})
+
+	arch, err := NewService(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer arch.Close()
+
+	iter := arch.List(context.Background(), time.Time{})
+	if !iter.Next() {
+		t.Fatal(iter.Err())
+	}
+	m := iter.Media()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	content, _, err := arch.Download(ctx, m)
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("got err %v, want context.Canceled", err)
+	}
+	if content != nil {
+		t.Errorf("got %d bytes of content, want none since the context was already canceled", len(content))
+	}
+}
+
+func TestMediaServiceGetNotFound(t *testing.T) {
+	filename := filepath.Join("testdata", "marselester_20201007.zip")
+	arch, err := NewService(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer arch.Close()
+
+	if _, err := arch.Get(context.Background(), "does-not-exist"); err == nil {
+		t.Error("got no error for an unknown ID, want one")
+	}
+}
+
+// TestMediaIterGroupFunc checks that MediaIter groups albums differently
+// depending on which GroupFunc it's given, on the same timeline of media
+// sharing a timestamp but carrying distinct per-child captions.
+func TestMediaIterGroupFunc(t *testing.T) {
+	takenAt := time.Date(2020, time.June, 21, 1, 12, 14, 0, time.UTC)
+	timeline := []*igshelf.Media{
+		{ID: "1", Type: "IMAGE", Caption: "first", TakenAt: takenAt},
+		{ID: "2", Type: "IMAGE", Caption: "second", TakenAt: takenAt},
+	}
+
+	tt := map[string]struct {
+		groupFunc GroupFunc
+		want      []*igshelf.Media
+	}{
+		"caption and timestamp keeps them apart": {
+			groupFunc: GroupByCaptionAndTimestamp,
+			want: []*igshelf.Media{
+				{ID: "1", Type: "IMAGE", Caption: "first", TakenAt: takenAt},
+				{ID: "2", Type: "IMAGE", Caption: "second", TakenAt: takenAt},
+			},
+		},
+		"timestamp only groups them into an album": {
+			groupFunc: GroupByTimestamp,
+			want: []*igshelf.Media{
+				{
+					ID:       albumID(timeline),
+					Type:     igshelf.MediaTypeAlbum,
+					Caption:  "first",
+					TakenAt:  takenAt,
+					Children: timeline,
+				},
+			},
+		},
+		"none keeps them as separate posts despite the shared timestamp": {
+			groupFunc: GroupNone,
+			want: []*igshelf.Media{
+				{ID: "1", Type: "IMAGE", Caption: "first", TakenAt: takenAt},
+				{ID: "2", Type: "IMAGE", Caption: "second", TakenAt: takenAt},
+			},
+		},
+	}
+
+	for name, tc := range tt {
+		t.Run(name, func(t *testing.T) {
+			iter := MediaIter{
+				ctx:       context.Background(),
+				source:    sliceSource(timeline),
+				groupFunc: tc.groupFunc,
+			}
+
+			var got []*igshelf.Media
+			for iter.Next() {
+				got = append(got, iter.Media())
+			}
+			if iter.Err() != nil {
+				t.Fatal(iter.Err())
+			}
+
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf(diff)
+			}
+		})
+	}
+}
+
+// TestAlbumIDStableAcrossChildOrder checks that albumID doesn't depend on
+// the order its children are given in, so the same set of children always
+// produces the same album ID regardless of which one a re-run sees first.
+func TestAlbumIDStableAcrossChildOrder(t *testing.T) {
+	want := albumID([]*igshelf.Media{{ID: "1"}, {ID: "2"}, {ID: "3"}})
+
+	orders := [][]*igshelf.Media{
+		{{ID: "2"}, {ID: "1"}, {ID: "3"}},
+		{{ID: "3"}, {ID: "2"}, {ID: "1"}},
+		{{ID: "1"}, {ID: "3"}, {ID: "2"}},
+	}
+	for _, children := range orders {
+		if got := albumID(children); got != want {
+			t.Errorf("got %q for order %v, want %q", got, children, want)
+		}
+	}
+}
+
 func TestMediaIter(t *testing.T) {
 	tt := map[string]struct {
 		timeline []*igshelf.Media
@@ -100,7 +560,7 @@ func TestMediaIter(t *testing.T) {
 			},
 			want: []*igshelf.Media{
 				{
-					ID:      "1album",
+					ID:      albumID([]*igshelf.Media{{ID: "1"}, {ID: "2"}}),
 					Type:    "CAROUSEL_ALBUM",
 					Caption: "still jumping",
 					TakenAt: time.Date(2020, time.June, 21, 1, 12, 14, 0, time.UTC),
@@ -150,7 +610,7 @@ func TestMediaIter(t *testing.T) {
 					TakenAt: time.Date(2020, time.October, 21, 1, 12, 14, 0, time.UTC),
 				},
 				{
-					ID:      "2album",
+					ID:      albumID([]*igshelf.Media{{ID: "2"}, {ID: "3"}}),
 					Type:    "CAROUSEL_ALBUM",
 					Caption: "still jumping",
 					TakenAt: time.Date(2020, time.June, 21, 1, 12, 14, 0, time.UTC),
@@ -194,7 +654,7 @@ func TestMediaIter(t *testing.T) {
 			},
 			want: []*igshelf.Media{
 				{
-					ID:      "1album",
+					ID:      albumID([]*igshelf.Media{{ID: "1"}, {ID: "2"}}),
 					Type:    "CAROUSEL_ALBUM",
 					Caption: "still jumping",
 					TakenAt: time.Date(2020, time.June, 21, 1, 12, 14, 0, time.UTC),
@@ -226,8 +686,9 @@ func TestMediaIter(t *testing.T) {
 	for name, tc := range tt {
 		t.Run(name, func(t *testing.T) {
 			iter := MediaIter{
-				ctx:      context.Background(),
-				timeline: tc.timeline,
+				ctx:       context.Background(),
+				source:    sliceSource(tc.timeline),
+				groupFunc: GroupByCaptionAndTimestamp,
 			}
 
 			var got []*igshelf.Media
@@ -0,0 +1,142 @@
+package archive
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/marselester/igshelf"
+)
+
+// NewMultiService opens every zip archive in filenames and returns a media
+// service whose List merges their timelines into one, e.g. for a user who
+// requested several Instagram exports over the years and wants a single
+// combined gallery. options are applied to each underlying archive.
+func NewMultiService(filenames []string, options ...ConfigOption) (*MultiMediaService, error) {
+	s := MultiMediaService{}
+	for _, fn := range filenames {
+		arch, err := NewService(fn, options...)
+		if err != nil {
+			s.Close()
+			return nil, err
+		}
+		s.services = append(s.services, arch)
+	}
+	return &s, nil
+}
+
+// MultiMediaService fans out over several archive.MediaService, presenting
+// their timelines as one merged, de-duplicated timeline.
+type MultiMediaService struct {
+	services []*MediaService
+}
+
+// Close closes every underlying archive, returning the last error encountered, if any.
+func (s *MultiMediaService) Close() error {
+	var err error
+	for _, a := range s.services {
+		if e := a.Close(); e != nil {
+			err = e
+		}
+	}
+	return err
+}
+
+// Download copies the media file from whichever archive contains it.
+func (s *MultiMediaService) Download(ctx context.Context, m *igshelf.Media) (content, thumbnail []byte, err error) {
+	for _, a := range s.services {
+		if content, thumbnail, err = a.Download(ctx, m); err == nil {
+			return content, thumbnail, nil
+		}
+	}
+	return nil, nil, err
+}
+
+// EstimatedSize sums the uncompressed size of every underlying archive's
+// media files.
+func (s *MultiMediaService) EstimatedSize(ctx context.Context) (int64, error) {
+	var total int64
+	for _, a := range s.services {
+		n, err := a.EstimatedSize(ctx)
+		if err != nil {
+			return 0, err
+		}
+		total += n
+	}
+	return total, nil
+}
+
+// Get looks up a single media by ID, trying each underlying archive in turn.
+func (s *MultiMediaService) Get(ctx context.Context, id string) (*igshelf.Media, error) {
+	var err error
+	for _, a := range s.services {
+		var m *igshelf.Media
+		if m, err = a.Get(ctx, id); err == nil {
+			return m, nil
+		}
+	}
+	return nil, err
+}
+
+// Stories returns the union of every underlying archive's stories (see
+// MediaService.Stories), de-duplicated by ID and sorted newest first.
+func (s *MultiMediaService) Stories(ctx context.Context) ([]*igshelf.Media, error) {
+	seen := make(map[string]bool)
+	var merged []*igshelf.Media
+	for _, a := range s.services {
+		stories, err := a.Stories(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list an archive's stories: %w", err)
+		}
+		for _, m := range stories {
+			if seen[m.ID] {
+				continue
+			}
+			seen[m.ID] = true
+			merged = append(merged, m)
+		}
+	}
+
+	sort.Slice(merged, func(i, j int) bool {
+		return merged[i].TakenAt.After(merged[j].TakenAt)
+	})
+	return merged, nil
+}
+
+// List returns the union of every archive's timeline in reverse
+// chronological order (newest first), de-duplicated by ID.
+// Note, a media's Checksum isn't known until it's downloaded, so ID is the
+// only dedup key available at listing time; two exports overlapping in date
+// range are expected to assign the same ID to the same media.
+func (s *MultiMediaService) List(ctx context.Context, since time.Time) igshelf.MediaIter {
+	seen := make(map[string]bool)
+	var merged []*igshelf.Media
+	for _, a := range s.services {
+		iter := a.List(ctx, since)
+		for iter.Next() {
+			m := iter.Media()
+			if seen[m.ID] {
+				continue
+			}
+			seen[m.ID] = true
+			merged = append(merged, m)
+		}
+		if iter.Err() != nil {
+			return &MediaIter{err: fmt.Errorf("failed to list an archive: %w", iter.Err())}
+		}
+	}
+
+	sort.Slice(merged, func(i, j int) bool {
+		return merged[i].TakenAt.After(merged[j].TakenAt)
+	})
+
+	return &MediaIter{
+		ctx:    ctx,
+		source: sliceSource(merged),
+		// Each archive already grouped its own albums, so the merged
+		// iterator shouldn't group further; two unrelated archives
+		// coincidentally sharing a timestamp aren't the same album.
+		groupFunc: GroupNone,
+	}
+}
@@ -0,0 +1,90 @@
+package archive
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/marselester/igshelf"
+)
+
+func TestMultiServiceListMerges(t *testing.T) {
+	s, err := NewMultiService([]string{
+		filepath.Join("testdata", "marselester_20201007.zip"),
+		filepath.Join("testdata", "second_export.zip"),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	iter := s.List(context.Background(), time.Time{})
+	var got []*igshelf.Media
+	for iter.Next() {
+		got = append(got, iter.Media())
+	}
+	if iter.Err() != nil {
+		t.Fatal(iter.Err())
+	}
+
+	want := []*igshelf.Media{
+		{
+			ID:           "aabbccddeeff00112233445566778899",
+			Caption:      "A new one from the second export",
+			Type:         "IMAGE",
+			Location:     "photos/202101/aabbccddeeff00112233445566778899.jpg",
+			Filename:     "202101_aabbccddeeff00112233445566778899.jpg",
+			TakenAt:      time.Date(2021, time.January, 5, 9, 30, 0, 0, time.UTC),
+			ArchiveIndex: 1,
+		},
+		{
+			ID:                "8c996aa535f0f7a322d4dbaef9cfd266",
+			Caption:           "Still jumping",
+			Type:              "VIDEO",
+			Location:          "videos/202010/8c996aa535f0f7a322d4dbaef9cfd266.mp4",
+			Filename:          "202010_8c996aa535f0f7a322d4dbaef9cfd266.mp4",
+			ThumbnailFilename: "202010_8c996aa535f0f7a322d4dbaef9cfd266_cover.jpg",
+			TakenAt:           time.Date(2020, time.October, 7, 15, 55, 33, 0, time.UTC),
+			ArchiveIndex:      1,
+		},
+		{
+			ID:           "d8612ffa060b392077322ccf2e953f35",
+			Caption:      "Starting another two-wheeled hobby.\n\nЯ буду долго гнать велосипед.",
+			Type:         "IMAGE",
+			Location:     "photos/202006/d8612ffa060b392077322ccf2e953f35.jpg",
+			Filename:     "202006_d8612ffa060b392077322ccf2e953f35.jpg",
+			TakenAt:      time.Date(2020, time.June, 21, 1, 12, 14, 0, time.UTC),
+			ArchiveIndex: 0,
+		},
+	}
+
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf(diff)
+	}
+}
+
+func TestMultiServiceDownload(t *testing.T) {
+	s, err := NewMultiService([]string{
+		filepath.Join("testdata", "marselester_20201007.zip"),
+		filepath.Join("testdata", "second_export.zip"),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	m := &igshelf.Media{
+		Type:     igshelf.MediaTypeImage,
+		Location: "photos/202101/aabbccddeeff00112233445566778899.jpg",
+	}
+	content, _, err := s.Download(context.Background(), m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := string(content), "new-photo-bytes"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,103 @@
+package archive
+
+import (
+	"archive/zip"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+// TestNormalizePath checks that URL-encoded and "./"-prefixed paths, as
+// seen in newer archives' uri field, resolve to the same key a plain
+// relative path would.
+func TestNormalizePath(t *testing.T) {
+	tt := map[string]struct {
+		path string
+		want string
+	}{
+		"plain":         {path: "photos/202010/test.jpg", want: "photos/202010/test.jpg"},
+		"dot slash":     {path: "./photos/202010/test.jpg", want: "photos/202010/test.jpg"},
+		"url encoded":   {path: "photos/202010/te%20st.jpg", want: "photos/202010/te st.jpg"},
+		"encoded slash": {path: "photos%2F202010%2Ftest.jpg", want: "photos/202010/test.jpg"},
+	}
+	for name, tc := range tt {
+		t.Run(name, func(t *testing.T) {
+			if got := normalizePath(tc.path); got != tc.want {
+				t.Errorf("got %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+// TestDownloadResolvesEncodedURI checks that a media.json entry using the
+// newer "uri" field with a URL-encoded, "./"-prefixed path still resolves
+// to its file in the zip archive.
+func TestDownloadResolvesEncodedURI(t *testing.T) {
+	filename := buildTestArchive(t, `{
+		"photos": [
+			{"caption": "test", "taken_at": "2020-10-07T15:55:33+00:00", "uri": "./photos/202010/te%20st.jpg"}
+		],
+		"videos": []
+	}`, map[string][]byte{
+		"photos/202010/te st.jpg": []byte("content"),
+	})
+
+	arch, err := NewService(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer arch.Close()
+
+	iter := arch.List(context.Background(), time.Time{})
+	if !iter.Next() {
+		t.Fatal(iter.Err())
+	}
+	m := iter.Media()
+
+	content, _, err := arch.Download(context.Background(), m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff([]byte("content"), content); diff != "" {
+		t.Errorf("content mismatch (-want +got):\n%s", diff)
+	}
+}
+
+// buildTestArchive creates a zip archive in t.TempDir() with the given
+// media.json body and additional files, returning its path.
+func buildTestArchive(t *testing.T, tocJSON string, files map[string][]byte) string {
+	t.Helper()
+
+	filename := filepath.Join(t.TempDir(), "archive.zip")
+	f, err := os.Create(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	w := zip.NewWriter(f)
+	toc, err := w.Create(tocFilename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := toc.Write([]byte(tocJSON)); err != nil {
+		t.Fatal(err)
+	}
+	for name, content := range files {
+		fw, err := w.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := fw.Write(content); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return filename
+}
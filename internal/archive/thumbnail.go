@@ -0,0 +1,48 @@
+package archive
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+)
+
+// FFmpegThumbnailer extracts the first frame of a video as a JPEG cover
+// by shelling out to the ffmpeg binary.
+type FFmpegThumbnailer struct {
+	// Path is the ffmpeg executable to run, defaulting to "ffmpeg" from $PATH.
+	Path string
+}
+
+// Thumbnail writes the video content to a temporary file and asks ffmpeg
+// to extract its first keyframe as a JPEG.
+func (t FFmpegThumbnailer) Thumbnail(content []byte) ([]byte, error) {
+	bin := t.Path
+	if bin == "" {
+		bin = "ffmpeg"
+	}
+
+	dir, err := ioutil.TempDir("", "igshelf-thumbnail")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create a temp dir: %w", err)
+	}
+	defer os.RemoveAll(dir)
+
+	src := filepath.Join(dir, "src.mp4")
+	if err = ioutil.WriteFile(src, content, 0600); err != nil {
+		return nil, fmt.Errorf("failed to write video to a temp file: %w", err)
+	}
+	dst := filepath.Join(dir, "cover.jpg")
+
+	cmd := exec.Command(bin, "-y", "-i", src, "-vframes", "1", "-f", "image2", dst)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return nil, fmt.Errorf("ffmpeg failed: %w: %s", err, out)
+	}
+
+	jpeg, err := ioutil.ReadFile(dst)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the generated thumbnail: %w", err)
+	}
+	return jpeg, nil
+}
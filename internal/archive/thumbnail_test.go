@@ -0,0 +1,42 @@
+package archive
+
+import (
+	"io/ioutil"
+	"os/exec"
+	"path/filepath"
+	"testing"
+)
+
+func TestFFmpegThumbnailer(t *testing.T) {
+	if _, err := exec.LookPath("ffmpeg"); err != nil {
+		t.Skip("ffmpeg is not installed")
+	}
+
+	// Generate a tiny sample video to feed into the thumbnailer.
+	dir := t.TempDir()
+	sample := filepath.Join(dir, "sample.mp4")
+	cmd := exec.Command("ffmpeg", "-y",
+		"-f", "lavfi", "-i", "color=c=blue:size=32x32:duration=1",
+		sample,
+	)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("failed to generate a sample video: %v: %s", err, out)
+	}
+	content, err := ioutil.ReadFile(sample)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tn := FFmpegThumbnailer{}
+	jpeg, err := tn.Thumbnail(content)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(jpeg) == 0 {
+		t.Fatal("expected a non-empty JPEG thumbnail")
+	}
+	// JPEG files start with the SOI marker 0xFFD8.
+	if jpeg[0] != 0xFF || jpeg[1] != 0xD8 {
+		t.Errorf("got file signature %x, want a JPEG (ffd8)", jpeg[:2])
+	}
+}
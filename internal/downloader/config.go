@@ -1,11 +1,38 @@
 package downloader
 
-import "github.com/go-kit/kit/log"
+import (
+	"os"
+)
 
 // ConfigOption configures the downloader service.
 type ConfigOption func(*Service)
 
-// WithMaxWorkers sets a max limit of workers to spawn when downloading photos/videos.
+// ExistingFilePolicy controls what Download does when a media file already
+// exists in the content directory, see WithExistingFilePolicy.
+type ExistingFilePolicy int
+
+const (
+	// ExistingFileSkip leaves an existing file untouched (modulo
+	// WithVerifyExisting) instead of re-downloading it. It's the default.
+	ExistingFileSkip ExistingFilePolicy = iota
+	// ExistingFileOverwrite always re-downloads a media's content and
+	// replaces whatever is already on disk.
+	ExistingFileOverwrite
+	// ExistingFileResume continues an existing file from where it left off
+	// via a Range request instead of restarting from scratch, which matters
+	// for large videos over a flaky connection. It falls back to a full
+	// re-download when the underlying media service can't resume, or when
+	// the CDN doesn't honor the range. It only supports the default local
+	// filesystem: Download rejects it outright when combined with a
+	// non-default WithFS, since resuming writes straight to disk rather
+	// than through the injected WriteFS.
+	ExistingFileResume
+)
+
+// WithMaxWorkers sets a max limit of workers to spawn when downloading
+// photos/videos. n <= 0 (including the zero value, so an unset flag
+// defaulting to 0 is safe) picks a worker count automatically based on
+// runtime.NumCPU instead of deadlocking on a zero-capacity semaphore.
 func WithMaxWorkers(n int) ConfigOption {
 	return func(s *Service) {
 		s.maxWorkers = n
@@ -13,8 +40,183 @@ func WithMaxWorkers(n int) ConfigOption {
 }
 
 // WithLogger configures a logger to debug media files downloading.
-func WithLogger(l log.Logger) ConfigOption {
+func WithLogger(l Logger) ConfigOption {
 	return func(r *Service) {
 		r.logger = l
 	}
 }
+
+// WithFileMode sets the permission bits downloaded media files are written with.
+func WithFileMode(mode os.FileMode) ConfigOption {
+	return func(s *Service) {
+		s.fileMode = mode
+	}
+}
+
+// WithMetrics registers a callback invoked once Download finishes with
+// aggregate counts and byte totals, suitable for logging or monitoring.
+func WithMetrics(fn func(Stats)) ConfigOption {
+	return func(s *Service) {
+		s.onMetrics = fn
+	}
+}
+
+// WithProgress makes Download report downloaded bytes to p as workers
+// finish content downloads, so a caller can poll p.Percent, p.Rate, or
+// p.ETA (or just p.String) to render live progress while Download is
+// still running, e.g. from a separate goroutine ticking once a second.
+func WithProgress(p *Progress) ConfigOption {
+	return func(s *Service) {
+		s.progress = p
+	}
+}
+
+// WithContentManifest makes Download write a content/index.json manifest
+// mapping each downloaded file's Filename to its ID, Type, Caption, and
+// TakenAt, independent of timeline.json, so the content directory is
+// self-describing if it's moved elsewhere on its own.
+func WithContentManifest(enabled bool) ConfigOption {
+	return func(s *Service) {
+		s.contentManifest = enabled
+	}
+}
+
+// WithIncremental makes Download fetch only media taken after the newest
+// TakenAt already stored, merging it on top of the existing timeline,
+// instead of re-fetching everything on every run.
+func WithIncremental(enabled bool) ConfigOption {
+	return func(s *Service) {
+		s.incremental = enabled
+	}
+}
+
+// WithThumbnailWorkers sets a max limit of concurrent image thumbnail
+// generations, separate from WithMaxWorkers, so CPU-bound thumbnailing
+// doesn't compete with IO-bound downloads for the same worker budget.
+func WithThumbnailWorkers(n int) ConfigOption {
+	return func(s *Service) {
+		s.thumbnailWorkers = n
+	}
+}
+
+// WithImageThumbnails makes Download generate a downscaled JPEG thumbnail
+// (longest side at most maxDim pixels) alongside each downloaded image,
+// so a gallery can show a small preview instead of the full-res file.
+func WithImageThumbnails(maxDim int) ConfigOption {
+	return func(s *Service) {
+		s.imageThumbnailMaxDim = maxDim
+	}
+}
+
+// WithStripRemoteLocations makes Download rewrite each successfully
+// downloaded media's Location from the Instagram service's (possibly
+// signed and expiring) URL to its local Filename, then re-store
+// timeline.json once downloading finishes. This makes timeline.json
+// portable and lets a template link to local files directly instead of
+// a CDN URL that may no longer resolve.
+func WithStripRemoteLocations(enabled bool) ConfigOption {
+	return func(s *Service) {
+		s.stripRemoteLocations = enabled
+	}
+}
+
+// WithFS overrides the filesystem Download reads and writes media through.
+// It defaults to the local disk; pass an in-memory or cloud-storage-backed
+// WriteFS to redirect where content ends up, e.g. to unit test Download
+// without touching disk. It's incompatible with WithExistingFilePolicy's
+// ExistingFileResume, since resuming writes to the local disk directly;
+// see ExistingFileResume.
+func WithFS(fsys WriteFS) ConfigOption {
+	return func(s *Service) {
+		s.fs = fsys
+	}
+}
+
+// WithDateDirs makes Download nest each media file under a
+// content/YYYY/MM/ subdirectory based on its TakenAt, instead of a single
+// flat content directory, so a gallery with tens of thousands of files
+// doesn't end up with one directory too large to list quickly.
+func WithDateDirs(enabled bool) ConfigOption {
+	return func(s *Service) {
+		s.dateDirs = enabled
+	}
+}
+
+// WithTypes restricts Download to media whose Type (e.g. igshelf.MediaTypeImage)
+// is in types, e.g. to fetch only videos. An album child keeps its own type,
+// so children are filtered independently of their parent's synthetic
+// CAROUSEL_ALBUM type. Media excluded this way is still recorded in
+// timeline.json, only its file isn't fetched. All types are downloaded
+// unless this option is given.
+func WithTypes(types ...string) ConfigOption {
+	return func(s *Service) {
+		s.types = make(map[string]struct{}, len(types))
+		for _, t := range types {
+			s.types[t] = struct{}{}
+		}
+	}
+}
+
+// WithSidecars makes Download write a <Filename>.json sidecar next to each
+// downloaded media file, containing its ID, Caption, TakenAt, and
+// Permalink, so the file is self-describing when imported into another
+// photo manager that doesn't understand timeline.json.
+func WithSidecars(enabled bool) ConfigOption {
+	return func(s *Service) {
+		s.sidecars = enabled
+	}
+}
+
+// WithWriteExif makes Download stamp each downloaded JPEG's Exif
+// DateTimeOriginal tag with its Media.TakenAt, since many Instagram images
+// arrive with no Exif date at all, which otherwise leaves photo managers
+// like Lightroom sorting them by download time instead of when they were
+// taken. Videos are left untouched.
+func WithWriteExif(enabled bool) ConfigOption {
+	return func(s *Service) {
+		s.writeExif = enabled
+	}
+}
+
+// WithStopQueuing makes Download stop enqueuing new media for download once
+// stop is closed, while letting downloads already in flight run to
+// completion, instead of aborting them the way canceling ctx does. This
+// lets a caller implement a two-stage interrupt: a first signal stops
+// queuing new work, a second (or canceling ctx directly) cancels
+// immediately.
+func WithStopQueuing(stop <-chan struct{}) ConfigOption {
+	return func(s *Service) {
+		s.stopQueuing = stop
+	}
+}
+
+// WithVerifyExisting makes the downloader hash an existing local file and
+// compare it against the stored Media.Checksum before skipping it,
+// so a file corrupted on a prior run gets re-downloaded.
+// It's off by default since hashing every file is slow.
+func WithVerifyExisting(enabled bool) ConfigOption {
+	return func(s *Service) {
+		s.verifyExisting = enabled
+	}
+}
+
+// WithExistingFilePolicy sets what Download does when a media file already
+// exists in the content directory: ExistingFileSkip (the default) leaves it
+// untouched, ExistingFileOverwrite always re-downloads it, and
+// ExistingFileResume continues it via a Range request.
+func WithExistingFilePolicy(policy ExistingFilePolicy) ConfigOption {
+	return func(s *Service) {
+		s.existingFilePolicy = policy
+	}
+}
+
+// WithDestination sets the directory Download reads an optional .igignore
+// file from, i.e. the directory holding timeline.json rather than
+// contentDirPath's content subdirectory, since that's where a user
+// naturally keeps files alongside the timeline. It defaults to
+// contentDirPath when unset.
+func WithDestination(dir string) ConfigOption {
+	return func(s *Service) {
+		s.destination = dir
+	}
+}
@@ -0,0 +1,32 @@
+package downloader
+
+import (
+	"path/filepath"
+
+	"github.com/marselester/igshelf"
+)
+
+// assignDateDirs rewrites every media's Filename (and ThumbnailFilename, if
+// any) to nest it under a TakenAt-derived YYYY/MM subdirectory, see
+// WithDateDirs. It runs before timeline.json is stored, so the recorded
+// Filename already matches where Download will write the file, and a
+// template linking through Media.ContentPath still doesn't need to know about
+// the nested layout.
+func assignDateDirs(timeline []*igshelf.Media) {
+	for _, m := range timeline {
+		assignDateDir(m)
+		for _, c := range m.Children {
+			assignDateDir(c)
+		}
+	}
+}
+
+func assignDateDir(m *igshelf.Media) {
+	dir := m.TakenAt.Format("2006/01")
+	if m.Filename != "" {
+		m.Filename = filepath.Join(dir, m.Filename)
+	}
+	if m.ThumbnailFilename != "" {
+		m.ThumbnailFilename = filepath.Join(dir, m.ThumbnailFilename)
+	}
+}
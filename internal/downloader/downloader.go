@@ -3,12 +3,19 @@ package downloader
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
 	"fmt"
-	"io/ioutil"
 	"os"
 	"path/filepath"
+	"runtime"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
 
-	"github.com/go-kit/kit/log"
 	"golang.org/x/sync/errgroup"
 
 	"github.com/marselester/igshelf"
@@ -17,6 +24,21 @@ import (
 const (
 	// defaultMaxWorkers is a max number of workers to spawn when downloading media files.
 	defaultMaxWorkers = 10
+	// autoMaxWorkersMultiplier scales runtime.NumCPU() into a worker count
+	// when WithMaxWorkers(0) asks for it to be chosen automatically, see
+	// WithMaxWorkers. Downloading is IO-bound, so a multiple of the CPU
+	// count keeps enough requests in flight without spawning an unbounded
+	// (and unfriendly to the remote server) number of workers.
+	autoMaxWorkersMultiplier = 4
+	// defaultThumbnailWorkers is a max number of concurrent image thumbnail
+	// generations unless a different one is set with WithThumbnailWorkers.
+	defaultThumbnailWorkers = 4
+	// defaultFileMode is the permission bits media files are written with
+	// unless a different one is set with WithFileMode.
+	defaultFileMode = 0600
+	// defaultDirMode is the permission bits directories created by
+	// WithDateDirs are given.
+	defaultDirMode = 0700
 )
 
 // Service is a service that copies Instagram timeline using media service
@@ -24,28 +46,152 @@ const (
 type Service struct {
 	ig     igshelf.MediaService
 	db     igshelf.MediaRepository
-	logger log.Logger
+	logger Logger
+
+	// fs is the filesystem media content is read from and written to, see WithFS.
+	fs WriteFS
 
 	maxWorkers int
 	// sem is a semaphore that limits count of workers that copy media files.
 	// Acquire this semaphore by sending a token, and release it by discarding a token.
 	sem chan token
+
+	// thumbnailWorkers limits how many image thumbnails are generated
+	// concurrently, see WithThumbnailWorkers.
+	thumbnailWorkers int
+	// thumbnailSem is a semaphore that limits count of workers generating
+	// image thumbnails, separate from sem so CPU-bound thumbnailing doesn't
+	// compete with IO-bound download workers for the same budget.
+	thumbnailSem chan token
+	// thumbnailFn generates an image thumbnail; overridable in tests.
+	thumbnailFn func(content []byte, maxDim int) ([]byte, error)
+
+	// verifyExisting hashes an existing file before skipping it, see WithVerifyExisting.
+	verifyExisting bool
+	// existingFilePolicy controls what happens to a media file that already
+	// exists in the content directory, see WithExistingFilePolicy.
+	existingFilePolicy ExistingFilePolicy
+	// onMetrics is called with aggregate Stats once Download finishes, see WithMetrics.
+	onMetrics func(Stats)
+	// progress, if set, is updated with downloaded bytes as workers finish
+	// content downloads, see WithProgress.
+	progress *Progress
+
+	// fileMode is the permission bits written media files get, see WithFileMode.
+	fileMode os.FileMode
+
+	// contentManifest makes Download write a content/index.json manifest,
+	// see WithContentManifest.
+	contentManifest bool
+
+	// incremental makes Download fetch only media newer than what's already
+	// stored, see WithIncremental.
+	incremental bool
+
+	// imageThumbnailMaxDim is the longest side (in pixels) of a generated
+	// image thumbnail, see WithImageThumbnails. Zero disables generation.
+	imageThumbnailMaxDim int
+
+	// stripRemoteLocations makes Download rewrite Location to the local
+	// Filename after a successful download, see WithStripRemoteLocations.
+	stripRemoteLocations bool
+
+	// dateDirs makes Download nest media files under content/YYYY/MM/,
+	// see WithDateDirs.
+	dateDirs bool
+
+	// types restricts Download to media of these types, see WithTypes.
+	// All types are downloaded when it's empty.
+	types map[string]struct{}
+
+	// sidecars makes Download write a <Filename>.json sidecar next to
+	// each downloaded media file, see WithSidecars.
+	sidecars bool
+
+	// writeExif makes Download stamp downloaded JPEGs' Exif
+	// DateTimeOriginal, see WithWriteExif.
+	writeExif bool
+
+	// stopQueuing, once closed, makes Download stop enqueuing new media
+	// while letting in-flight downloads finish, see WithStopQueuing.
+	stopQueuing <-chan struct{}
+
+	// destination is the directory Download reads an optional .igignore
+	// file from, see WithDestination. It defaults to contentDirPath when unset.
+	destination string
 }
 type token struct{}
 
+// pageReporter is implemented by media iterators that expose pagination
+// progress, e.g. instagram.MediaIter, so Download can log page numbers
+// during a long pull without every igshelf.MediaIter needing to support it.
+type pageReporter interface {
+	Page() int
+}
+
+// resumableDownloader is implemented by media services that can continue an
+// existing file via a Range request, e.g. instagram.MediaService, so
+// ExistingFileResume can use it without every igshelf.MediaService needing
+// to support it.
+type resumableDownloader interface {
+	DownloadResumable(ctx context.Context, m *igshelf.Media, path string) error
+}
+
+// Stats holds aggregate counts and byte totals for a Download run.
+type Stats struct {
+	// Downloaded is a number of media files fetched successfully.
+	Downloaded int64
+	// Skipped is a number of media files that already existed and were left untouched.
+	Skipped int64
+	// Failed is a number of media files that could not be fetched.
+	Failed int64
+	// TotalBytes is the sum of downloaded content sizes (media files only, not thumbnails).
+	TotalBytes int64
+}
+
+// Report summarizes a Download run, including one interrupted by a canceled context.
+type Report struct {
+	Stats
+	// Complete is false if the run was interrupted (e.g. by SIGINT)
+	// before every media file was attempted.
+	Complete bool
+	// Failures lists media that were missing or failed to download, so a
+	// caller can persist them (e.g. as report.json) instead of only logging.
+	Failures []FailedMedia
+}
+
+// FailedMedia records a single media that couldn't be downloaded.
+type FailedMedia struct {
+	// ID is the media's ID, see igshelf.Media.
+	ID string `json:"id"`
+	// Reason is the error that caused the download to fail.
+	Reason string `json:"reason"`
+	// TakenAt is the media's publish date, see igshelf.Media.
+	TakenAt time.Time `json:"taken_at"`
+}
+
 // NewService creates a service to copy Instagram timeline.
 func NewService(ig igshelf.MediaService, db igshelf.MediaRepository, options ...ConfigOption) *Service {
 	s := Service{
 		ig:     ig,
 		db:     db,
-		logger: log.NewNopLogger(),
+		logger: nopLogger{},
+		fs:     osFS{},
 
 		maxWorkers: defaultMaxWorkers,
+		fileMode:   defaultFileMode,
+
+		thumbnailWorkers: defaultThumbnailWorkers,
+		thumbnailFn:      scaleImageThumbnail,
 	}
 	for _, opt := range options {
 		opt(&s)
 	}
+	if s.maxWorkers <= 0 {
+		s.maxWorkers = runtime.NumCPU() * autoMaxWorkersMultiplier
+	}
 	s.sem = make(chan token, s.maxWorkers)
+	s.thumbnailSem = make(chan token, s.thumbnailWorkers)
 	return &s
 }
 
@@ -54,22 +200,117 @@ func NewService(ig igshelf.MediaService, db igshelf.MediaRepository, options ...
 // After that it copies media files concurrently.
 // It doesn't stop if one of the files was not copied due to an error.
 // For example, media.json might list a file which actually wasn't included into the archive.
-func (s *Service) Download(ctx context.Context, contentDirPath string) error {
+//
+// If ctx is canceled mid-run, Download stops early and returns a Report with
+// Complete set to false, rather than an error, so a caller can render what
+// was downloaded so far. timeline.json (and the skip-existing logic) let the
+// next run resume where this one left off.
+func (s *Service) Download(ctx context.Context, contentDirPath string) (Report, error) {
+	// DownloadResumable is implemented against the local disk (os.Stat,
+	// os.OpenFile), not the injected WriteFS, so resuming with a non-default
+	// WithFS (e.g. an in-memory or cloud-storage backend) would write the
+	// resumed content somewhere the rest of the pipeline can't see it.
+	if s.existingFilePolicy == ExistingFileResume {
+		if _, ok := s.fs.(osFS); !ok {
+			return Report{}, fmt.Errorf("existing file policy: ExistingFileResume only supports the default local filesystem, not a WithFS override")
+		}
+	}
+
+	// When incremental, only media taken after the newest stored TakenAt is
+	// fetched, and the new media is merged on top of what's already there.
+	var (
+		existing []*igshelf.Media
+		since    time.Time
+	)
+	if s.incremental {
+		if prior, err := s.db.List(); err == nil {
+			existing = prior
+			for _, m := range existing {
+				if m.TakenAt.After(since) {
+					since = m.TakenAt
+				}
+			}
+		}
+	}
+
 	var timeline []*igshelf.Media
-	iter := s.ig.List(ctx)
+	iter := s.ig.List(ctx, since)
+	pr, reportsPages := iter.(pageReporter)
+	var lastPage int
 	for iter.Next() {
 		timeline = append(timeline, iter.Media())
+		if reportsPages {
+			if page := pr.Page(); page != lastPage {
+				lastPage = page
+				s.logger.Log("level", "debug", "msg", "fetched page", "page", page)
+			}
+		}
 	}
 	if iter.Err() != nil {
-		return fmt.Errorf("failed to fetch the timeline: %w", iter.Err())
+		return Report{}, fmt.Errorf("failed to fetch the timeline: %w", iter.Err())
+	}
+
+	// A media service is expected to yield media newest first, but the API
+	// occasionally returns a page slightly out of order (e.g. around DST
+	// boundaries), so restore strict order defensively before storing it.
+	sort.SliceStable(timeline, func(i, j int) bool {
+		return timeline[i].TakenAt.After(timeline[j].TakenAt)
+	})
+
+	if len(existing) > 0 {
+		timeline = append(timeline, existing...)
+	}
+
+	if s.imageThumbnailMaxDim > 0 {
+		assignImageThumbnailFilenames(timeline)
+	}
+	if s.dateDirs {
+		assignDateDirs(timeline)
 	}
 
 	if err := s.db.Store(timeline); err != nil {
-		return fmt.Errorf("failed to store the timeline: %w", err)
+		return Report{}, fmt.Errorf("failed to store the timeline: %w", err)
+	}
+
+	// Media listed in .igignore is still recorded above, but its file is never fetched.
+	ignoreDir := s.destination
+	if ignoreDir == "" {
+		ignoreDir = contentDirPath
+	}
+	ignore, err := loadIgnoreList(ignoreDir)
+	if err != nil {
+		return Report{}, fmt.Errorf("failed to read %s: %w", igignoreFilename, err)
 	}
 
 	g, ctx := errgroup.WithContext(ctx)
 	mediac := make(chan *igshelf.Media, s.maxWorkers)
+	var stats Stats
+	if s.onMetrics != nil {
+		defer func() { s.onMetrics(stats) }()
+	}
+
+	var (
+		manifestMu sync.Mutex
+		manifest   []*igshelf.Media
+
+		failuresMu sync.Mutex
+		failures   []FailedMedia
+	)
+	recordManifest := func(m *igshelf.Media) {
+		if !s.contentManifest {
+			return
+		}
+		manifestMu.Lock()
+		manifest = append(manifest, m)
+		manifestMu.Unlock()
+	}
+	if s.contentManifest {
+		defer func() {
+			if err := writeContentManifest(s.fs, contentDirPath, manifest, s.fileMode); err != nil {
+				s.logger.Log("msg", "failed to write content manifest", "err", err)
+			}
+		}()
+	}
 
 	// Line up all the media (including children) for downloading.
 	g.Go(func() error {
@@ -77,14 +318,39 @@ func (s *Service) Download(ctx context.Context, contentDirPath string) error {
 
 		for _, m := range timeline {
 			select {
-			case mediac <- m:
-			case <-ctx.Done():
-				return ctx.Err()
+			case <-s.stopQueuing:
+				return nil
+			default:
+			}
+
+			switch {
+			// A zip archive has no notion of albums, so igshelf synthesizes a
+			// CAROUSEL_ALBUM parent to group children under without a file of
+			// its own; only its children are ever downloaded.
+			case m.Type == igshelf.MediaTypeAlbum && m.Filename == "":
+			case ignore.Match(m):
+				atomic.AddInt64(&stats.Skipped, 1)
+			case !s.typeAllowed(m):
+				atomic.AddInt64(&stats.Skipped, 1)
+			default:
+				select {
+				case mediac <- m:
+				case <-s.stopQueuing:
+					return nil
+				case <-ctx.Done():
+					return ctx.Err()
+				}
 			}
 
 			for _, m = range m.Children {
+				if ignore.Match(m) || !s.typeAllowed(m) {
+					atomic.AddInt64(&stats.Skipped, 1)
+					continue
+				}
 				select {
 				case mediac <- m:
+				case <-s.stopQueuing:
+					return nil
 				case <-ctx.Done():
 					return ctx.Err()
 				}
@@ -103,31 +369,278 @@ func (s *Service) Download(ctx context.Context, contentDirPath string) error {
 		g.Go(func() error {
 			defer func() { <-s.sem }()
 
-			// Zip archive doesn't contain albums.
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			// A media already queued when stopQueuing closed is treated as
+			// not yet in flight and skipped, so only downloads that were
+			// already under way when the signal arrived get to finish.
+			select {
+			case <-s.stopQueuing:
+				return nil
+			default:
+			}
+
+			// The producer already skips enqueuing synthetic album parents;
+			// this is a defensive backstop against any other media with no
+			// file of its own slipping through.
 			if m.Filename == "" {
 				return nil
 			}
 
+			if m.Unavailable {
+				s.logger.Log("level", "debug", "msg", "media unavailable, skipping download", "media", m)
+				atomic.AddInt64(&stats.Skipped, 1)
+				return nil
+			}
+
 			contentPath := filepath.Join(contentDirPath, m.Filename)
-			// Skip downloading if the media file already exists.
-			_, err := os.Stat(contentPath)
-			if os.IsExist(err) {
+			// Stat, not IsExist: IsExist matches errors like "file already
+			// exists" from Create, not the nil error Stat returns for a file
+			// that exists.
+			_, statErr := s.fs.Stat(contentPath)
+			exists := statErr == nil
+
+			if exists && s.existingFilePolicy == ExistingFileResume {
+				if rd, ok := s.ig.(resumableDownloader); ok {
+					if err := rd.DownloadResumable(ctx, m, contentPath); err != nil {
+						s.logger.Log("msg", "failed to resume media content", "media", m, "err", err)
+						atomic.AddInt64(&stats.Failed, 1)
+						failuresMu.Lock()
+						failures = append(failures, FailedMedia{ID: m.ID, Reason: err.Error(), TakenAt: m.TakenAt})
+						failuresMu.Unlock()
+						return nil
+					}
+
+					var size int64
+					if b, err := readFile(s.fs, contentPath); err == nil {
+						size = int64(len(b))
+						sum := sha256.Sum256(b)
+						m.Checksum = hex.EncodeToString(sum[:])
+					}
+					atomic.AddInt64(&stats.Downloaded, 1)
+					atomic.AddInt64(&stats.TotalBytes, size)
+					if s.progress != nil {
+						s.progress.Add(size)
+					}
+					recordManifest(m)
+					return nil
+				}
+				// The media service can't resume, so fall through to a full
+				// re-download below (as if the policy were
+				// ExistingFileOverwrite) rather than silently leaving a
+				// possibly incomplete file in place.
+				exists = false
+			}
+
+			if exists && s.existingFilePolicy == ExistingFileSkip {
+				if !s.verifyExisting || m.Checksum == "" {
+					atomic.AddInt64(&stats.Skipped, 1)
+					recordManifest(m)
+					return nil
+				}
+				// A prior run may have left a corrupted or truncated file behind,
+				// so re-download it when its hash doesn't match the stored checksum.
+				match, err := fileChecksumMatches(s.fs, contentPath, m.Checksum)
+				if err == nil && match {
+					atomic.AddInt64(&stats.Skipped, 1)
+					recordManifest(m)
+					return nil
+				}
+			}
+
+			content, thumbnail, err := s.ig.Download(ctx, m)
+			if err != nil {
+				s.logger.Log("msg", "failed to download media content", "media", m, "err", err)
+				atomic.AddInt64(&stats.Failed, 1)
+				failuresMu.Lock()
+				failures = append(failures, FailedMedia{ID: m.ID, Reason: err.Error(), TakenAt: m.TakenAt})
+				failuresMu.Unlock()
+				return nil
+			}
+			// A nil content with no error means the media service reported
+			// the content unchanged (e.g. a 304 from a conditional request),
+			// so the local file already on disk is left as-is.
+			if content == nil {
+				atomic.AddInt64(&stats.Skipped, 1)
+				recordManifest(m)
+				return nil
+			}
+
+			if s.writeExif && m.Type == igshelf.MediaTypeImage {
+				if withExif, err := writeExifDateTimeOriginal(content, m.TakenAt); err != nil {
+					s.logger.Log("msg", "failed to write Exif DateTimeOriginal", "media", m, "err", err)
+				} else {
+					content = withExif
+				}
+			}
+
+			if s.dateDirs {
+				if err = s.fs.MkdirAll(filepath.Dir(contentPath), defaultDirMode); err != nil {
+					return fmt.Errorf("failed to create content directory for %s: %w", m.ID, err)
+				}
+			}
+			if err = writeFile(s.fs, contentPath, content, s.fileMode); err != nil {
+				return fmt.Errorf("failed to store media content %s: %w", m.ID, err)
+			}
+			if s.sidecars {
+				if err = writeSidecar(s.fs, contentPath, m, s.fileMode); err != nil {
+					return fmt.Errorf("failed to write sidecar for %s: %w", m.ID, err)
+				}
+			}
+			sum := sha256.Sum256(content)
+			m.Checksum = hex.EncodeToString(sum[:])
+			if s.stripRemoteLocations {
+				m.Location = m.Filename
+			}
+			atomic.AddInt64(&stats.Downloaded, 1)
+			atomic.AddInt64(&stats.TotalBytes, int64(len(content)))
+			if s.progress != nil {
+				s.progress.Add(int64(len(content)))
+			}
+			recordManifest(m)
+
+			if thumbnail != nil {
+				thumbnailPath := filepath.Join(contentDirPath, m.ThumbnailFilename)
+				if err = writeFile(s.fs, thumbnailPath, thumbnail, s.fileMode); err != nil {
+					return fmt.Errorf("failed to store media thumbnail %s: %w", m.ID, err)
+				}
+			} else if s.imageThumbnailMaxDim > 0 && m.Type == igshelf.MediaTypeImage && m.ThumbnailFilename != "" {
+				// Videos get their cover from the media service; images don't
+				// have one, so it's generated locally by downscaling the
+				// content, bounded by a separate worker pool since it's
+				// CPU-bound rather than IO-bound like the download itself.
+				s.thumbnailSem <- token{}
+				thumbnail, err := s.thumbnailFn(content, s.imageThumbnailMaxDim)
+				<-s.thumbnailSem
+				if err != nil {
+					s.logger.Log("msg", "failed to generate image thumbnail", "media", m, "err", err)
+				} else {
+					thumbnailPath := filepath.Join(contentDirPath, m.ThumbnailFilename)
+					if err = writeFile(s.fs, thumbnailPath, thumbnail, s.fileMode); err != nil {
+						return fmt.Errorf("failed to store image thumbnail %s: %w", m.ID, err)
+					}
+				}
+			}
+
+			return nil
+		})
+	}
+
+	err = g.Wait()
+	var stoppedQueuing bool
+	select {
+	case <-s.stopQueuing:
+		stoppedQueuing = true
+	default:
+	}
+	report := Report{Stats: stats, Complete: !errors.Is(err, context.Canceled) && !stoppedQueuing, Failures: failures}
+	if err != nil && !errors.Is(err, context.Canceled) {
+		return report, err
+	}
+
+	// Locations were only rewritten in memory as files were downloaded above,
+	// so timeline.json must be re-stored to persist them.
+	if s.stripRemoteLocations {
+		if err := s.db.Store(timeline); err != nil {
+			return report, fmt.Errorf("failed to store the timeline: %w", err)
+		}
+	}
+
+	return report, nil
+}
+
+// DownloadIDs fetches and stores content for specific media, resolved one
+// by one via the media service's Get method, instead of pulling the whole
+// timeline with List. It's meant for targeted recovery, e.g. re-fetching
+// the "four missing files" from a prior Download run without repeating it
+// in full. Unlike Download, it doesn't read or write timeline.json, so a
+// caller relying on it (e.g. for a subsequent gallery render) should
+// already have it from an earlier Download.
+func (s *Service) DownloadIDs(ctx context.Context, contentDirPath string, ids []string) (Report, error) {
+	g, ctx := errgroup.WithContext(ctx)
+	idc := make(chan string, s.maxWorkers)
+	var stats Stats
+	if s.onMetrics != nil {
+		defer func() { s.onMetrics(stats) }()
+	}
+
+	var (
+		failuresMu sync.Mutex
+		failures   []FailedMedia
+	)
+
+	g.Go(func() error {
+		defer close(idc)
+		for _, id := range ids {
+			select {
+			case idc <- id:
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+		return nil
+	})
+
+	for id := range idc {
+		s.sem <- token{}
+
+		id := id
+		g.Go(func() error {
+			defer func() { <-s.sem }()
+
+			m, err := s.ig.Get(ctx, id)
+			if err != nil {
+				s.logger.Log("msg", "failed to resolve media", "id", id, "err", err)
+				atomic.AddInt64(&stats.Failed, 1)
+				failuresMu.Lock()
+				failures = append(failures, FailedMedia{ID: id, Reason: err.Error()})
+				failuresMu.Unlock()
+				return nil
+			}
+			if !s.typeAllowed(m) || m.Filename == "" {
+				atomic.AddInt64(&stats.Skipped, 1)
+				return nil
+			}
+
+			if m.Unavailable {
+				s.logger.Log("level", "debug", "msg", "media unavailable, skipping download", "media", m)
+				atomic.AddInt64(&stats.Skipped, 1)
 				return nil
 			}
 
 			content, thumbnail, err := s.ig.Download(ctx, m)
 			if err != nil {
 				s.logger.Log("msg", "failed to download media content", "media", m, "err", err)
+				atomic.AddInt64(&stats.Failed, 1)
+				failuresMu.Lock()
+				failures = append(failures, FailedMedia{ID: m.ID, Reason: err.Error(), TakenAt: m.TakenAt})
+				failuresMu.Unlock()
+				return nil
+			}
+			if content == nil {
+				atomic.AddInt64(&stats.Skipped, 1)
 				return nil
 			}
 
-			if err = ioutil.WriteFile(contentPath, content, 0600); err != nil {
+			contentPath := filepath.Join(contentDirPath, m.Filename)
+			if s.dateDirs {
+				if err = s.fs.MkdirAll(filepath.Dir(contentPath), defaultDirMode); err != nil {
+					return fmt.Errorf("failed to create content directory for %s: %w", m.ID, err)
+				}
+			}
+			if err = writeFile(s.fs, contentPath, content, s.fileMode); err != nil {
 				return fmt.Errorf("failed to store media content %s: %w", m.ID, err)
 			}
+			atomic.AddInt64(&stats.Downloaded, 1)
+			atomic.AddInt64(&stats.TotalBytes, int64(len(content)))
+			if s.progress != nil {
+				s.progress.Add(int64(len(content)))
+			}
 
 			if thumbnail != nil {
 				thumbnailPath := filepath.Join(contentDirPath, m.ThumbnailFilename)
-				if err = ioutil.WriteFile(thumbnailPath, thumbnail, 0600); err != nil {
+				if err = writeFile(s.fs, thumbnailPath, thumbnail, s.fileMode); err != nil {
 					return fmt.Errorf("failed to store media thumbnail %s: %w", m.ID, err)
 				}
 			}
@@ -136,5 +649,60 @@ func (s *Service) Download(ctx context.Context, contentDirPath string) error {
 		})
 	}
 
-	return g.Wait()
+	err := g.Wait()
+	report := Report{Stats: stats, Complete: !errors.Is(err, context.Canceled), Failures: failures}
+	if err != nil && !errors.Is(err, context.Canceled) {
+		return report, err
+	}
+	return report, nil
+}
+
+// typeAllowed reports whether m's Type passes the WithTypes filter. Every
+// type is allowed unless WithTypes was given.
+func (s *Service) typeAllowed(m *igshelf.Media) bool {
+	if len(s.types) == 0 {
+		return true
+	}
+	_, ok := s.types[m.Type]
+	return ok
+}
+
+// manifestEntry is a content/index.json record describing a single downloaded file.
+type manifestEntry struct {
+	ID      string    `json:"id"`
+	Type    string    `json:"type"`
+	Caption string    `json:"caption"`
+	TakenAt time.Time `json:"taken_at"`
+}
+
+// writeContentManifest writes content/index.json, mapping each media's
+// Filename to enough metadata to identify it without timeline.json,
+// so the content directory is self-describing if moved elsewhere.
+func writeContentManifest(fsys WriteFS, contentDirPath string, mm []*igshelf.Media, mode os.FileMode) error {
+	manifest := make(map[string]manifestEntry, len(mm))
+	for _, m := range mm {
+		manifest[m.Filename] = manifestEntry{
+			ID:      m.ID,
+			Type:    m.Type,
+			Caption: m.Caption,
+			TakenAt: m.TakenAt,
+		}
+	}
+
+	b, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFile(fsys, filepath.Join(contentDirPath, "index.json"), b, mode)
+}
+
+// fileChecksumMatches reports whether the file at path hashes to want,
+// a SHA-256 hex digest.
+func fileChecksumMatches(fsys WriteFS, path, want string) (bool, error) {
+	b, err := readFile(fsys, path)
+	if err != nil {
+		return false, err
+	}
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:]) == want, nil
 }
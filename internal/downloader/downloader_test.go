@@ -1,11 +1,24 @@
 package downloader
 
 import (
+	"bytes"
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
 	"fmt"
+	"image"
+	"image/jpeg"
+	"io"
 	"io/ioutil"
 	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/google/go-cmp/cmp"
 
@@ -13,6 +26,60 @@ import (
 	"github.com/marselester/igshelf/internal/mock"
 )
 
+// TestMaxWorkersAutoWhenZero checks that WithMaxWorkers(0) picks a worker
+// count based on runtime.NumCPU instead of leaving the service with a
+// zero-capacity semaphore, which would deadlock the first Download call.
+func TestMaxWorkersAutoWhenZero(t *testing.T) {
+	ig := mock.MediaService{}
+	db := mock.MediaRepository{}
+	s := NewService(&ig, &db, WithMaxWorkers(0))
+
+	want := runtime.NumCPU() * autoMaxWorkersMultiplier
+	if s.maxWorkers != want {
+		t.Errorf("got %d workers, want %d", s.maxWorkers, want)
+	}
+	if got := cap(s.sem); got != want {
+		t.Errorf("got semaphore capacity %d, want %d", got, want)
+	}
+}
+
+// TestMaxWorkersZeroDoesNotDeadlock exercises a real Download call with
+// WithMaxWorkers(0) to prove media files are actually copied, not just
+// that maxWorkers is nonzero.
+func TestMaxWorkersZeroDoesNotDeadlock(t *testing.T) {
+	timeline := []*igshelf.Media{
+		{ID: "1", Type: igshelf.MediaTypeImage, Filename: "1.jpg"},
+		{ID: "2", Type: igshelf.MediaTypeImage, Filename: "2.jpg"},
+	}
+	t.Cleanup(func() {
+		for _, m := range timeline {
+			os.Remove(filepath.Join("testdata", m.Filename))
+		}
+	})
+
+	ig := mock.MediaService{
+		ListFn: func(since time.Time) igshelf.MediaIter {
+			return &mock.MediaIter{Batch: timeline}
+		},
+		DownloadFn: func(m *igshelf.Media) ([]byte, []byte, error) {
+			return []byte("content"), nil, nil
+		},
+	}
+	db := mock.MediaRepository{}
+	s := NewService(&ig, &db, WithMaxWorkers(0))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	report, err := s.Download(ctx, "testdata")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := report.Downloaded, int64(len(timeline)); got != want {
+		t.Errorf("got %d downloaded, want %d", got, want)
+	}
+}
+
 func TestTimelineIsStored(t *testing.T) {
 	want := []*igshelf.Media{{
 		ID:                "17863188140095492",
@@ -24,7 +91,7 @@ func TestTimelineIsStored(t *testing.T) {
 	}}
 
 	ig := mock.MediaService{
-		ListFn: func() igshelf.MediaIter {
+		ListFn: func(since time.Time) igshelf.MediaIter {
 			return &mock.MediaIter{Batch: want}
 		},
 		DownloadFn: func(m *igshelf.Media) ([]byte, []byte, error) {
@@ -39,7 +106,7 @@ func TestTimelineIsStored(t *testing.T) {
 	}}
 	s := NewService(&ig, &db)
 
-	err := s.Download(context.Background(), "testdata")
+	_, err := s.Download(context.Background(), "testdata")
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -68,7 +135,7 @@ func TestDownload(t *testing.T) {
 	})
 
 	ig := mock.MediaService{
-		ListFn: func() igshelf.MediaIter {
+		ListFn: func(since time.Time) igshelf.MediaIter {
 			return &mock.MediaIter{Batch: timeline}
 		},
 		DownloadFn: func(m *igshelf.Media) ([]byte, []byte, error) {
@@ -78,7 +145,7 @@ func TestDownload(t *testing.T) {
 	db := mock.MediaRepository{}
 	s := NewService(&ig, &db)
 
-	err := s.Download(context.Background(), "testdata")
+	_, err := s.Download(context.Background(), "testdata")
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -99,3 +166,1299 @@ func TestDownload(t *testing.T) {
 		t.Errorf(diff)
 	}
 }
+
+// TestDownloadNoopRepository checks that downloader.Service works with
+// igshelf.NoopRepository as db, so media files can be downloaded without
+// writing a timeline index alongside them.
+func TestDownloadNoopRepository(t *testing.T) {
+	timeline := []*igshelf.Media{{
+		ID:       "17870000000000001",
+		Type:     "IMAGE",
+		Filename: "17870000000000001.jpg",
+	}}
+	t.Cleanup(func() {
+		if err := os.Remove("testdata/17870000000000001.jpg"); err != nil {
+			t.Errorf("failed to delete an image: %v", err)
+		}
+	})
+
+	ig := mock.MediaService{
+		ListFn: func(since time.Time) igshelf.MediaIter {
+			return &mock.MediaIter{Batch: timeline}
+		},
+		DownloadFn: func(m *igshelf.Media) ([]byte, []byte, error) {
+			return []byte("content"), nil, nil
+		},
+	}
+	s := NewService(&ig, igshelf.NoopRepository{})
+
+	if _, err := s.Download(context.Background(), "testdata"); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := ioutil.ReadFile("testdata/17870000000000001.jpg")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff([]byte("content"), got); diff != "" {
+		t.Errorf(diff)
+	}
+
+	fis, err := ioutil.ReadDir("testdata")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, fi := range fis {
+		if strings.HasSuffix(fi.Name(), ".json") {
+			t.Errorf("expected NoopRepository not to write %s, it should discard Store", fi.Name())
+		}
+	}
+}
+
+// TestMediaFnResultDrivesDownload checks that a mock.MediaIter configured
+// with MediaFn (rather than a plain Batch) actually feeds its result to the
+// downloader, regression-testing a bug where Media() discarded MediaFn's
+// return value.
+func TestMediaFnResultDrivesDownload(t *testing.T) {
+	want := &igshelf.Media{
+		ID:       "17850307850323541",
+		Type:     "IMAGE",
+		Filename: "17850307850323541.jpg",
+	}
+	var calls int
+	it := &mock.MediaIter{
+		NextFn: func() bool {
+			calls++
+			return calls == 1
+		},
+		MediaFn: func() *igshelf.Media { return want },
+	}
+
+	ig := mock.MediaService{
+		ListFn: func(since time.Time) igshelf.MediaIter { return it },
+		DownloadFn: func(m *igshelf.Media) ([]byte, []byte, error) {
+			return []byte("content"), nil, nil
+		},
+	}
+	db := mock.MediaRepository{}
+	s := NewService(&ig, &db)
+
+	t.Cleanup(func() {
+		if err := os.Remove("testdata/17850307850323541.jpg"); err != nil {
+			t.Errorf("failed to delete an image: %v", err)
+		}
+	})
+
+	if _, err := s.Download(context.Background(), "testdata"); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := ioutil.ReadFile("testdata/17850307850323541.jpg")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff([]byte("content"), got); diff != "" {
+		t.Errorf(diff)
+	}
+}
+
+func TestVerifyExisting(t *testing.T) {
+	wantFile := []byte("the complete file")
+	sum := sha256.Sum256(wantFile)
+
+	timeline := []*igshelf.Media{{
+		ID:       "17863188140095493",
+		Type:     "IMAGE",
+		Filename: "17863188140095493.jpg",
+		Checksum: hex.EncodeToString(sum[:]),
+	}}
+	const contentPath = "testdata/17863188140095493.jpg"
+	if err := ioutil.WriteFile(contentPath, []byte("truncated"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		if err := os.Remove(contentPath); err != nil {
+			t.Errorf("failed to delete an image: %s", err)
+		}
+	})
+
+	ig := mock.MediaService{
+		ListFn: func(since time.Time) igshelf.MediaIter {
+			return &mock.MediaIter{Batch: timeline}
+		},
+		DownloadFn: func(m *igshelf.Media) ([]byte, []byte, error) {
+			return wantFile, nil, nil
+		},
+	}
+	db := mock.MediaRepository{}
+	s := NewService(&ig, &db, WithVerifyExisting(true))
+
+	if _, err := s.Download(context.Background(), "testdata"); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := ioutil.ReadFile(contentPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(wantFile, got); diff != "" {
+		t.Errorf(diff)
+	}
+}
+
+// TestExistingFilePolicySkip checks that the default policy leaves an
+// existing media file untouched and never calls DownloadFn.
+func TestExistingFilePolicySkip(t *testing.T) {
+	timeline := []*igshelf.Media{{
+		ID:       "1",
+		Type:     "IMAGE",
+		Filename: "1.jpg",
+	}}
+	var downloaded bool
+	ig := mock.MediaService{
+		ListFn: func(since time.Time) igshelf.MediaIter {
+			return &mock.MediaIter{Batch: timeline}
+		},
+		DownloadFn: func(m *igshelf.Media) ([]byte, []byte, error) {
+			downloaded = true
+			return []byte("fresh"), nil, nil
+		},
+	}
+	db := mock.MediaRepository{}
+	fsys := newMemFS()
+	fsys.files["content/1.jpg"] = []byte("already here")
+	s := NewService(&ig, &db, WithFS(fsys))
+
+	report, err := s.Download(context.Background(), "content")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if downloaded {
+		t.Error("DownloadFn shouldn't be called for an existing file under ExistingFileSkip")
+	}
+	if diff := cmp.Diff([]byte("already here"), fsys.files["content/1.jpg"]); diff != "" {
+		t.Errorf(diff)
+	}
+	if report.Skipped != 1 {
+		t.Errorf("got %d skipped, want 1", report.Skipped)
+	}
+}
+
+// TestExistingFilePolicyOverwrite checks that ExistingFileOverwrite
+// re-downloads and replaces an existing media file.
+func TestExistingFilePolicyOverwrite(t *testing.T) {
+	timeline := []*igshelf.Media{{
+		ID:       "1",
+		Type:     "IMAGE",
+		Filename: "1.jpg",
+	}}
+	ig := mock.MediaService{
+		ListFn: func(since time.Time) igshelf.MediaIter {
+			return &mock.MediaIter{Batch: timeline}
+		},
+		DownloadFn: func(m *igshelf.Media) ([]byte, []byte, error) {
+			return []byte("fresh"), nil, nil
+		},
+	}
+	db := mock.MediaRepository{}
+	fsys := newMemFS()
+	fsys.files["content/1.jpg"] = []byte("stale")
+	s := NewService(&ig, &db, WithFS(fsys), WithExistingFilePolicy(ExistingFileOverwrite))
+
+	report, err := s.Download(context.Background(), "content")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff([]byte("fresh"), fsys.files["content/1.jpg"]); diff != "" {
+		t.Errorf(diff)
+	}
+	if report.Downloaded != 1 {
+		t.Errorf("got %d downloaded, want 1", report.Downloaded)
+	}
+}
+
+// TestExistingFilePolicyResume checks that ExistingFileResume calls
+// DownloadResumable instead of Download when the media service supports it.
+// DownloadResumable writes straight to the local disk, so this exercises
+// the default filesystem rather than WithFS, see
+// TestExistingFilePolicyResumeRejectsNonDefaultFS.
+func TestExistingFilePolicyResume(t *testing.T) {
+	timeline := []*igshelf.Media{{
+		ID:       "1",
+		Type:     "IMAGE",
+		Filename: "resume1.jpg",
+	}}
+	const contentPath = "testdata/resume1.jpg"
+	if err := ioutil.WriteFile(contentPath, []byte("part"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		if err := os.Remove(contentPath); err != nil {
+			t.Errorf("failed to delete an image: %s", err)
+		}
+	})
+
+	var downloaded bool
+	ig := mock.MediaService{
+		ListFn: func(since time.Time) igshelf.MediaIter {
+			return &mock.MediaIter{Batch: timeline}
+		},
+		DownloadFn: func(m *igshelf.Media) ([]byte, []byte, error) {
+			downloaded = true
+			return nil, nil, nil
+		},
+		DownloadResumableFn: func(m *igshelf.Media, path string) error {
+			f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0600)
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+			_, err = f.Write([]byte("-rest"))
+			return err
+		},
+	}
+	db := mock.MediaRepository{}
+	s := NewService(&ig, &db, WithExistingFilePolicy(ExistingFileResume))
+
+	report, err := s.Download(context.Background(), "testdata")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if downloaded {
+		t.Error("Download shouldn't be called when DownloadResumable succeeds")
+	}
+	got, err := ioutil.ReadFile(contentPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff([]byte("part-rest"), got); diff != "" {
+		t.Errorf(diff)
+	}
+	if report.Downloaded != 1 {
+		t.Errorf("got %d downloaded, want 1", report.Downloaded)
+	}
+}
+
+// TestExistingFilePolicyResumeRejectsNonDefaultFS checks that Download
+// fails fast instead of silently corrupting state when ExistingFileResume
+// is combined with a non-default WithFS: DownloadResumable writes through
+// the local disk directly, so its output would be invisible to a WriteFS
+// that redirects elsewhere.
+func TestExistingFilePolicyResumeRejectsNonDefaultFS(t *testing.T) {
+	ig := mock.MediaService{}
+	db := mock.MediaRepository{}
+	s := NewService(&ig, &db, WithFS(newMemFS()), WithExistingFilePolicy(ExistingFileResume))
+
+	if _, err := s.Download(context.Background(), "content"); err == nil {
+		t.Error("expected an error combining ExistingFileResume with a non-default WithFS")
+	}
+}
+
+// nonResumableMediaService implements igshelf.MediaService but not
+// resumableDownloader, so ExistingFileResume has to fall back to a full
+// re-download through it.
+type nonResumableMediaService struct {
+	listFn     func(since time.Time) igshelf.MediaIter
+	downloadFn func(m *igshelf.Media) ([]byte, []byte, error)
+}
+
+func (s *nonResumableMediaService) List(ctx context.Context, since time.Time) igshelf.MediaIter {
+	return s.listFn(since)
+}
+
+func (s *nonResumableMediaService) Download(ctx context.Context, m *igshelf.Media) ([]byte, []byte, error) {
+	return s.downloadFn(m)
+}
+
+func (s *nonResumableMediaService) Get(ctx context.Context, id string) (*igshelf.Media, error) {
+	return nil, nil
+}
+
+// TestExistingFilePolicyResumeFallsBack checks that ExistingFileResume falls
+// back to a full re-download when the underlying media service doesn't
+// implement resumableDownloader.
+func TestExistingFilePolicyResumeFallsBack(t *testing.T) {
+	timeline := []*igshelf.Media{{
+		ID:       "1",
+		Type:     "IMAGE",
+		Filename: "resume2.jpg",
+	}}
+	const contentPath = "testdata/resume2.jpg"
+	if err := ioutil.WriteFile(contentPath, []byte("stale"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		if err := os.Remove(contentPath); err != nil {
+			t.Errorf("failed to delete an image: %s", err)
+		}
+	})
+
+	var downloaded bool
+	ig := &nonResumableMediaService{
+		listFn: func(since time.Time) igshelf.MediaIter {
+			return &mock.MediaIter{Batch: timeline}
+		},
+		downloadFn: func(m *igshelf.Media) ([]byte, []byte, error) {
+			downloaded = true
+			return []byte("fresh"), nil, nil
+		},
+	}
+	db := mock.MediaRepository{}
+	s := NewService(ig, &db, WithExistingFilePolicy(ExistingFileResume))
+
+	if _, err := s.Download(context.Background(), "testdata"); err != nil {
+		t.Fatal(err)
+	}
+	if !downloaded {
+		t.Error("Download should be called when the media service can't resume")
+	}
+	got, err := ioutil.ReadFile(contentPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff([]byte("fresh"), got); diff != "" {
+		t.Errorf(diff)
+	}
+}
+
+func TestStripRemoteLocations(t *testing.T) {
+	timeline := []*igshelf.Media{{
+		ID:       "17863188140095493",
+		Type:     "IMAGE",
+		Location: "https://scontent.cdninstagram.com/v/t51.2885-15/2...",
+		Filename: "17863188140095493.jpg",
+	}}
+	t.Cleanup(func() {
+		if err := os.Remove("testdata/17863188140095493.jpg"); err != nil {
+			t.Errorf("failed to delete an image: %s", err)
+		}
+	})
+
+	ig := mock.MediaService{
+		ListFn: func(since time.Time) igshelf.MediaIter {
+			return &mock.MediaIter{Batch: timeline}
+		},
+		DownloadFn: func(m *igshelf.Media) ([]byte, []byte, error) {
+			return []byte("content"), nil, nil
+		},
+	}
+	var stored []*igshelf.Media
+	db := mock.MediaRepository{StoreFn: func(got []*igshelf.Media) error {
+		stored = got
+		return nil
+	}}
+	s := NewService(&ig, &db, WithStripRemoteLocations(true))
+
+	if _, err := s.Download(context.Background(), "testdata"); err != nil {
+		t.Fatal(err)
+	}
+
+	want := "17863188140095493.jpg"
+	if got := stored[0].Location; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestUnavailableMediaIsSkipped(t *testing.T) {
+	timeline := []*igshelf.Media{{
+		ID:          "17850885734317674",
+		Type:        "IMAGE",
+		Filename:    "17850885734317674.jpg",
+		Unavailable: true,
+	}}
+
+	var downloaded bool
+	ig := mock.MediaService{
+		ListFn: func(since time.Time) igshelf.MediaIter {
+			return &mock.MediaIter{Batch: timeline}
+		},
+		DownloadFn: func(m *igshelf.Media) ([]byte, []byte, error) {
+			downloaded = true
+			return []byte("content"), nil, nil
+		},
+	}
+	db := mock.MediaRepository{}
+	s := NewService(&ig, &db)
+
+	report, err := s.Download(context.Background(), "testdata")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if downloaded {
+		t.Error("got a download attempt for unavailable media, want none")
+	}
+	if report.Skipped != 1 {
+		t.Errorf("got %d skipped, want 1", report.Skipped)
+	}
+}
+
+// TestIgnoreListReadFromDestination checks that .igignore is read from
+// WithDestination's directory (the one holding timeline.json) rather than
+// contentDirPath, which may be a content/ subdirectory of it.
+func TestIgnoreListReadFromDestination(t *testing.T) {
+	dst := t.TempDir()
+	if err := ioutil.WriteFile(filepath.Join(dst, igignoreFilename), []byte("1\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	timeline := []*igshelf.Media{
+		{ID: "1", Type: igshelf.MediaTypeImage, Filename: "1.jpg"},
+		{ID: "2", Type: igshelf.MediaTypeImage, Filename: "2.jpg"},
+	}
+	ig := mock.MediaService{
+		ListFn: func(since time.Time) igshelf.MediaIter {
+			return &mock.MediaIter{Batch: timeline}
+		},
+		DownloadFn: func(m *igshelf.Media) ([]byte, []byte, error) {
+			return []byte("content"), nil, nil
+		},
+	}
+	db := mock.MediaRepository{}
+	fsys := newMemFS()
+	s := NewService(&ig, &db, WithFS(fsys), WithDestination(dst))
+
+	report, err := s.Download(context.Background(), "content")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Skipped != 1 {
+		t.Errorf("got %d skipped, want 1", report.Skipped)
+	}
+	if _, ok := fsys.files["content/1.jpg"]; ok {
+		t.Error("media 1 is listed in .igignore and shouldn't have been downloaded")
+	}
+	if _, ok := fsys.files["content/2.jpg"]; !ok {
+		t.Error("expected content/2.jpg to have been downloaded")
+	}
+}
+
+// TestSyntheticAlbumParentSkipped checks that a zip-archive-synthesized
+// CAROUSEL_ALBUM parent (no Filename of its own) is never attempted, while
+// its children still are.
+func TestSyntheticAlbumParentSkipped(t *testing.T) {
+	timeline := []*igshelf.Media{{
+		ID:   "album-1",
+		Type: igshelf.MediaTypeAlbum,
+		Children: []*igshelf.Media{
+			{ID: "child-1", Type: igshelf.MediaTypeImage, Filename: "child-1.jpg"},
+		},
+	}}
+
+	var attempted []string
+	ig := mock.MediaService{
+		ListFn: func(since time.Time) igshelf.MediaIter {
+			return &mock.MediaIter{Batch: timeline}
+		},
+		DownloadFn: func(m *igshelf.Media) ([]byte, []byte, error) {
+			attempted = append(attempted, m.ID)
+			return []byte("content"), nil, nil
+		},
+	}
+	db := mock.MediaRepository{}
+	s := NewService(&ig, &db)
+
+	t.Cleanup(func() {
+		if err := os.Remove("testdata/child-1.jpg"); err != nil {
+			t.Errorf("failed to delete a child image: %v", err)
+		}
+	})
+
+	if _, err := s.Download(context.Background(), "testdata"); err != nil {
+		t.Fatal(err)
+	}
+
+	if diff := cmp.Diff([]string{"child-1"}, attempted); diff != "" {
+		t.Errorf("download attempts mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestTimelineIsSortedBeforeStore(t *testing.T) {
+	// Slightly out of order, as an API page occasionally arrives around a DST boundary.
+	timeline := []*igshelf.Media{
+		{ID: "1", TakenAt: time.Date(2020, time.October, 7, 15, 0, 0, 0, time.UTC)},
+		{ID: "2", TakenAt: time.Date(2020, time.October, 8, 12, 0, 0, 0, time.UTC)},
+		{ID: "3", TakenAt: time.Date(2020, time.October, 8, 9, 0, 0, 0, time.UTC)},
+	}
+
+	ig := mock.MediaService{
+		ListFn: func(since time.Time) igshelf.MediaIter {
+			return &mock.MediaIter{Batch: timeline}
+		},
+	}
+	var got []*igshelf.Media
+	db := mock.MediaRepository{StoreFn: func(timeline []*igshelf.Media) error {
+		got = timeline
+		return nil
+	}}
+	s := NewService(&ig, &db)
+
+	if _, err := s.Download(context.Background(), "testdata"); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{"2", "3", "1"}
+	var gotIDs []string
+	for _, m := range got {
+		gotIDs = append(gotIDs, m.ID)
+	}
+	if diff := cmp.Diff(want, gotIDs); diff != "" {
+		t.Errorf(diff)
+	}
+}
+
+func TestIncrementalDownloadSince(t *testing.T) {
+	existing := []*igshelf.Media{
+		{ID: "1", TakenAt: time.Date(2020, time.October, 7, 15, 0, 0, 0, time.UTC)},
+	}
+	newMedia := []*igshelf.Media{
+		{ID: "2", TakenAt: time.Date(2020, time.October, 8, 12, 0, 0, 0, time.UTC)},
+	}
+
+	var got time.Time
+	ig := mock.MediaService{
+		ListFn: func(since time.Time) igshelf.MediaIter {
+			got = since
+			return &mock.MediaIter{Batch: newMedia}
+		},
+	}
+	var stored []*igshelf.Media
+	db := mock.MediaRepository{
+		ListFn: func() ([]*igshelf.Media, error) {
+			return existing, nil
+		},
+		StoreFn: func(timeline []*igshelf.Media) error {
+			stored = timeline
+			return nil
+		},
+	}
+	s := NewService(&ig, &db, WithIncremental(true))
+
+	if _, err := s.Download(context.Background(), "testdata"); err != nil {
+		t.Fatal(err)
+	}
+
+	if want := existing[0].TakenAt; !got.Equal(want) {
+		t.Errorf("got since %v, want %v", got, want)
+	}
+
+	want := []string{"2", "1"}
+	var gotIDs []string
+	for _, m := range stored {
+		gotIDs = append(gotIDs, m.ID)
+	}
+	if diff := cmp.Diff(want, gotIDs); diff != "" {
+		t.Errorf(diff)
+	}
+}
+
+func TestDownloadCancelMidRun(t *testing.T) {
+	timeline := []*igshelf.Media{
+		{ID: "1", Type: "IMAGE", Filename: "1.jpg"},
+		{ID: "2", Type: "IMAGE", Filename: "2.jpg"},
+	}
+
+	// Simulates a SIGINT arriving after the timeline was fetched and stored,
+	// but before any media file was copied.
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	ig := mock.MediaService{
+		ListFn: func(since time.Time) igshelf.MediaIter {
+			return &mock.MediaIter{Batch: timeline}
+		},
+		DownloadFn: func(m *igshelf.Media) ([]byte, []byte, error) {
+			t.Errorf("Download shouldn't be called for %s once ctx is canceled", m.ID)
+			return nil, nil, nil
+		},
+	}
+	db := mock.MediaRepository{}
+	s := NewService(&ig, &db)
+
+	report, err := s.Download(ctx, "testdata")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Complete {
+		t.Error("expected an incomplete report for a canceled run")
+	}
+	if report.Downloaded != 0 {
+		t.Errorf("got %d downloaded, want 0", report.Downloaded)
+	}
+}
+
+func TestContentManifest(t *testing.T) {
+	takenAt := time.Date(2020, time.October, 7, 15, 55, 33, 0, time.UTC)
+	timeline := []*igshelf.Media{
+		{ID: "1", Type: "IMAGE", Caption: "Sunset walk", Filename: "1.jpg", TakenAt: takenAt},
+	}
+	t.Cleanup(func() {
+		os.Remove("testdata/1.jpg")
+		os.Remove("testdata/index.json")
+	})
+
+	ig := mock.MediaService{
+		ListFn: func(since time.Time) igshelf.MediaIter {
+			return &mock.MediaIter{Batch: timeline}
+		},
+		DownloadFn: func(m *igshelf.Media) ([]byte, []byte, error) {
+			return []byte("content"), nil, nil
+		},
+	}
+	db := mock.MediaRepository{}
+	s := NewService(&ig, &db, WithContentManifest(true))
+
+	if _, err := s.Download(context.Background(), "testdata"); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := ioutil.ReadFile("testdata/index.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := fmt.Sprintf(`{
+  "1.jpg": {
+    "id": "1",
+    "type": "IMAGE",
+    "caption": "Sunset walk",
+    "taken_at": "%s"
+  }
+}`, takenAt.Format(time.RFC3339Nano))
+	if diff := cmp.Diff(want, string(got)); diff != "" {
+		t.Errorf(diff)
+	}
+}
+
+func TestImageThumbnails(t *testing.T) {
+	src := image.NewRGBA(image.Rect(0, 0, 800, 400))
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, src, nil); err != nil {
+		t.Fatal(err)
+	}
+	content := buf.Bytes()
+
+	timeline := []*igshelf.Media{
+		{ID: "1", Type: igshelf.MediaTypeImage, Filename: "1.jpg"},
+	}
+	t.Cleanup(func() {
+		os.Remove("testdata/1.jpg")
+		os.Remove("testdata/1_thumb.jpg")
+	})
+
+	ig := mock.MediaService{
+		ListFn: func(since time.Time) igshelf.MediaIter {
+			return &mock.MediaIter{Batch: timeline}
+		},
+		DownloadFn: func(m *igshelf.Media) ([]byte, []byte, error) {
+			return content, nil, nil
+		},
+	}
+	var stored []*igshelf.Media
+	db := mock.MediaRepository{StoreFn: func(timeline []*igshelf.Media) error {
+		stored = timeline
+		return nil
+	}}
+	s := NewService(&ig, &db, WithImageThumbnails(200))
+
+	if _, err := s.Download(context.Background(), "testdata"); err != nil {
+		t.Fatal(err)
+	}
+
+	if got, want := stored[0].ThumbnailFilename, "1_thumb.jpg"; got != want {
+		t.Errorf("got stored thumbnail filename %q, want %q", got, want)
+	}
+
+	thumb, err := ioutil.ReadFile("testdata/1_thumb.jpg")
+	if err != nil {
+		t.Fatal(err)
+	}
+	img, _, err := image.Decode(bytes.NewReader(thumb))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bounds := img.Bounds(); bounds.Dx() > 200 || bounds.Dy() > 200 {
+		t.Errorf("got thumbnail bounds %v, want both sides within 200", bounds)
+	}
+}
+
+// TestThumbnailWorkersBoundsConcurrency checks that WithThumbnailWorkers
+// caps how many thumbnailer invocations run at once, independent of
+// WithMaxWorkers, by racing several images through a counting thumbnailer
+// that blocks until released.
+func TestThumbnailWorkersBoundsConcurrency(t *testing.T) {
+	const (
+		images           = 8
+		thumbnailWorkers = 2
+	)
+
+	timeline := make([]*igshelf.Media, images)
+	for i := range timeline {
+		id := fmt.Sprintf("%d", i)
+		timeline[i] = &igshelf.Media{ID: id, Type: igshelf.MediaTypeImage, Filename: id + ".jpg"}
+	}
+	t.Cleanup(func() {
+		for _, m := range timeline {
+			os.Remove(filepath.Join("testdata", m.Filename))
+		}
+	})
+
+	ig := mock.MediaService{
+		ListFn: func(since time.Time) igshelf.MediaIter {
+			return &mock.MediaIter{Batch: timeline}
+		},
+		DownloadFn: func(m *igshelf.Media) ([]byte, []byte, error) {
+			return []byte("content"), nil, nil
+		},
+	}
+	db := mock.MediaRepository{}
+	s := NewService(&ig, &db,
+		WithMaxWorkers(images),
+		WithImageThumbnails(200),
+		WithThumbnailWorkers(thumbnailWorkers),
+	)
+
+	var (
+		mu       sync.Mutex
+		inFlight int
+		maxSeen  int
+	)
+	s.thumbnailFn = func(content []byte, maxDim int) ([]byte, error) {
+		mu.Lock()
+		inFlight++
+		if inFlight > maxSeen {
+			maxSeen = inFlight
+		}
+		mu.Unlock()
+
+		time.Sleep(20 * time.Millisecond)
+
+		mu.Lock()
+		inFlight--
+		mu.Unlock()
+		return []byte("thumb"), nil
+	}
+
+	if _, err := s.Download(context.Background(), "testdata"); err != nil {
+		t.Fatal(err)
+	}
+
+	if maxSeen > thumbnailWorkers {
+		t.Errorf("got %d concurrent thumbnailer invocations, want at most %d", maxSeen, thumbnailWorkers)
+	}
+}
+
+func TestDownloadFailures(t *testing.T) {
+	takenAt := time.Date(2020, time.October, 7, 15, 55, 33, 0, time.UTC)
+	timeline := []*igshelf.Media{
+		{ID: "1", Type: igshelf.MediaTypeImage, Filename: "1.jpg", TakenAt: takenAt},
+	}
+
+	ig := mock.MediaService{
+		ListFn: func(since time.Time) igshelf.MediaIter {
+			return &mock.MediaIter{Batch: timeline}
+		},
+		DownloadFn: func(m *igshelf.Media) ([]byte, []byte, error) {
+			return nil, nil, fmt.Errorf("file not found in archive")
+		},
+	}
+	db := mock.MediaRepository{}
+	s := NewService(&ig, &db)
+
+	report, err := s.Download(context.Background(), "testdata")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []FailedMedia{
+		{ID: "1", Reason: "file not found in archive", TakenAt: takenAt},
+	}
+	if diff := cmp.Diff(want, report.Failures); diff != "" {
+		t.Errorf(diff)
+	}
+}
+
+// TestCustomLogger checks a caller can plug their own Logger implementation,
+// without depending on github.com/go-kit/kit/log, and observe the key-values
+// the downloader emits for a failed download.
+func TestCustomLogger(t *testing.T) {
+	timeline := []*igshelf.Media{
+		{ID: "1", Type: igshelf.MediaTypeImage, Filename: "1.jpg"},
+	}
+
+	ig := mock.MediaService{
+		ListFn: func(since time.Time) igshelf.MediaIter {
+			return &mock.MediaIter{Batch: timeline}
+		},
+		DownloadFn: func(m *igshelf.Media) ([]byte, []byte, error) {
+			return nil, nil, fmt.Errorf("file not found in archive")
+		},
+	}
+	db := mock.MediaRepository{}
+
+	var got []interface{}
+	logger := LoggerFunc(func(keyvals ...interface{}) error {
+		got = keyvals
+		return nil
+	})
+	s := NewService(&ig, &db, WithLogger(logger))
+
+	if _, err := s.Download(context.Background(), "testdata"); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []interface{}{"msg", "failed to download media content", "media", timeline[0], "err", fmt.Errorf("file not found in archive")}
+	if diff := cmp.Diff(want, got, cmp.Comparer(func(a, b error) bool {
+		return a.Error() == b.Error()
+	})); diff != "" {
+		t.Errorf(diff)
+	}
+}
+
+// pagedMediaIter wraps mock.MediaIter with a Page method, mimicking
+// instagram.MediaIter, so Download's optional pagination logging can be
+// exercised without a live API server.
+type pagedMediaIter struct {
+	*mock.MediaIter
+	page int
+}
+
+func (it *pagedMediaIter) Page() int { return it.page }
+
+func TestLogsFetchedPages(t *testing.T) {
+	timeline := []*igshelf.Media{
+		{ID: "1", Type: igshelf.MediaTypeImage, Filename: "1.jpg"},
+		{ID: "2", Type: igshelf.MediaTypeImage, Filename: "2.jpg"},
+	}
+
+	ig := mock.MediaService{
+		ListFn: func(since time.Time) igshelf.MediaIter {
+			return &pagedMediaIter{
+				MediaIter: &mock.MediaIter{Batch: timeline},
+				page:      1,
+			}
+		},
+	}
+	db := mock.MediaRepository{}
+
+	var got []int
+	logger := LoggerFunc(func(keyvals ...interface{}) error {
+		for i := 0; i+1 < len(keyvals); i += 2 {
+			if keyvals[i] == "page" {
+				got = append(got, keyvals[i+1].(int))
+			}
+		}
+		return nil
+	})
+	s := NewService(&ig, &db, WithLogger(logger))
+
+	if _, err := s.Download(context.Background(), "testdata"); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []int{1}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf(diff)
+	}
+}
+
+func TestMetrics(t *testing.T) {
+	timeline := []*igshelf.Media{
+		{ID: "1", Type: "IMAGE", Filename: "1.jpg"},
+		{ID: "2", Type: "IMAGE", Filename: "2.jpg"},
+	}
+	payload := map[string][]byte{
+		"1": []byte("aaaaa"),
+		"2": []byte("bbb"),
+	}
+	t.Cleanup(func() {
+		for _, m := range timeline {
+			if err := os.Remove(filepath.Join("testdata", m.Filename)); err != nil {
+				t.Errorf("failed to delete %s: %s", m.Filename, err)
+			}
+		}
+	})
+
+	ig := mock.MediaService{
+		ListFn: func(since time.Time) igshelf.MediaIter {
+			return &mock.MediaIter{Batch: timeline}
+		},
+		DownloadFn: func(m *igshelf.Media) ([]byte, []byte, error) {
+			return payload[m.ID], nil, nil
+		},
+	}
+	db := mock.MediaRepository{}
+
+	var got Stats
+	s := NewService(&ig, &db, WithMetrics(func(stats Stats) {
+		got = stats
+	}))
+
+	if _, err := s.Download(context.Background(), "testdata"); err != nil {
+		t.Fatal(err)
+	}
+
+	want := Stats{Downloaded: 2, TotalBytes: int64(len(payload["1"]) + len(payload["2"]))}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf(diff)
+	}
+}
+
+func TestDownloadInMemoryFS(t *testing.T) {
+	timeline := []*igshelf.Media{{
+		ID:                "17863188140095492",
+		Type:              "VIDEO",
+		Location:          "https://video.cdninstagram.com/v/t50.2886-16/2...",
+		ThumbnailLocation: "https://scontent.cdninstagram.com/v/t51.29350-15/2...",
+		Filename:          "17863188140095492.mp4",
+		ThumbnailFilename: "17863188140095492_cover.jpg",
+	}}
+	var (
+		wantFile  = []byte("content")
+		wantThumb = []byte("thumbnail")
+	)
+
+	ig := mock.MediaService{
+		ListFn: func(since time.Time) igshelf.MediaIter {
+			return &mock.MediaIter{Batch: timeline}
+		},
+		DownloadFn: func(m *igshelf.Media) ([]byte, []byte, error) {
+			return wantFile, wantThumb, nil
+		},
+	}
+	db := mock.MediaRepository{}
+	fsys := newMemFS()
+	s := NewService(&ig, &db, WithFS(fsys))
+
+	if _, err := s.Download(context.Background(), "memory"); err != nil {
+		t.Fatal(err)
+	}
+
+	if diff := cmp.Diff(wantFile, fsys.files["memory/17863188140095492.mp4"]); diff != "" {
+		t.Errorf(diff)
+	}
+	if diff := cmp.Diff(wantThumb, fsys.files["memory/17863188140095492_cover.jpg"]); diff != "" {
+		t.Errorf(diff)
+	}
+}
+
+// TestDownloadDateDirs checks that WithDateDirs nests a media file (and
+// its thumbnail) under a content/YYYY/MM/ subdirectory derived from
+// TakenAt, and that the stored timeline's Filename is updated to match.
+func TestDownloadDateDirs(t *testing.T) {
+	timeline := []*igshelf.Media{{
+		ID:                "17863188140095492",
+		Type:              "VIDEO",
+		Location:          "https://video.cdninstagram.com/v/t50.2886-16/2...",
+		ThumbnailLocation: "https://scontent.cdninstagram.com/v/t51.29350-15/2...",
+		Filename:          "17863188140095492.mp4",
+		ThumbnailFilename: "17863188140095492_cover.jpg",
+		TakenAt:           time.Date(2020, time.October, 7, 15, 55, 33, 0, time.UTC),
+	}}
+	var (
+		wantFile  = []byte("content")
+		wantThumb = []byte("thumbnail")
+	)
+
+	ig := mock.MediaService{
+		ListFn: func(since time.Time) igshelf.MediaIter {
+			return &mock.MediaIter{Batch: timeline}
+		},
+		DownloadFn: func(m *igshelf.Media) ([]byte, []byte, error) {
+			return wantFile, wantThumb, nil
+		},
+	}
+	var stored []*igshelf.Media
+	db := mock.MediaRepository{
+		StoreFn: func(timeline []*igshelf.Media) error {
+			stored = timeline
+			return nil
+		},
+	}
+	fsys := newMemFS()
+	s := NewService(&ig, &db, WithFS(fsys), WithDateDirs(true))
+
+	if _, err := s.Download(context.Background(), "memory"); err != nil {
+		t.Fatal(err)
+	}
+
+	if diff := cmp.Diff(wantFile, fsys.files["memory/2020/10/17863188140095492.mp4"]); diff != "" {
+		t.Errorf(diff)
+	}
+	if diff := cmp.Diff(wantThumb, fsys.files["memory/2020/10/17863188140095492_cover.jpg"]); diff != "" {
+		t.Errorf(diff)
+	}
+
+	if got, want := stored[0].Filename, "2020/10/17863188140095492.mp4"; got != want {
+		t.Errorf("got Filename %q, want %q", got, want)
+	}
+}
+
+// TestDownloadWithTypes checks that WithTypes restricts Download to media
+// of the given types, while still recording every media (regardless of
+// type) in timeline.json.
+func TestDownloadWithTypes(t *testing.T) {
+	timeline := []*igshelf.Media{
+		{ID: "1", Type: "IMAGE", Filename: "1.jpg"},
+		{ID: "2", Type: "VIDEO", Filename: "2.mp4"},
+	}
+
+	var downloaded []string
+	ig := mock.MediaService{
+		ListFn: func(since time.Time) igshelf.MediaIter {
+			return &mock.MediaIter{Batch: timeline}
+		},
+		DownloadFn: func(m *igshelf.Media) ([]byte, []byte, error) {
+			downloaded = append(downloaded, m.ID)
+			return []byte("content"), nil, nil
+		},
+	}
+
+	var stored []*igshelf.Media
+	db := mock.MediaRepository{
+		StoreFn: func(timeline []*igshelf.Media) error {
+			stored = timeline
+			return nil
+		},
+	}
+	fsys := newMemFS()
+	s := NewService(&ig, &db, WithFS(fsys), WithTypes(igshelf.MediaTypeVideo))
+
+	if _, err := s.Download(context.Background(), "memory"); err != nil {
+		t.Fatal(err)
+	}
+
+	if diff := cmp.Diff([]string{"2"}, downloaded); diff != "" {
+		t.Errorf("downloaded (-want +got):\n%s", diff)
+	}
+	if len(stored) != 2 {
+		t.Errorf("got %d stored media, want both to still be recorded in timeline.json", len(stored))
+	}
+}
+
+// TestDownloadSidecars checks that WithSidecars writes a <Filename>.json
+// sidecar next to a downloaded file with the media's identifying fields.
+func TestDownloadSidecars(t *testing.T) {
+	timeline := []*igshelf.Media{{
+		ID:        "17863188140095492",
+		Type:      "VIDEO",
+		Caption:   "Still jumping",
+		Permalink: "https://www.instagram.com/p/CGDFCNqHJv1/",
+		TakenAt:   time.Date(2020, time.October, 7, 15, 55, 33, 0, time.UTC),
+		Filename:  "17863188140095492.mp4",
+	}}
+
+	ig := mock.MediaService{
+		ListFn: func(since time.Time) igshelf.MediaIter {
+			return &mock.MediaIter{Batch: timeline}
+		},
+		DownloadFn: func(m *igshelf.Media) ([]byte, []byte, error) {
+			return []byte("content"), nil, nil
+		},
+	}
+	db := mock.MediaRepository{}
+	fsys := newMemFS()
+	s := NewService(&ig, &db, WithFS(fsys), WithSidecars(true))
+
+	if _, err := s.Download(context.Background(), "memory"); err != nil {
+		t.Fatal(err)
+	}
+
+	var got sidecarEntry
+	if err := json.Unmarshal(fsys.files["memory/17863188140095492.mp4.json"], &got); err != nil {
+		t.Fatal(err)
+	}
+
+	want := sidecarEntry{
+		ID:        "17863188140095492",
+		Caption:   "Still jumping",
+		Permalink: "https://www.instagram.com/p/CGDFCNqHJv1/",
+		TakenAt:   time.Date(2020, time.October, 7, 15, 55, 33, 0, time.UTC),
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf(diff)
+	}
+}
+
+// memFS is a minimal in-memory WriteFS used to unit test Download without
+// touching disk. Reads of files it doesn't hold behave like a missing file,
+// matching os.Open/os.Stat.
+type memFS struct {
+	mu    sync.Mutex
+	files map[string][]byte
+}
+
+func newMemFS() *memFS {
+	return &memFS{files: make(map[string][]byte)}
+}
+
+func (fsys *memFS) MkdirAll(path string, perm os.FileMode) error { return nil }
+
+func (fsys *memFS) Create(name string, perm os.FileMode) (io.WriteCloser, error) {
+	return &memFile{fsys: fsys, name: name}, nil
+}
+
+func (fsys *memFS) Open(name string) (io.ReadCloser, error) {
+	fsys.mu.Lock()
+	defer fsys.mu.Unlock()
+	b, ok := fsys.files[name]
+	if !ok {
+		return nil, &os.PathError{Op: "open", Path: name, Err: os.ErrNotExist}
+	}
+	return ioutil.NopCloser(bytes.NewReader(b)), nil
+}
+
+func (fsys *memFS) Stat(name string) (os.FileInfo, error) {
+	fsys.mu.Lock()
+	defer fsys.mu.Unlock()
+	if _, ok := fsys.files[name]; !ok {
+		return nil, &os.PathError{Op: "stat", Path: name, Err: os.ErrNotExist}
+	}
+	return nil, nil
+}
+
+// memFile buffers writes until Close, then stores them in fsys.files,
+// mirroring the all-at-once semantics writeFile relies on.
+type memFile struct {
+	fsys *memFS
+	name string
+	buf  bytes.Buffer
+}
+
+func (f *memFile) Write(p []byte) (int, error) { return f.buf.Write(p) }
+
+func (f *memFile) Close() error {
+	f.fsys.mu.Lock()
+	defer f.fsys.mu.Unlock()
+	f.fsys.files[f.name] = f.buf.Bytes()
+	return nil
+}
+
+// TestDownloadStopQueuing checks that closing the stop-queuing channel
+// (simulating a first SIGINT) lets an in-flight download finish while
+// preventing any not-yet-started download from beginning.
+func TestDownloadStopQueuing(t *testing.T) {
+	timeline := []*igshelf.Media{
+		{ID: "1", Type: "IMAGE", Filename: "1.jpg"},
+		{ID: "2", Type: "IMAGE", Filename: "2.jpg"},
+	}
+	t.Cleanup(func() {
+		os.Remove("testdata/1.jpg")
+		os.Remove("testdata/2.jpg")
+	})
+
+	stopQueuing := make(chan struct{})
+	ig := mock.MediaService{
+		ListFn: func(since time.Time) igshelf.MediaIter {
+			return &mock.MediaIter{Batch: timeline}
+		},
+		DownloadFn: func(m *igshelf.Media) ([]byte, []byte, error) {
+			if m.ID == "2" {
+				t.Error("media 2 shouldn't be downloaded once stopQueuing is closed")
+				return nil, nil, nil
+			}
+			close(stopQueuing)
+			return []byte("content"), nil, nil
+		},
+	}
+	db := mock.MediaRepository{}
+	// A single worker serializes downloads, so media 2's goroutine can't
+	// even start until media 1's has fully finished closing stopQueuing.
+	s := NewService(&ig, &db, WithMaxWorkers(1), WithStopQueuing(stopQueuing))
+
+	report, err := s.Download(context.Background(), "testdata")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Complete {
+		t.Error("expected an incomplete report for a stopped run")
+	}
+	if got, want := report.Downloaded, int64(1); got != want {
+		t.Errorf("got %d downloaded, want %d", got, want)
+	}
+
+	if _, err := os.Stat("testdata/1.jpg"); err != nil {
+		t.Errorf("expected media 1 to have been downloaded: %v", err)
+	}
+	if _, err := os.Stat("testdata/2.jpg"); !os.IsNotExist(err) {
+		t.Error("expected media 2 to not have been downloaded")
+	}
+}
+
+// TestDownloadIDs checks DownloadIDs resolves media by ID via Get and
+// downloads only the requested ones, ignoring the rest of the media
+// service's timeline.
+func TestDownloadIDs(t *testing.T) {
+	byID := map[string]*igshelf.Media{
+		"1": {ID: "1", Type: igshelf.MediaTypeImage, Filename: "1.jpg"},
+		"2": {ID: "2", Type: igshelf.MediaTypeImage, Filename: "2.jpg"},
+		"3": {ID: "3", Type: igshelf.MediaTypeImage, Filename: "3.jpg"},
+	}
+
+	var gotMu sync.Mutex
+	var got []string
+	ig := mock.MediaService{
+		GetFn: func(id string) (*igshelf.Media, error) {
+			gotMu.Lock()
+			got = append(got, id)
+			gotMu.Unlock()
+			return byID[id], nil
+		},
+		DownloadFn: func(m *igshelf.Media) ([]byte, []byte, error) {
+			return []byte("content"), nil, nil
+		},
+	}
+	db := mock.MediaRepository{}
+	fsys := newMemFS()
+	s := NewService(&ig, &db, WithFS(fsys))
+
+	report, err := s.DownloadIDs(context.Background(), "memory", []string{"1", "3"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got, want := report.Downloaded, int64(2); got != want {
+		t.Errorf("got %d downloaded, want %d", got, want)
+	}
+
+	sort.Strings(got)
+	if diff := cmp.Diff([]string{"1", "3"}, got); diff != "" {
+		t.Errorf(diff)
+	}
+	if _, ok := fsys.files["memory/2.jpg"]; ok {
+		t.Error("media 2 wasn't requested and shouldn't have been downloaded")
+	}
+	for _, name := range []string{"memory/1.jpg", "memory/3.jpg"} {
+		if _, ok := fsys.files[name]; !ok {
+			t.Errorf("expected %s to have been downloaded", name)
+		}
+	}
+}
+
+// TestDownloadIDsSkipsUnavailable checks that DownloadIDs skips a media Get
+// resolves as Unavailable (e.g. a copyright-flagged album child) instead of
+// attempting a Download that would fail on its empty Location.
+func TestDownloadIDsSkipsUnavailable(t *testing.T) {
+	var downloaded bool
+	ig := mock.MediaService{
+		GetFn: func(id string) (*igshelf.Media, error) {
+			return &igshelf.Media{ID: id, Type: igshelf.MediaTypeImage, Filename: id + ".jpg", Unavailable: true}, nil
+		},
+		DownloadFn: func(m *igshelf.Media) ([]byte, []byte, error) {
+			downloaded = true
+			return nil, nil, fmt.Errorf("shouldn't be called for unavailable media")
+		},
+	}
+	db := mock.MediaRepository{}
+	s := NewService(&ig, &db, WithFS(newMemFS()))
+
+	report, err := s.DownloadIDs(context.Background(), "memory", []string{"1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if downloaded {
+		t.Error("Download shouldn't be called for unavailable media")
+	}
+	if report.Skipped != 1 {
+		t.Errorf("got %d skipped, want 1", report.Skipped)
+	}
+}
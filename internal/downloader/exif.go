@@ -0,0 +1,74 @@
+package downloader
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"time"
+)
+
+// exifDateTimeLayout is the format Exif uses for date/time tag values.
+const exifDateTimeLayout = "2006:01:02 15:04:05"
+
+// writeExifDateTimeOriginal returns a copy of content with a minimal Exif
+// APP1 segment inserted right after the JPEG's SOI marker, setting
+// DateTimeOriginal (tag 0x9003) to t. content must start with a JPEG SOI
+// marker (0xFFD8); anything else is an error.
+//
+// This hand-rolls the handful of TIFF/Exif fields it needs instead of
+// pulling in a library, mirroring scaleImageThumbnail's dependency-free
+// approach: many Instagram images arrive with no Exif data at all, so
+// there's no existing APP1 segment to merge into.
+func writeExifDateTimeOriginal(content []byte, t time.Time) ([]byte, error) {
+	if len(content) < 2 || content[0] != 0xFF || content[1] != 0xD8 {
+		return nil, fmt.Errorf("not a JPEG file (missing SOI marker)")
+	}
+
+	app1 := buildExifAPP1(t)
+	out := make([]byte, 0, len(content)+len(app1))
+	out = append(out, content[:2]...)
+	out = append(out, app1...)
+	out = append(out, content[2:]...)
+	return out, nil
+}
+
+// buildExifAPP1 builds a JPEG APP1 segment (marker, length, "Exif\0\0",
+// and a TIFF block) carrying a single DateTimeOriginal tag.
+func buildExifAPP1(t time.Time) []byte {
+	dt := []byte(t.Format(exifDateTimeLayout) + "\x00")
+
+	var tiff bytes.Buffer
+	tiff.WriteString("MM")                            // big-endian byte order
+	binary.Write(&tiff, binary.BigEndian, uint16(42)) // TIFF magic number
+	binary.Write(&tiff, binary.BigEndian, uint32(8))  // offset to IFD0
+
+	// IFD0 holds a single entry pointing to the Exif SubIFD.
+	const exifIFDOffset = 8 + 2 + 12 + 4
+	binary.Write(&tiff, binary.BigEndian, uint16(1))
+	binary.Write(&tiff, binary.BigEndian, uint16(0x8769)) // ExifIFDPointer
+	binary.Write(&tiff, binary.BigEndian, uint16(4))      // LONG
+	binary.Write(&tiff, binary.BigEndian, uint32(1))
+	binary.Write(&tiff, binary.BigEndian, uint32(exifIFDOffset))
+	binary.Write(&tiff, binary.BigEndian, uint32(0)) // no next IFD
+
+	// The Exif SubIFD holds DateTimeOriginal; its ASCII value is too long
+	// to fit inline, so it's stored right after this IFD and referenced
+	// by offset.
+	dtOffset := exifIFDOffset + 2 + 12 + 4
+	binary.Write(&tiff, binary.BigEndian, uint16(1))
+	binary.Write(&tiff, binary.BigEndian, uint16(0x9003)) // DateTimeOriginal
+	binary.Write(&tiff, binary.BigEndian, uint16(2))      // ASCII
+	binary.Write(&tiff, binary.BigEndian, uint32(len(dt)))
+	binary.Write(&tiff, binary.BigEndian, uint32(dtOffset))
+	binary.Write(&tiff, binary.BigEndian, uint32(0)) // no next IFD
+
+	tiff.Write(dt)
+
+	var seg bytes.Buffer
+	seg.WriteByte(0xFF)
+	seg.WriteByte(0xE1)
+	binary.Write(&seg, binary.BigEndian, uint16(2+6+tiff.Len()))
+	seg.WriteString("Exif\x00\x00")
+	seg.Write(tiff.Bytes())
+	return seg.Bytes()
+}
@@ -0,0 +1,114 @@
+package downloader
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"testing"
+	"time"
+
+	"github.com/marselester/igshelf"
+	"github.com/marselester/igshelf/internal/mock"
+)
+
+// readExifDateTimeOriginal parses back the APP1 segment written by
+// writeExifDateTimeOriginal, mirroring its structure just enough to
+// extract DateTimeOriginal for a test assertion.
+func readExifDateTimeOriginal(content []byte) (string, error) {
+	if len(content) < 4 || content[0] != 0xFF || content[1] != 0xD8 || content[2] != 0xFF || content[3] != 0xE1 {
+		return "", fmt.Errorf("no APP1 segment right after SOI")
+	}
+	tiff := content[4+2+6:] // skip marker, length, and "Exif\0\0"
+
+	ifd0Offset := binary.BigEndian.Uint32(tiff[4:8])
+	ifd0 := tiff[ifd0Offset:]
+	ifd0Entry := ifd0[2:14] // tag(2) type(2) count(4) value(4)
+	exifIFDOffset := binary.BigEndian.Uint32(ifd0Entry[8:12])
+	exifIFD := tiff[exifIFDOffset:]
+
+	count := binary.BigEndian.Uint16(exifIFD[0:2])
+	for i := uint16(0); i < count; i++ {
+		entry := exifIFD[2+i*12 : 2+i*12+12]
+		tag := binary.BigEndian.Uint16(entry[0:2])
+		if tag != 0x9003 {
+			continue
+		}
+		length := binary.BigEndian.Uint32(entry[4:8])
+		offset := binary.BigEndian.Uint32(entry[8:12])
+		dt := tiff[offset : offset+length]
+		return string(bytes.TrimRight(dt, "\x00")), nil
+	}
+	return "", fmt.Errorf("DateTimeOriginal tag not found")
+}
+
+func TestWriteExifDateTimeOriginal(t *testing.T) {
+	src := image.NewRGBA(image.Rect(0, 0, 8, 8))
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, src, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	takenAt := time.Date(2020, time.October, 7, 15, 55, 33, 0, time.UTC)
+	out, err := writeExifDateTimeOriginal(buf.Bytes(), takenAt)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := readExifDateTimeOriginal(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "2020:10:07 15:55:33"; got != want {
+		t.Errorf("got DateTimeOriginal %q, want %q", got, want)
+	}
+}
+
+func TestWriteExifDateTimeOriginalNotJPEG(t *testing.T) {
+	if _, err := writeExifDateTimeOriginal([]byte("not a jpeg"), time.Now()); err == nil {
+		t.Error("expected an error for non-JPEG content")
+	}
+}
+
+func TestDownloadWriteExif(t *testing.T) {
+	src := image.NewRGBA(image.Rect(0, 0, 8, 8))
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, src, nil); err != nil {
+		t.Fatal(err)
+	}
+	content := buf.Bytes()
+
+	takenAt := time.Date(2020, time.October, 7, 15, 55, 33, 0, time.UTC)
+	timeline := []*igshelf.Media{{
+		ID:       "17863188140095492",
+		Type:     igshelf.MediaTypeImage,
+		Filename: "17863188140095492.jpg",
+		TakenAt:  takenAt,
+	}}
+
+	ig := mock.MediaService{
+		ListFn: func(since time.Time) igshelf.MediaIter {
+			return &mock.MediaIter{Batch: timeline}
+		},
+		DownloadFn: func(m *igshelf.Media) ([]byte, []byte, error) {
+			return content, nil, nil
+		},
+	}
+	db := mock.MediaRepository{}
+	fsys := newMemFS()
+	s := NewService(&ig, &db, WithFS(fsys), WithWriteExif(true))
+
+	if _, err := s.Download(context.Background(), "memory"); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := readExifDateTimeOriginal(fsys.files["memory/17863188140095492.jpg"])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "2020:10:07 15:55:33"; got != want {
+		t.Errorf("got DateTimeOriginal %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,68 @@
+package downloader
+
+import (
+	"io"
+	"io/ioutil"
+	"os"
+)
+
+// WriteFS is the minimal filesystem Download reads and writes media
+// through, letting content be stored somewhere other than the local disk,
+// e.g. cloud storage or an in-memory filesystem in tests. The default,
+// used unless WithFS overrides it, wraps the local disk with package os.
+type WriteFS interface {
+	// MkdirAll creates a directory, along with any necessary parents.
+	MkdirAll(path string, perm os.FileMode) error
+	// Create creates or truncates the named file for writing.
+	Create(name string, perm os.FileMode) (io.WriteCloser, error)
+	// Open opens the named file for reading, e.g. to verify a file that
+	// already exists.
+	Open(name string) (io.ReadCloser, error)
+	// Stat returns file info for the named file, e.g. to check whether it
+	// already exists before downloading it again.
+	Stat(name string) (os.FileInfo, error)
+}
+
+// osFS is the default WriteFS, backed by the local disk.
+type osFS struct{}
+
+func (osFS) MkdirAll(path string, perm os.FileMode) error {
+	return os.MkdirAll(path, perm)
+}
+
+func (osFS) Create(name string, perm os.FileMode) (io.WriteCloser, error) {
+	return os.OpenFile(name, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, perm)
+}
+
+func (osFS) Open(name string) (io.ReadCloser, error) {
+	return os.Open(name)
+}
+
+func (osFS) Stat(name string) (os.FileInfo, error) {
+	return os.Stat(name)
+}
+
+// writeFile writes data to name through fsys, creating (or truncating) it
+// with the given permissions, mirroring ioutil.WriteFile.
+func writeFile(fsys WriteFS, name string, data []byte, perm os.FileMode) error {
+	w, err := fsys.Create(name, perm)
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(data); err != nil {
+		w.Close()
+		return err
+	}
+	return w.Close()
+}
+
+// readFile reads the entire contents of name through fsys, mirroring
+// ioutil.ReadFile.
+func readFile(fsys WriteFS, name string) ([]byte, error) {
+	r, err := fsys.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	return ioutil.ReadAll(r)
+}
@@ -0,0 +1,62 @@
+package downloader
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/marselester/igshelf"
+)
+
+// igignoreFilename is an optional file listing media to skip when downloading.
+const igignoreFilename = ".igignore"
+
+// ignoreList holds media IDs and filename glob patterns to skip when downloading.
+// Media matched by it is still recorded in timeline.json, only its file isn't fetched.
+type ignoreList struct {
+	ids      map[string]struct{}
+	patterns []string
+}
+
+// loadIgnoreList reads igignoreFilename from dir, if present.
+// Each non-empty, non-comment line is either an exact media ID
+// or a glob pattern (as understood by path/filepath.Match) matched against Media.Filename.
+func loadIgnoreList(dir string) (*ignoreList, error) {
+	il := ignoreList{ids: make(map[string]struct{})}
+
+	b, err := ioutil.ReadFile(filepath.Join(dir, igignoreFilename))
+	if os.IsNotExist(err) {
+		return &il, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	for _, line := range strings.Split(string(b), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if strings.ContainsAny(line, "*?[") {
+			il.patterns = append(il.patterns, line)
+		} else {
+			il.ids[line] = struct{}{}
+		}
+	}
+	return &il, nil
+}
+
+// Match reports whether m should be skipped, either by its exact ID
+// or because its filename matches one of the glob patterns.
+func (il *ignoreList) Match(m *igshelf.Media) bool {
+	if _, ok := il.ids[m.ID]; ok {
+		return true
+	}
+	for _, p := range il.patterns {
+		if ok, _ := filepath.Match(p, m.Filename); ok {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,49 @@
+package downloader
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/marselester/igshelf"
+)
+
+func TestIgnoreListMatch(t *testing.T) {
+	dir := t.TempDir()
+	content := "# skip this one\n17863188140095492\n\n*_private.jpg\n"
+	if err := ioutil.WriteFile(filepath.Join(dir, igignoreFilename), []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	il, err := loadIgnoreList(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tt := map[string]struct {
+		m    *igshelf.Media
+		want bool
+	}{
+		"exact id":        {&igshelf.Media{ID: "17863188140095492", Filename: "whatever.jpg"}, true},
+		"glob match":      {&igshelf.Media{ID: "1", Filename: "202010_private.jpg"}, true},
+		"no match":        {&igshelf.Media{ID: "2", Filename: "202010_public.jpg"}, false},
+		"comment ignored": {&igshelf.Media{ID: "skip this one"}, false},
+	}
+	for name, tc := range tt {
+		t.Run(name, func(t *testing.T) {
+			if got := il.Match(tc.m); got != tc.want {
+				t.Errorf("got %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestLoadIgnoreListMissing(t *testing.T) {
+	il, err := loadIgnoreList(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if il.Match(&igshelf.Media{ID: "1"}) {
+		t.Error("expected no media to be ignored when .igignore is absent")
+	}
+}
@@ -0,0 +1,32 @@
+package downloader
+
+// Logger is the minimal logging interface the downloader depends on.
+// It matches github.com/go-kit/kit/log.Logger's Log method exactly, so an
+// existing go-kit logger can be passed to WithLogger as is; callers who'd
+// rather not pull in go-kit can implement Logger directly, e.g. on top of
+// log/slog, or pass a LoggerFunc.
+//
+// A call may include a "level" key ("debug", "info", or "error") to mark
+// routine, high-volume messages (e.g. a per-file skip) as "debug" so a
+// leveled Logger, such as the one WithSlog builds, can suppress them
+// without the downloader knowing anything about its caller's verbosity
+// settings. Calls with no "level" default to "info", except that an "err"
+// key still bumps them to "error" for backward compatibility.
+type Logger interface {
+	Log(keyvals ...interface{}) error
+}
+
+// LoggerFunc adapts a bare Log-shaped function into a Logger, e.g. to wrap
+// an existing logger without depending on its package:
+// downloader.LoggerFunc(logger.Log).
+type LoggerFunc func(keyvals ...interface{}) error
+
+// Log calls f.
+func (f LoggerFunc) Log(keyvals ...interface{}) error {
+	return f(keyvals...)
+}
+
+// nopLogger discards every log line. It's the default until WithLogger is used.
+type nopLogger struct{}
+
+func (nopLogger) Log(...interface{}) error { return nil }
@@ -0,0 +1,103 @@
+package downloader
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// progressRateSmoothing weights how much a new throughput sample moves the
+// moving average: closer to 1 tracks recent bursts more closely, closer to
+// 0 smooths them out. It's not exported since callers have no use for
+// tuning it separately from Progress itself.
+const progressRateSmoothing = 0.3
+
+// Progress tracks bytes downloaded against an expected total and estimates
+// throughput and time remaining from it. It's safe for concurrent use, so
+// worker goroutines can report bytes as downloads complete without a
+// caller synchronizing access itself.
+type Progress struct {
+	mu sync.Mutex
+
+	total     int64
+	completed int64
+	rate      float64
+	lastTime  time.Time
+}
+
+// NewProgress creates a Progress that tracks completed bytes against
+// total, e.g. a prior run's Stats.TotalBytes used as an estimate.
+func NewProgress(total int64) *Progress {
+	return &Progress{total: total}
+}
+
+// Add records n more downloaded bytes and updates the moving-average
+// throughput estimate. It's safe to call from multiple worker goroutines
+// concurrently.
+func (p *Progress) Add(n int64) {
+	now := time.Now()
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.completed += n
+	if p.lastTime.IsZero() {
+		p.lastTime = now
+		return
+	}
+
+	elapsed := now.Sub(p.lastTime).Seconds()
+	p.lastTime = now
+	if elapsed <= 0 {
+		return
+	}
+	instant := float64(n) / elapsed
+	if p.rate == 0 {
+		p.rate = instant
+	} else {
+		p.rate = progressRateSmoothing*instant + (1-progressRateSmoothing)*p.rate
+	}
+}
+
+// Percent returns how much of total has been completed, in [0, 100]. It
+// returns 0 if total is unknown (zero or negative).
+func (p *Progress) Percent() float64 {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.total <= 0 {
+		return 0
+	}
+	pct := float64(p.completed) / float64(p.total) * 100
+	if pct > 100 {
+		pct = 100
+	}
+	return pct
+}
+
+// Rate returns the current moving-average throughput in bytes/sec.
+func (p *Progress) Rate() float64 {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.rate
+}
+
+// ETA estimates the time left to reach total at the current Rate. It
+// returns 0 if the rate isn't known yet or total has already been reached.
+func (p *Progress) ETA() time.Duration {
+	p.mu.Lock()
+	remaining := p.total - p.completed
+	rate := p.rate
+	p.mu.Unlock()
+
+	if remaining <= 0 || rate <= 0 {
+		return 0
+	}
+	return time.Duration(float64(remaining)/rate) * time.Second
+}
+
+// String renders progress the way a CLI would show it, e.g.
+// "42% — 3.1 MB/s — ETA 2m10s".
+func (p *Progress) String() string {
+	return fmt.Sprintf("%.0f%% — %.1f MB/s — ETA %s", p.Percent(), p.Rate()/1e6, p.ETA().Round(time.Second))
+}
@@ -0,0 +1,44 @@
+package downloader
+
+import (
+	"testing"
+	"time"
+)
+
+// TestProgressETADecreases feeds synthetic byte updates at a steady rate
+// and checks the ETA trends towards zero as completed approaches total,
+// rather than growing or resetting between updates.
+func TestProgressETADecreases(t *testing.T) {
+	p := NewProgress(1000)
+
+	var last time.Duration = -1
+	for i := 0; i < 10; i++ {
+		p.Add(100)
+		// Add advances lastTime from time.Now(), so a real (tiny) sleep is
+		// needed between calls for the rate estimate to be based on
+		// distinct timestamps rather than a zero elapsed duration.
+		time.Sleep(time.Millisecond)
+
+		eta := p.ETA()
+		if last >= 0 && eta > last {
+			t.Errorf("update %d: ETA increased from %s to %s", i, last, eta)
+		}
+		last = eta
+	}
+
+	if got := p.Percent(); got != 100 {
+		t.Errorf("got %.0f%% complete, want 100%%", got)
+	}
+	if got := p.ETA(); got != 0 {
+		t.Errorf("got ETA %s once complete, want 0", got)
+	}
+}
+
+func TestProgressPercentUnknownTotal(t *testing.T) {
+	p := NewProgress(0)
+	p.Add(500)
+
+	if got := p.Percent(); got != 0 {
+		t.Errorf("got %.0f%%, want 0%% for an unknown total", got)
+	}
+}
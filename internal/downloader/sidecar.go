@@ -0,0 +1,32 @@
+package downloader
+
+import (
+	"encoding/json"
+	"os"
+	"time"
+
+	"github.com/marselester/igshelf"
+)
+
+// sidecarEntry is the content of a <Filename>.json sidecar, see WithSidecars.
+type sidecarEntry struct {
+	ID        string    `json:"id"`
+	Caption   string    `json:"caption"`
+	TakenAt   time.Time `json:"taken_at"`
+	Permalink string    `json:"permalink,omitempty"`
+}
+
+// writeSidecar writes a <contentPath>.json sidecar describing m.
+func writeSidecar(fsys WriteFS, contentPath string, m *igshelf.Media, mode os.FileMode) error {
+	entry := sidecarEntry{
+		ID:        m.ID,
+		Caption:   m.Caption,
+		TakenAt:   m.TakenAt,
+		Permalink: m.Permalink,
+	}
+	b, err := json.MarshalIndent(entry, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFile(fsys, contentPath+".json", b, mode)
+}
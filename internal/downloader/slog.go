@@ -0,0 +1,65 @@
+package downloader
+
+import (
+	"context"
+	"log/slog"
+)
+
+// WithSlog configures the downloader to log through a standard library
+// log/slog.Logger instead of implementing Logger directly.
+func WithSlog(l *slog.Logger) ConfigOption {
+	return func(s *Service) {
+		s.logger = slogLogger{l}
+	}
+}
+
+// slogLogger adapts a *slog.Logger to Logger, translating go-kit style
+// "msg", <text>, key, value, ... pairs into a single slog record. An "err"
+// key bumps the record to slog.LevelError, since every existing call site
+// that logs an error expects it to stand out. A "level" key overrides that
+// default outright, e.g. so a routine per-file skip can log at
+// slog.LevelDebug and stay quiet unless the caller's handler is configured
+// to show it.
+type slogLogger struct {
+	l *slog.Logger
+}
+
+func (a slogLogger) Log(keyvals ...interface{}) error {
+	level := slog.LevelInfo
+	msg := ""
+	attrs := make([]interface{}, 0, len(keyvals))
+	for i := 0; i+1 < len(keyvals); i += 2 {
+		key, _ := keyvals[i].(string)
+		val := keyvals[i+1]
+		switch key {
+		case "msg":
+			msg, _ = val.(string)
+			continue
+		case "level":
+			if s, ok := val.(string); ok {
+				level = parseLevel(s)
+			}
+			continue
+		case "err":
+			level = slog.LevelError
+		}
+		attrs = append(attrs, key, val)
+	}
+	a.l.Log(context.Background(), level, msg, attrs...)
+	return nil
+}
+
+// parseLevel maps a Logger "level" keyval to its slog.Level, defaulting to
+// slog.LevelInfo for an empty or unrecognized value.
+func parseLevel(s string) slog.Level {
+	switch s {
+	case "debug":
+		return slog.LevelDebug
+	case "warn":
+		return slog.LevelWarn
+	case "error":
+		return slog.LevelError
+	default:
+		return slog.LevelInfo
+	}
+}
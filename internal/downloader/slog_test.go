@@ -0,0 +1,116 @@
+package downloader
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/marselester/igshelf"
+	"github.com/marselester/igshelf/internal/mock"
+)
+
+// recordingHandler captures the last slog.Record it's asked to handle,
+// so a test can inspect the attributes attached to a download failure log.
+// level filters like a real handler would, e.g. slog.LevelInfo (the zero
+// value) drops slog.LevelDebug records.
+type recordingHandler struct {
+	record slog.Record
+	level  slog.Level
+}
+
+func (h *recordingHandler) Enabled(_ context.Context, level slog.Level) bool {
+	return level >= h.level
+}
+func (h *recordingHandler) Handle(_ context.Context, r slog.Record) error {
+	h.record = r
+	return nil
+}
+func (h *recordingHandler) WithAttrs(attrs []slog.Attr) slog.Handler { return h }
+func (h *recordingHandler) WithGroup(name string) slog.Handler       { return h }
+
+func (h *recordingHandler) attr(key string) (slog.Value, bool) {
+	var (
+		val   slog.Value
+		found bool
+	)
+	h.record.Attrs(func(a slog.Attr) bool {
+		if a.Key == key {
+			val, found = a.Value, true
+			return false
+		}
+		return true
+	})
+	return val, found
+}
+
+func TestWithSlog(t *testing.T) {
+	timeline := []*igshelf.Media{
+		{ID: "1", Type: igshelf.MediaTypeImage, Filename: "1.jpg"},
+	}
+
+	ig := mock.MediaService{
+		ListFn: func(since time.Time) igshelf.MediaIter {
+			return &mock.MediaIter{Batch: timeline}
+		},
+		DownloadFn: func(m *igshelf.Media) ([]byte, []byte, error) {
+			return nil, nil, fmt.Errorf("file not found in archive")
+		},
+	}
+	db := mock.MediaRepository{}
+
+	h := &recordingHandler{}
+	s := NewService(&ig, &db, WithSlog(slog.New(h)))
+
+	if _, err := s.Download(context.Background(), "testdata"); err != nil {
+		t.Fatal(err)
+	}
+
+	if got, want := h.record.Level, slog.LevelError; got != want {
+		t.Errorf("got level %v, want %v", got, want)
+	}
+
+	media, ok := h.attr("media")
+	if !ok {
+		t.Fatal("expected a media attribute")
+	}
+	if got, want := media.Any().(*igshelf.Media).ID, "1"; got != want {
+		t.Errorf("got media ID %q, want %q", got, want)
+	}
+
+	errVal, ok := h.attr("err")
+	if !ok {
+		t.Fatal("expected an err attribute")
+	}
+	if got, want := errVal.Any().(error).Error(), "file not found in archive"; got != want {
+		t.Errorf("got err %q, want %q", got, want)
+	}
+}
+
+// TestWithSlogDebugSuppressedAtInfoLevel checks that a routine per-file skip
+// (logged at "debug") doesn't reach a handler configured for slog.LevelInfo,
+// so -log-level=info doesn't flood output with them.
+func TestWithSlogDebugSuppressedAtInfoLevel(t *testing.T) {
+	timeline := []*igshelf.Media{
+		{ID: "1", Type: igshelf.MediaTypeImage, Filename: "1.jpg", Unavailable: true},
+	}
+
+	ig := mock.MediaService{
+		ListFn: func(since time.Time) igshelf.MediaIter {
+			return &mock.MediaIter{Batch: timeline}
+		},
+	}
+	db := mock.MediaRepository{}
+
+	h := &recordingHandler{level: slog.LevelInfo}
+	s := NewService(&ig, &db, WithSlog(slog.New(h)))
+
+	if _, err := s.Download(context.Background(), "testdata"); err != nil {
+		t.Fatal(err)
+	}
+
+	if h.record.Message != "" {
+		t.Errorf("got message %q, want none: a debug-level skip shouldn't reach an info-level handler", h.record.Message)
+	}
+}
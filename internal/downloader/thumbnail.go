@@ -0,0 +1,80 @@
+package downloader
+
+import (
+	"bytes"
+	"image"
+	"image/jpeg"
+	"path/filepath"
+
+	_ "image/gif"
+	_ "image/png"
+
+	"github.com/marselester/igshelf"
+)
+
+// assignImageThumbnailFilenames gives every image (top-level or album child)
+// a ThumbnailFilename derived from its Filename, so timeline.json records
+// where WithImageThumbnails will write the downscaled copy.
+func assignImageThumbnailFilenames(timeline []*igshelf.Media) {
+	for _, m := range timeline {
+		assignImageThumbnailFilename(m)
+		for _, c := range m.Children {
+			assignImageThumbnailFilename(c)
+		}
+	}
+}
+
+func assignImageThumbnailFilename(m *igshelf.Media) {
+	if m.Type != igshelf.MediaTypeImage || m.Filename == "" || m.ThumbnailFilename != "" {
+		return
+	}
+	ext := filepath.Ext(m.Filename)
+	m.ThumbnailFilename = m.Filename[:len(m.Filename)-len(ext)] + "_thumb.jpg"
+}
+
+// scaleImageThumbnail decodes an encoded image (JPEG, PNG, or GIF) and
+// returns a downscaled JPEG whose longest side is at most maxDim, preserving
+// aspect ratio. It's re-encoded as JPEG regardless of the source format, so
+// callers always get a stable extension for the thumbnail file.
+func scaleImageThumbnail(content []byte, maxDim int) ([]byte, error) {
+	src, _, err := image.Decode(bytes.NewReader(content))
+	if err != nil {
+		return nil, err
+	}
+
+	bounds := src.Bounds()
+	srcW, srcH := bounds.Dx(), bounds.Dy()
+	dstW, dstH := srcW, srcH
+	if srcW > maxDim || srcH > maxDim {
+		if srcW >= srcH {
+			dstW = maxDim
+			dstH = srcH * maxDim / srcW
+		} else {
+			dstH = maxDim
+			dstW = srcW * maxDim / srcH
+		}
+	}
+	if dstW < 1 {
+		dstW = 1
+	}
+	if dstH < 1 {
+		dstH = 1
+	}
+
+	// Nearest-neighbor sampling keeps this dependency-free; thumbnails are
+	// small enough that resampling quality isn't worth pulling in a library.
+	dst := image.NewRGBA(image.Rect(0, 0, dstW, dstH))
+	for y := 0; y < dstH; y++ {
+		sy := bounds.Min.Y + y*srcH/dstH
+		for x := 0; x < dstW; x++ {
+			sx := bounds.Min.X + x*srcW/dstW
+			dst.Set(x, y, src.At(sx, sy))
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, dst, nil); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,40 @@
+package downloader
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"testing"
+)
+
+func TestScaleImageThumbnail(t *testing.T) {
+	src := image.NewRGBA(image.Rect(0, 0, 800, 400))
+	for y := 0; y < 400; y++ {
+		for x := 0; x < 800; x++ {
+			src.Set(x, y, color.RGBA{R: uint8(x % 256), G: uint8(y % 256), B: 0, A: 255})
+		}
+	}
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, src, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	const maxDim = 200
+	got, err := scaleImageThumbnail(buf.Bytes(), maxDim)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	thumb, _, err := image.Decode(bytes.NewReader(got))
+	if err != nil {
+		t.Fatal(err)
+	}
+	bounds := thumb.Bounds()
+	if bounds.Dx() > maxDim || bounds.Dy() > maxDim {
+		t.Errorf("got bounds %v, want both sides within %d", bounds, maxDim)
+	}
+	if bounds.Dx() != maxDim {
+		t.Errorf("got width %d, want %d (longest side scaled to maxDim)", bounds.Dx(), maxDim)
+	}
+}
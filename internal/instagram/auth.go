@@ -0,0 +1,147 @@
+package instagram
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+const (
+	// defaultAuthorizeURL is where a user is sent to grant an app access to
+	// their account, per Instagram's Basic Display authorization code flow.
+	defaultAuthorizeURL = "https://api.instagram.com/oauth/authorize"
+	// defaultTokenURL is where an authorization code is exchanged for an
+	// access token, see ExchangeCode.
+	defaultTokenURL = "https://api.instagram.com/oauth/access_token"
+)
+
+// AuthOption configures AuthCodeURL and ExchangeCode.
+type AuthOption func(*authConfig)
+
+type authConfig struct {
+	authorizeURL string
+	tokenURL     string
+	httpClient   *http.Client
+}
+
+// WithAuthorizeURL overrides the authorization endpoint AuthCodeURL builds
+// a URL against, e.g. to point at a mock server in tests.
+func WithAuthorizeURL(authorizeURL string) AuthOption {
+	return func(c *authConfig) {
+		c.authorizeURL = authorizeURL
+	}
+}
+
+// WithTokenURL overrides the token endpoint ExchangeCode posts to, e.g. to
+// point at a mock server in tests.
+func WithTokenURL(tokenURL string) AuthOption {
+	return func(c *authConfig) {
+		c.tokenURL = tokenURL
+	}
+}
+
+// WithAuthHTTPClient sets the http.Client ExchangeCode uses to reach the
+// token endpoint, e.g. to inject a timeout or a mock transport.
+func WithAuthHTTPClient(httpClient *http.Client) AuthOption {
+	return func(c *authConfig) {
+		c.httpClient = httpClient
+	}
+}
+
+// Token is the result of exchanging an authorization code for API access,
+// see ExchangeCode.
+type Token struct {
+	// AccessToken authenticates subsequent Client requests.
+	AccessToken string `json:"access_token"`
+	// UserID identifies the Instagram user who authorized the app.
+	UserID int64 `json:"user_id"`
+}
+
+// AuthCodeURL builds the URL a user should be redirected to in order to
+// grant an app access to their Instagram account, the first step of
+// Instagram's Basic Display authorization code flow. clientID and
+// redirectURI must match the app's Instagram configuration exactly.
+// scopes is typically []string{"user_profile", "user_media"}.
+func AuthCodeURL(clientID, redirectURI string, scopes []string, options ...AuthOption) string {
+	c := authConfig{authorizeURL: defaultAuthorizeURL}
+	for _, opt := range options {
+		opt(&c)
+	}
+
+	q := url.Values{
+		"client_id":     {clientID},
+		"redirect_uri":  {redirectURI},
+		"scope":         {strings.Join(scopes, ",")},
+		"response_type": {"code"},
+	}
+	return c.authorizeURL + "?" + q.Encode()
+}
+
+// ExchangeCode exchanges an authorization code obtained from AuthCodeURL's
+// redirect for an access token, the second step of Instagram's Basic
+// Display authorization code flow.
+func ExchangeCode(ctx context.Context, clientID, clientSecret, redirectURI, code string, options ...AuthOption) (Token, error) {
+	c := authConfig{tokenURL: defaultTokenURL, httpClient: http.DefaultClient}
+	for _, opt := range options {
+		opt(&c)
+	}
+
+	form := url.Values{
+		"client_id":     {clientID},
+		"client_secret": {clientSecret},
+		"grant_type":    {"authorization_code"},
+		"redirect_uri":  {redirectURI},
+		"code":          {code},
+	}
+	req, err := http.NewRequest(http.MethodPost, c.tokenURL, bytes.NewBufferString(form.Encode()))
+	if err != nil {
+		return Token{}, fmt.Errorf("failed to build the token request: %w", err)
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return Token{}, fmt.Errorf("failed to reach the token endpoint: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return Token{}, fmt.Errorf("failed to read the token response: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		var authErr AuthError
+		if err := json.Unmarshal(body, &authErr); err != nil {
+			return Token{}, fmt.Errorf("token endpoint returned %d: %s", resp.StatusCode, body)
+		}
+		return Token{}, authErr
+	}
+
+	var tok Token
+	if err := json.Unmarshal(body, &tok); err != nil {
+		return Token{}, fmt.Errorf("failed to decode the token response: %w", err)
+	}
+	return tok, nil
+}
+
+// AuthError is returned by ExchangeCode when the token endpoint rejects an
+// authorization code, e.g. because it already expired or was already used.
+type AuthError struct {
+	// Type is Instagram's machine-readable error category, e.g. "OAuthException".
+	Type string `json:"error_type"`
+	// Code is Instagram's numeric error code.
+	Code int `json:"code"`
+	// Message is a human-readable description of the error.
+	Message string `json:"error_message"`
+}
+
+func (e AuthError) Error() string {
+	return fmt.Sprintf("%s %d: %s", e.Type, e.Code, e.Message)
+}
@@ -0,0 +1,114 @@
+package instagram
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+)
+
+// TestAuthCodeURL checks that AuthCodeURL builds an authorization URL with
+// the expected query parameters, joining scopes with a comma as Instagram
+// expects.
+func TestAuthCodeURL(t *testing.T) {
+	got := AuthCodeURL("client-id", "https://example.com/callback", []string{"user_profile", "user_media"})
+
+	u, err := url.Parse(got)
+	if err != nil {
+		t.Fatalf("got an unparseable URL %q: %v", got, err)
+	}
+	if got, want := u.Scheme+"://"+u.Host+u.Path, defaultAuthorizeURL; got != want {
+		t.Errorf("got endpoint %q, want %q", got, want)
+	}
+
+	q := u.Query()
+	cases := map[string]string{
+		"client_id":     "client-id",
+		"redirect_uri":  "https://example.com/callback",
+		"scope":         "user_profile,user_media",
+		"response_type": "code",
+	}
+	for k, want := range cases {
+		if got := q.Get(k); got != want {
+			t.Errorf("query param %q: got %q, want %q", k, got, want)
+		}
+	}
+}
+
+// TestAuthCodeURLCustomEndpoint checks that WithAuthorizeURL overrides the
+// default authorization endpoint.
+func TestAuthCodeURLCustomEndpoint(t *testing.T) {
+	got := AuthCodeURL("client-id", "https://example.com/callback", nil,
+		WithAuthorizeURL("https://mock.test/oauth/authorize"))
+
+	if want := "https://mock.test/oauth/authorize?"; got[:len(want)] != want {
+		t.Errorf("got %q, want it to start with %q", got, want)
+	}
+}
+
+// TestExchangeCode checks that ExchangeCode posts the expected
+// form-encoded fields to the token endpoint and decodes a successful
+// response into a Token.
+func TestExchangeCode(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if got, want := r.Method, http.MethodPost; got != want {
+			t.Errorf("got method %q, want %q", got, want)
+		}
+		if err := r.ParseForm(); err != nil {
+			t.Fatal(err)
+		}
+		cases := map[string]string{
+			"client_id":     "client-id",
+			"client_secret": "client-secret",
+			"grant_type":    "authorization_code",
+			"redirect_uri":  "https://example.com/callback",
+			"code":          "abc123",
+		}
+		for k, want := range cases {
+			if got := r.PostForm.Get(k); got != want {
+				t.Errorf("form field %q: got %q, want %q", k, got, want)
+			}
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"access_token": "IGQVJ...", "user_id": 17841401}`))
+	}))
+	defer srv.Close()
+
+	got, err := ExchangeCode(context.Background(), "client-id", "client-secret", "https://example.com/callback", "abc123",
+		WithTokenURL(srv.URL))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := Token{AccessToken: "IGQVJ...", UserID: 17841401}
+	if got != want {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+}
+
+// TestExchangeCodeError checks that a rejected authorization code surfaces
+// as an AuthError decoded from the token endpoint's error body.
+func TestExchangeCodeError(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"error_type": "OAuthException", "code": 400, "error_message": "Matching code was not found or was already used"}`))
+	}))
+	defer srv.Close()
+
+	_, err := ExchangeCode(context.Background(), "client-id", "client-secret", "https://example.com/callback", "stale-code",
+		WithTokenURL(srv.URL))
+	if err == nil {
+		t.Fatal("expected an error for a rejected code")
+	}
+
+	authErr, ok := err.(AuthError)
+	if !ok {
+		t.Fatalf("got error of type %T, want AuthError", err)
+	}
+	if got, want := authErr.Type, "OAuthException"; got != want {
+		t.Errorf("got type %q, want %q", got, want)
+	}
+}
@@ -8,6 +8,8 @@ import (
 	"io/ioutil"
 	"net/http"
 	"net/url"
+	"strings"
+	"time"
 )
 
 const (
@@ -32,11 +34,78 @@ func WithHTTPClient(httpClient *http.Client) ConfigOption {
 	}
 }
 
+// WithTransport wraps Client's underlying http.Client with a custom
+// http.RoundTripper, e.g. to route requests through a proxy or trust
+// a custom set of root CAs, without having to build a whole http.Client.
+func WithTransport(rt http.RoundTripper) ConfigOption {
+	return func(c *Client) {
+		c.httpClient = &http.Client{
+			Timeout:   c.httpClient.Timeout,
+			Transport: rt,
+		}
+	}
+}
+
+// WithTransportLimits tunes the underlying http.Client's transport to cap
+// concurrent connections per host, which matters when downloading media
+// concurrently from Instagram's CDN: too many connections to the same host
+// can get throttled or reset. maxConnsPerHost caps the total (idle + active)
+// connections per host, maxIdleConnsPerHost caps how many idle ones are
+// kept around for reuse; both map directly to the http.Transport fields of
+// the same name.
+func WithTransportLimits(maxConnsPerHost, maxIdleConnsPerHost int) ConfigOption {
+	return func(c *Client) {
+		rt := c.httpClient.Transport
+		if rt == nil {
+			rt = http.DefaultTransport
+		}
+		t, ok := rt.(*http.Transport)
+		if ok {
+			t = t.Clone()
+		} else {
+			t = http.DefaultTransport.(*http.Transport).Clone()
+		}
+		t.MaxConnsPerHost = maxConnsPerHost
+		t.MaxIdleConnsPerHost = maxIdleConnsPerHost
+
+		c.httpClient = &http.Client{
+			Timeout:   c.httpClient.Timeout,
+			Transport: t,
+		}
+	}
+}
+
+// WithTimeout sets a timeout on Client's underlying http.Client, separate
+// from any deadline on the context passed to NewRequest, so a connection
+// that hangs (e.g. a stalled TCP handshake) can't block a request forever.
+// The default http.Client has no timeout, so this is a safer default for
+// the CLI to opt into.
+func WithTimeout(d time.Duration) ConfigOption {
+	return func(c *Client) {
+		c.httpClient = &http.Client{
+			Timeout:   d,
+			Transport: c.httpClient.Transport,
+		}
+	}
+}
+
+// WithResponseHook registers a function invoked after each response is
+// read, with the raw response body, mainly for debug logging when the API
+// schema drifts. It runs regardless of whether unmarshaling succeeds.
+func WithResponseHook(fn func(req *http.Request, status int, body []byte)) ConfigOption {
+	return func(c *Client) {
+		c.onResponse = fn
+	}
+}
+
 // Client manages communication with the Instagram API.
 type Client struct {
 	accessToken string
 	baseURL     string
 	httpClient  *http.Client
+
+	// onResponse is called with the raw response body, see WithResponseHook.
+	onResponse func(req *http.Request, status int, body []byte)
 }
 
 // NewClient returns a Client which can be configured with config options.
@@ -57,17 +126,28 @@ func NewClient(accessToken string, options ...ConfigOption) *Client {
 // Query string parameters are optional.
 // If specified, the value pointed to by body is JSON encoded and included as the request body.
 func (c *Client) NewRequest(ctx context.Context, method, path string, queryParams url.Values, bodyParams interface{}) (*http.Request, error) {
-	var urlStr string
-	if queryParams != nil {
-		urlStr = fmt.Sprintf("%s/%s?%s", c.baseURL, path, queryParams.Encode())
-	} else {
-		urlStr = fmt.Sprintf("%s/%s", c.baseURL, path)
+	base, err := url.Parse(c.baseURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid base url %q: %w", c.baseURL, err)
 	}
 
-	var (
-		b   []byte
-		err error
-	)
+	// ResolveReference treats base's last path segment as a file to be
+	// replaced unless the path ends with a slash, which would otherwise
+	// drop a base URL's own path prefix, e.g. https://proxy/instagram.
+	q := base.Query()
+	base.Path = strings.TrimSuffix(base.Path, "/") + "/"
+	base.RawQuery = ""
+	u := base.ResolveReference(&url.URL{Path: path})
+
+	for k, vv := range queryParams {
+		for _, v := range vv {
+			q.Add(k, v)
+		}
+	}
+	u.RawQuery = q.Encode()
+	urlStr := u.String()
+
+	var b []byte
 	if bodyParams != nil {
 		if b, err = json.Marshal(bodyParams); err != nil {
 			return nil, err
@@ -103,9 +183,13 @@ func (c *Client) Do(req *http.Request, v interface{}) (*http.Response, error) {
 	if err != nil {
 		return resp, err
 	}
+	if c.onResponse != nil {
+		c.onResponse(req, resp.StatusCode, body)
+	}
 
 	e := Error{
 		HTTPStatusCode: resp.StatusCode,
+		Headers:        resp.Header,
 		Body:           string(body),
 	}
 
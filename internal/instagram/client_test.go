@@ -0,0 +1,270 @@
+package instagram
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+// recordingRoundTripper records the last request it saw and returns a canned response.
+type recordingRoundTripper struct {
+	req        *http.Request
+	body       string
+	statusCode int
+}
+
+func (rt *recordingRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	rt.req = req
+	statusCode := rt.statusCode
+	if statusCode == 0 {
+		statusCode = http.StatusOK
+	}
+	return &http.Response{
+		StatusCode: statusCode,
+		Body:       ioutil.NopCloser(bytes.NewBufferString(rt.body)),
+		Header:     make(http.Header),
+	}, nil
+}
+
+// TestErrorHeaders checks that Client.Do captures the response headers on
+// an error response, so a caller can inspect things like x-fb-trace-id or
+// retry-after without them being folded into Error()'s string output.
+func TestErrorHeaders(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("x-fb-trace-id", "Abc123")
+		w.Header().Set("retry-after", "30")
+		w.WriteHeader(http.StatusTooManyRequests)
+		fmt.Fprint(w, `{"error": {"message": "rate limited", "type": "IGApiException", "code": 4}}`)
+	}))
+	defer srv.Close()
+
+	c := NewClient("token", WithBaseURL(srv.URL))
+	req, err := c.NewRequest(context.Background(), http.MethodGet, "me/media", nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var v struct{}
+	_, err = c.Do(req, &v)
+
+	var igErr Error
+	if !errors.As(err, &igErr) {
+		t.Fatalf("got %T, want instagram.Error", err)
+	}
+	if got, want := igErr.Headers.Get("x-fb-trace-id"), "Abc123"; got != want {
+		t.Errorf("got trace ID %q, want %q", got, want)
+	}
+	if got, want := igErr.Headers.Get("retry-after"), "30"; got != want {
+		t.Errorf("got retry-after %q, want %q", got, want)
+	}
+	if strings.Contains(igErr.Error(), "Abc123") {
+		t.Error("Error() string shouldn't include header values")
+	}
+}
+
+// TestClientWithTimeout checks that a request to a server that hangs past
+// WithTimeout's duration errors, rather than blocking forever.
+func TestClientWithTimeout(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(50 * time.Millisecond)
+		w.Write([]byte(`{"data": []}`))
+	}))
+	defer srv.Close()
+
+	c := NewClient("token", WithBaseURL(srv.URL), WithTimeout(5*time.Millisecond))
+
+	req, err := c.NewRequest(context.Background(), http.MethodGet, "me/media", nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var v struct {
+		Data []interface{} `json:"data"`
+	}
+	if _, err = c.Do(req, &v); err == nil {
+		t.Error("got no error for a request exceeding the client timeout, want one")
+	}
+}
+
+func TestClientWithTransport(t *testing.T) {
+	rt := recordingRoundTripper{body: `{"data": []}`}
+	c := NewClient("token", WithTransport(&rt))
+
+	req, err := c.NewRequest(context.Background(), http.MethodGet, "me/media", nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var v struct {
+		Data []interface{} `json:"data"`
+	}
+	if _, err := c.Do(req, &v); err != nil {
+		t.Fatal(err)
+	}
+
+	if rt.req == nil {
+		t.Fatal("expected the custom transport to see the request")
+	}
+	if got, want := rt.req.URL.Path, "/me/media"; got != want {
+		t.Errorf("got request path %q, want %q", got, want)
+	}
+}
+
+// TestClientWithTransportLimits checks that MaxConnsPerHost and
+// MaxIdleConnsPerHost are set on the client's transport as given.
+func TestClientWithTransportLimits(t *testing.T) {
+	c := NewClient("token", WithTransportLimits(4, 2))
+
+	tr, ok := c.httpClient.Transport.(*http.Transport)
+	if !ok {
+		t.Fatalf("got transport %T, want *http.Transport", c.httpClient.Transport)
+	}
+	if got, want := tr.MaxConnsPerHost, 4; got != want {
+		t.Errorf("got MaxConnsPerHost %d, want %d", got, want)
+	}
+	if got, want := tr.MaxIdleConnsPerHost, 2; got != want {
+		t.Errorf("got MaxIdleConnsPerHost %d, want %d", got, want)
+	}
+}
+
+// TestClientWithTransportLimitsPreservesTimeout checks that applying
+// WithTransportLimits after WithTimeout doesn't drop the configured timeout.
+func TestClientWithTransportLimitsPreservesTimeout(t *testing.T) {
+	c := NewClient("token", WithTimeout(5*time.Second), WithTransportLimits(4, 2))
+
+	if got, want := c.httpClient.Timeout, 5*time.Second; got != want {
+		t.Errorf("got timeout %v, want %v", got, want)
+	}
+}
+
+func TestClientNewRequestBaseURL(t *testing.T) {
+	tt := map[string]struct {
+		baseURL  string
+		wantPath string
+	}{
+		"no trailing slash": {
+			baseURL:  "https://graph.instagram.com",
+			wantPath: "/me/media",
+		},
+		"trailing slash": {
+			baseURL:  "https://graph.instagram.com/",
+			wantPath: "/me/media",
+		},
+		"path prefix": {
+			baseURL:  "https://proxy.example.com/instagram",
+			wantPath: "/instagram/me/media",
+		},
+		"path prefix with trailing slash": {
+			baseURL:  "https://proxy.example.com/instagram/",
+			wantPath: "/instagram/me/media",
+		},
+	}
+
+	for name, tc := range tt {
+		t.Run(name, func(t *testing.T) {
+			rt := recordingRoundTripper{body: `{"data": []}`}
+			c := NewClient("token", WithBaseURL(tc.baseURL), WithTransport(&rt))
+
+			req, err := c.NewRequest(context.Background(), http.MethodGet, "me/media", nil, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var v struct {
+				Data []interface{} `json:"data"`
+			}
+			if _, err := c.Do(req, &v); err != nil {
+				t.Fatal(err)
+			}
+
+			if got := rt.req.URL.Path; got != tc.wantPath {
+				t.Errorf("got path %q, want %q", got, tc.wantPath)
+			}
+		})
+	}
+}
+
+func TestClientNewRequestPreservesBaseQuery(t *testing.T) {
+	rt := recordingRoundTripper{body: `{"data": []}`}
+	c := NewClient("token", WithBaseURL("https://proxy.example.com/instagram?key=abc"), WithTransport(&rt))
+
+	req, err := c.NewRequest(context.Background(), http.MethodGet, "me/media", url.Values{"fields": []string{"id"}}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var v struct {
+		Data []interface{} `json:"data"`
+	}
+	if _, err := c.Do(req, &v); err != nil {
+		t.Fatal(err)
+	}
+
+	q := rt.req.URL.Query()
+	if got, want := q.Get("key"), "abc"; got != want {
+		t.Errorf("got base query param %q, want %q", got, want)
+	}
+	if got, want := q.Get("fields"), "id"; got != want {
+		t.Errorf("got fields query param %q, want %q", got, want)
+	}
+}
+
+func TestClientWithResponseHook(t *testing.T) {
+	tt := map[string]struct {
+		body       string
+		statusCode int
+	}{
+		"success": {
+			body:       `{"data": []}`,
+			statusCode: http.StatusOK,
+		},
+		"error": {
+			body:       `{"error": {"message": "boom", "type": "IGApiException", "code": 100}}`,
+			statusCode: http.StatusBadRequest,
+		},
+	}
+
+	for name, tc := range tt {
+		t.Run(name, func(t *testing.T) {
+			rt := recordingRoundTripper{body: tc.body, statusCode: tc.statusCode}
+
+			var (
+				gotBody   []byte
+				gotStatus int
+			)
+			c := NewClient("token", WithTransport(&rt), WithResponseHook(func(req *http.Request, status int, body []byte) {
+				gotStatus = status
+				gotBody = body
+			}))
+
+			req, err := c.NewRequest(context.Background(), http.MethodGet, "me/media", nil, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var v struct {
+				Data []interface{} `json:"data"`
+			}
+			// Do may return an error for the "error" case, but the hook
+			// must still see the raw body.
+			c.Do(req, &v)
+
+			if diff := cmp.Diff(tc.body, string(gotBody)); diff != "" {
+				t.Errorf(diff)
+			}
+			if gotStatus != tc.statusCode {
+				t.Errorf("got status %d, want %d", gotStatus, tc.statusCode)
+			}
+		})
+	}
+}
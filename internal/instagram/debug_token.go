@@ -0,0 +1,54 @@
+package instagram
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// TokenInfo describes the validity, scopes, and expiry of a Client's
+// access token, as reported by Instagram's debug_token endpoint.
+type TokenInfo struct {
+	// Valid reports whether the token is still usable.
+	Valid bool
+	// Scopes lists the permissions granted to the token, e.g. "user_media".
+	Scopes []string
+	// ExpiresAt is when the token stops being usable.
+	ExpiresAt time.Time
+}
+
+// debugTokenResp mirrors the shape of debug_token's "data" object.
+type debugTokenResp struct {
+	Data struct {
+		IsValid   bool     `json:"is_valid"`
+		Scopes    []string `json:"scopes"`
+		ExpiresAt int64    `json:"expires_at"`
+	} `json:"data"`
+}
+
+// DebugToken reports whether the Client's access token is still valid and
+// which scopes it was granted, so a long-running backup can fail fast with
+// a clear message instead of discovering an expired or under-scoped token
+// partway through a download run.
+func (c *Client) DebugToken(ctx context.Context) (TokenInfo, error) {
+	q := url.Values{"input_token": []string{c.accessToken}}
+	req, err := c.NewRequest(ctx, http.MethodGet, "debug_token", q, nil)
+	if err != nil {
+		return TokenInfo{}, err
+	}
+
+	var v debugTokenResp
+	if _, err = c.Do(req, &v); err != nil {
+		return TokenInfo{}, err
+	}
+
+	info := TokenInfo{
+		Valid:  v.Data.IsValid,
+		Scopes: v.Data.Scopes,
+	}
+	if v.Data.ExpiresAt != 0 {
+		info.ExpiresAt = time.Unix(v.Data.ExpiresAt, 0)
+	}
+	return info, nil
+}
@@ -0,0 +1,79 @@
+package instagram
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+// TestClientDebugToken checks that DebugToken decodes a sample debug_token
+// response into a TokenInfo.
+func TestClientDebugToken(t *testing.T) {
+	expiresAt := time.Date(2027, time.January, 2, 3, 4, 5, 0, time.UTC)
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if got, want := r.URL.Query().Get("input_token"), "token"; got != want {
+			t.Errorf("got input_token %q, want %q", got, want)
+		}
+		fmt.Fprintf(w, `{"data": {"is_valid": true, "scopes": ["user_profile", "user_media"], "expires_at": %d}}`, expiresAt.Unix())
+	}))
+	defer srv.Close()
+
+	c := NewClient("token", WithBaseURL(srv.URL))
+	got, err := c.DebugToken(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := TokenInfo{
+		Valid:     true,
+		Scopes:    []string{"user_profile", "user_media"},
+		ExpiresAt: expiresAt,
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("DebugToken() mismatch (-want +got):\n%s", diff)
+	}
+}
+
+// TestClientDebugTokenExpired checks that an expired or revoked token is
+// reported as invalid rather than as an error, since the request itself
+// succeeds.
+func TestClientDebugTokenExpired(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"data": {"is_valid": false, "scopes": []}}`)
+	}))
+	defer srv.Close()
+
+	c := NewClient("token", WithBaseURL(srv.URL))
+	got, err := c.DebugToken(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Valid {
+		t.Error("got Valid true, want false")
+	}
+}
+
+// TestClientDebugTokenError checks that a failed request surfaces as an
+// instagram.Error, matching Client.Do's usual error handling.
+func TestClientDebugTokenError(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprint(w, `{"error": {"message": "Invalid OAuth access token", "type": "OAuthException", "code": 190}}`)
+	}))
+	defer srv.Close()
+
+	c := NewClient("token", WithBaseURL(srv.URL))
+	_, err := c.DebugToken(context.Background())
+
+	var igErr Error
+	if !errors.As(err, &igErr) {
+		t.Fatalf("got %T, want instagram.Error", err)
+	}
+}
@@ -3,6 +3,7 @@ package instagram
 import (
 	"errors"
 	"fmt"
+	"net/http"
 )
 
 // Error is the response returned when a call is unsuccessful.
@@ -20,6 +21,10 @@ type Error struct {
 
 	// HTTPStatusCode is an HTTP status code returned by a server.
 	HTTPStatusCode int
+	// Headers is the response headers returned by a server, e.g. to
+	// inspect x-fb-trace-id or retry-after when debugging rate limits.
+	// It's not included in Error() since headers are noisy to log by default.
+	Headers http.Header
 	// Body is the raw response returned by a server.
 	Body string
 	// Inner is a wrapped error, e.g., JSON serialization error.
@@ -0,0 +1,72 @@
+package instagram
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+
+	"github.com/marselester/igshelf"
+)
+
+// insightsMetrics are the per-media metrics requested from the insights
+// edge when WithInsights is enabled.
+const insightsMetrics = "likes,comments"
+
+// insightsResp mirrors the shape of a media's insights edge: a list of
+// named metrics, each carrying a single lifetime value.
+type insightsResp struct {
+	Data []struct {
+		Name   string `json:"name"`
+		Values []struct {
+			Value int `json:"value"`
+		} `json:"values"`
+	} `json:"data"`
+}
+
+// fetchInsights requests likes/comments counts for a single media ID.
+func (s *MediaService) fetchInsights(ctx context.Context, id string) (likes, comments int, err error) {
+	req, err := s.client.NewRequest(ctx, http.MethodGet, fmt.Sprintf("%s/insights", id), url.Values{"metric": []string{insightsMetrics}}, nil)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	var v insightsResp
+	if _, err = s.client.Do(req, &v); err != nil {
+		return 0, 0, err
+	}
+
+	for _, metric := range v.Data {
+		if len(metric.Values) == 0 {
+			continue
+		}
+		switch metric.Name {
+		case "likes":
+			likes = metric.Values[0].Value
+		case "comments":
+			comments = metric.Values[0].Value
+		}
+	}
+	return likes, comments, nil
+}
+
+// enrichInsights populates Likes and Comments on each of mm's top-level
+// media (album children have no insights of their own) when WithInsights
+// is set. A personal account doesn't support insights at all, and Instagram
+// reports that as an API error per media rather than up front, so a media
+// whose insights can't be fetched is logged and left with zero counts
+// instead of failing the whole List/ListBefore/Get call.
+func (s *MediaService) enrichInsights(ctx context.Context, mm []*igshelf.Media) {
+	if !s.insights {
+		return
+	}
+	for _, m := range mm {
+		likes, comments, err := s.fetchInsights(ctx, m.ID)
+		if err != nil {
+			s.logger.Log("level", "debug", "msg", "insights unavailable, skipping", "media", m.ID, "err", err)
+			continue
+		}
+		m.Likes = likes
+		m.Comments = comments
+	}
+}
@@ -0,0 +1,23 @@
+package instagram
+
+// Logger is the minimal logging interface MediaService depends on. It
+// matches github.com/go-kit/kit/log.Logger's Log method exactly, so an
+// existing go-kit logger can be passed to WithLogger as is; callers who'd
+// rather not pull in go-kit can implement Logger directly, or pass a
+// LoggerFunc.
+type Logger interface {
+	Log(keyvals ...interface{}) error
+}
+
+// LoggerFunc adapts a bare Log-shaped function into a Logger.
+type LoggerFunc func(keyvals ...interface{}) error
+
+// Log calls f.
+func (f LoggerFunc) Log(keyvals ...interface{}) error {
+	return f(keyvals...)
+}
+
+// nopLogger discards every log line. It's the default until WithLogger is used.
+type nopLogger struct{}
+
+func (nopLogger) Log(...interface{}) error { return nil }
@@ -3,10 +3,18 @@ package instagram
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"net/http"
 	"net/url"
+	"os"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/marselester/igshelf"
@@ -14,17 +22,221 @@ import (
 
 // MediaService provides access to user's Instagram timeline via Instagram Basic Display API.
 type MediaService struct {
-	client *Client
-	userID string
+	client          *Client
+	userID          string
+	prefetch        bool
+	urlRefresh      bool
+	allowEmptyMedia bool
+
+	// conditionalRequests makes Download send a stored ETag with
+	// If-None-Match and skip re-downloading on a 304, see WithConditionalRequests.
+	conditionalRequests bool
+	// etagMu guards etags, since Download runs concurrently across a
+	// downloader's worker pool.
+	etagMu sync.Mutex
+	// etags maps a media ID to the ETag its content was last downloaded with.
+	etags map[string]string
+
+	// logger debugs media unmarshaling, see WithLogger.
+	logger Logger
+
+	// fields is the comma-separated "fields" query param sent with every
+	// List/ListBefore/Get request, see WithFields.
+	fields string
+
+	// autoDropFields makes List/ListBefore retry a page once with a
+	// deprecated field removed, see WithAutoDropFields.
+	autoDropFields bool
+
+	// filenamePrefixLayout is prepended to assigned filenames, see WithFilenamePrefix.
+	filenamePrefixLayout string
+
+	// thumbnails makes List/Get assign ThumbnailFilename and Download fetch
+	// a video's cover image, see WithThumbnails.
+	thumbnails bool
+
+	// insights makes List/ListBefore/Get fetch each media's likes/comments
+	// counts, see WithInsights.
+	insights bool
+}
+
+// defaultFields is the "fields" query param requested when WithFields isn't
+// given, matching what buildMedia knows how to unmarshal.
+const defaultFields = "id,caption,media_type,media_url,permalink,thumbnail_url,timestamp,children{media_type,media_url,thumbnail_url}"
+
+// defaultFilenamePrefixLayout is the time.Format layout prepended to a
+// filename unless overridden with WithFilenamePrefix.
+const defaultFilenamePrefixLayout = "200601_"
+
+// ServiceOption configures the media service.
+type ServiceOption func(*MediaService)
+
+// WithPrefetch makes the iterator returned by List fetch the next page
+// in the background while the caller processes the current one,
+// instead of stalling on every page boundary.
+func WithPrefetch(enabled bool) ServiceOption {
+	return func(s *MediaService) {
+		s.prefetch = enabled
+	}
+}
+
+// WithURLRefresh makes Download re-resolve a media's signed CDN URL and
+// retry once when the stored Location has expired (HTTP 403 or 410),
+// which happens when Download runs long after List filled Location in.
+func WithURLRefresh(enabled bool) ServiceOption {
+	return func(s *MediaService) {
+		s.urlRefresh = enabled
+	}
+}
+
+// WithAllowEmptyMedia disables the zero-byte content check on Download, in
+// case a deployment relies on genuinely empty files (e.g. a probe/test
+// account). By default an HTTP 200 with an empty body is treated as an
+// error, since Instagram never legitimately serves an empty photo or video.
+func WithAllowEmptyMedia(enabled bool) ServiceOption {
+	return func(s *MediaService) {
+		s.allowEmptyMedia = enabled
+	}
+}
+
+// WithConditionalRequests makes Download store the ETag it receives for
+// each media ID and send it back as If-None-Match on a later Download of
+// the same media, treating a 304 response as unchanged and keeping
+// whatever's already stored locally instead of re-fetching it, e.g. when
+// periodically re-downloading to verify or refresh a timeline.
+func WithConditionalRequests(enabled bool) ServiceOption {
+	return func(s *MediaService) {
+		s.conditionalRequests = enabled
+	}
+}
+
+// WithLogger configures a logger to debug media unmarshaling, e.g. an
+// unexpected media_type on an album child.
+func WithLogger(l Logger) ServiceOption {
+	return func(s *MediaService) {
+		s.logger = l
+	}
+}
+
+// WithFields overrides the default "fields" query param requested from the
+// API, e.g. to add is_shared_to_feed or media_product_type, or drop children
+// to shrink the payload. buildMedia relies on id and media_type to make
+// sense of a response, so they're appended automatically when missing from
+// fields.
+func WithFields(fields ...string) ServiceOption {
+	return func(s *MediaService) {
+		s.fields = ensureFields(fields, "id", "media_type")
+	}
+}
+
+// ensureFields joins fields into a comma-separated query value, appending
+// any of required that isn't already present.
+func ensureFields(fields []string, required ...string) string {
+	have := make(map[string]struct{}, len(fields))
+	for _, f := range fields {
+		have[f] = struct{}{}
+	}
+	for _, r := range required {
+		if _, ok := have[r]; !ok {
+			fields = append(fields, r)
+		}
+	}
+	return strings.Join(fields, ",")
+}
+
+// WithFilenamePrefix overrides the time.Format layout prepended to
+// assigned filenames, e.g. "2006-01-02_" for a full date instead of the
+// default "200601_" year/month prefix. An empty layout disables the
+// prefix entirely.
+func WithFilenamePrefix(layout string) ServiceOption {
+	return func(s *MediaService) {
+		s.filenamePrefixLayout = layout
+	}
+}
+
+// WithThumbnails controls whether List/Get assign a video's
+// ThumbnailFilename and Download fetches its cover image. It defaults to
+// true; passing false saves a request per video for a caller who doesn't
+// care about covers.
+func WithThumbnails(enabled bool) ServiceOption {
+	return func(s *MediaService) {
+		s.thumbnails = enabled
+	}
+}
+
+// WithAutoDropFields makes List and ListBefore retry a page once, with the
+// offending field removed from the "fields" query param, when Instagram
+// rejects the request because a field has been deprecated (IGApiException
+// code 100, e.g. "Tried accessing nonexisting field (thumbnail_url) on node
+// type (Media)"). This trades a little completeness (the dropped field is
+// simply absent from the result) for still getting the rest of the page
+// instead of failing the whole pull.
+func WithAutoDropFields(enabled bool) ServiceOption {
+	return func(s *MediaService) {
+		s.autoDropFields = enabled
+	}
+}
+
+// WithInsights makes List, ListBefore, and Get additionally fetch each
+// media's likes and comments counts from Instagram's insights edge,
+// populating igshelf.Media's Likes and Comments fields. It's off by
+// default since insights cost an extra request per media and only work
+// for business/creator accounts; a personal account degrades gracefully,
+// leaving Likes/Comments at zero rather than failing the call.
+func WithInsights(enabled bool) ServiceOption {
+	return func(s *MediaService) {
+		s.insights = enabled
+	}
+}
+
+// deprecatedFieldPattern extracts the field name from an IGApiException
+// field-deprecation message, e.g. "Tried accessing nonexisting field
+// (thumbnail_url) on node type (Media)".
+var deprecatedFieldPattern = regexp.MustCompile(`field \((\w+)\)`)
+
+// deprecatedField reports the field name Instagram rejected, if err is an
+// IGApiException code 100 whose message matches the expected shape.
+func deprecatedField(err error) (string, bool) {
+	var e Error
+	if !errors.As(err, &e) || e.Code != 100 {
+		return "", false
+	}
+	m := deprecatedFieldPattern.FindStringSubmatch(e.Message)
+	if m == nil {
+		return "", false
+	}
+	return m[1], true
+}
+
+// dropField removes name from fields, a comma-separated "fields" query
+// value, leaving the rest untouched.
+func dropField(fields, name string) string {
+	parts := strings.Split(fields, ",")
+	kept := parts[:0]
+	for _, p := range parts {
+		if p != name {
+			kept = append(kept, p)
+		}
+	}
+	return strings.Join(kept, ",")
 }
 
 // NewService creates a media service that provides access to user's Instagram timeline via Instagram Basic Display API.
 // In most cases userID should be "me", but you can also set explicit ID such as 17843400535183040.
-func NewService(client *Client, userID string) *MediaService {
-	return &MediaService{
-		client: client,
-		userID: userID,
+func NewService(client *Client, userID string, options ...ServiceOption) *MediaService {
+	s := MediaService{
+		client:               client,
+		userID:               userID,
+		etags:                make(map[string]string),
+		logger:               nopLogger{},
+		fields:               defaultFields,
+		filenamePrefixLayout: defaultFilenamePrefixLayout,
+		thumbnails:           true,
+	}
+	for _, opt := range options {
+		opt(&s)
 	}
+	return &s
 }
 
 // media represents an image, video, or album requested from Instagram API.
@@ -55,55 +267,231 @@ type media struct {
 // timeISO8601 is used to parse Instagram's timestamp field, e.g., 2019-11-10T12:20:51+0000.
 type timeISO8601 time.Time
 
-// UnmarshalJSON decodes ISO 8601 time as time.Time.
+// timeISO8601Layouts are the ISO 8601 timestamp layouts observed in the wild:
+// archive media.json uses a colon offset, the API's documented format
+// doesn't, and some API responses use Z for UTC.
+var timeISO8601Layouts = []string{
+	`"2006-01-02T15:04:05-0700"`,
+	`"2006-01-02T15:04:05-07:00"`,
+	`"2006-01-02T15:04:05Z07:00"`,
+}
+
+// UnmarshalJSON decodes ISO 8601 time as time.Time, trying each of
+// timeISO8601Layouts in turn.
 func (t *timeISO8601) UnmarshalJSON(data []byte) error {
 	// Ignore null, like in the main JSON package.
 	if string(data) == "null" {
 		return nil
 	}
-	v, err := time.Parse("\"2006-01-02T15:04:05+0000\"", string(data))
-	if err != nil {
-		return err
+
+	var (
+		v   time.Time
+		err error
+	)
+	for _, layout := range timeISO8601Layouts {
+		if v, err = time.Parse(layout, string(data)); err == nil {
+			*t = timeISO8601(v)
+			return nil
+		}
 	}
-	*t = timeISO8601(v)
-	return nil
+	return err
 }
 
-// Download copies the media file and its thumbnail (video cover) if it's available.
+// Download copies the media file and its thumbnail (video cover) if it's
+// available. With WithConditionalRequests, a nil content and nil err means
+// the CDN reported the media unchanged (304), and the caller should keep
+// whatever's already stored locally instead of treating it as a failure.
 func (s *MediaService) Download(ctx context.Context, m *igshelf.Media) (content, thumbnail []byte, err error) {
+	content, err = s.downloadContent(ctx, m)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to download content: %w", err)
+	}
+	if content == nil {
+		return nil, nil, nil
+	}
+
+	if !s.thumbnails || m.ThumbnailLocation == "" {
+		return content, nil, nil
+	}
+
+	thumbContent, status, _, err := s.fetchURL(ctx, m.ThumbnailLocation, "")
+	if err != nil {
+		return content, nil, fmt.Errorf("failed to download thumbnail: %w", err)
+	}
+	if status != http.StatusOK {
+		return content, nil, fmt.Errorf("failed to download thumbnail: unexpected status %d", status)
+	}
+
+	return content, thumbContent, nil
+}
+
+// downloadContent fetches m's content, and when WithURLRefresh is on and the
+// CDN reports the signed URL has expired (403 or 410), it re-resolves
+// m.Location by ID and retries once. With WithConditionalRequests, a nil
+// content and nil error means the CDN reported the media unchanged (304).
+func (s *MediaService) downloadContent(ctx context.Context, m *igshelf.Media) ([]byte, error) {
+	content, status, etag, err := s.fetchURL(ctx, m.Location, s.ifNoneMatch(m.ID))
+	if err != nil {
+		return nil, err
+	}
+	if !s.urlRefresh || (status != http.StatusForbidden && status != http.StatusGone) {
+		if status == http.StatusNotModified {
+			return nil, nil
+		}
+		if status != http.StatusOK {
+			return nil, fmt.Errorf("unexpected status %d", status)
+		}
+		s.storeETag(m.ID, etag)
+		return s.checkNotEmpty(content)
+	}
+
+	fresh, err := s.resolveURL(ctx, m.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to refresh an expired CDN url: %w", err)
+	}
+	m.Location = fresh
+
+	content, status, etag, err = s.fetchURL(ctx, m.Location, s.ifNoneMatch(m.ID))
+	if err != nil {
+		return nil, err
+	}
+	if status == http.StatusNotModified {
+		return nil, nil
+	}
+	if status != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %d", status)
+	}
+	s.storeETag(m.ID, etag)
+	return s.checkNotEmpty(content)
+}
+
+// ifNoneMatch returns the stored ETag for id to send as If-None-Match, or
+// "" when conditional requests are disabled or none is stored yet.
+func (s *MediaService) ifNoneMatch(id string) string {
+	if !s.conditionalRequests {
+		return ""
+	}
+	s.etagMu.Lock()
+	defer s.etagMu.Unlock()
+	return s.etags[id]
+}
+
+// storeETag records etag for id, so a later Download can send it as
+// If-None-Match. It's a no-op when conditional requests are disabled or the
+// response didn't carry an ETag.
+func (s *MediaService) storeETag(id, etag string) {
+	if !s.conditionalRequests || etag == "" {
+		return
+	}
+	s.etagMu.Lock()
+	defer s.etagMu.Unlock()
+	s.etags[id] = etag
+}
+
+// checkNotEmpty rejects a zero-byte body with an error unless
+// WithAllowEmptyMedia is set, since an HTTP 200 with an empty body would
+// otherwise be written to disk as a permanently "downloaded" 0-byte file.
+func (s *MediaService) checkNotEmpty(content []byte) ([]byte, error) {
+	if !s.allowEmptyMedia && len(content) == 0 {
+		return nil, fmt.Errorf("empty response body")
+	}
+	return content, nil
+}
+
+// DownloadResumable fetches m's content into the file at path, resuming
+// from an existing partial file via a Range request instead of restarting
+// the download from scratch, which matters for large videos over flaky
+// connections. If the CDN doesn't honor the range and returns a fresh 200
+// instead of 206, path is truncated and downloaded again in full.
+func (s *MediaService) DownloadResumable(ctx context.Context, m *igshelf.Media, path string) error {
+	var existing int64
+	if fi, err := os.Stat(path); err == nil {
+		existing = fi.Size()
+	}
+
 	req, err := http.NewRequest(http.MethodGet, m.Location, nil)
 	if err != nil {
-		return nil, nil, err
+		return err
 	}
 	req = req.WithContext(ctx)
-	contResp, err := s.client.httpClient.Do(req)
+	if existing > 0 {
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-", existing))
+	}
+
+	resp, err := s.client.httpClient.Do(req)
 	if err != nil {
-		return nil, nil, fmt.Errorf("failed to download content: %w", err)
+		return fmt.Errorf("failed to fetch %s: %w", m.Location, err)
 	}
-	defer contResp.Body.Close()
-	if content, err = ioutil.ReadAll(contResp.Body); err != nil {
-		return nil, nil, fmt.Errorf("failed to read content: %w", err)
+	defer resp.Body.Close()
+
+	flag := os.O_CREATE | os.O_WRONLY
+	switch resp.StatusCode {
+	case http.StatusPartialContent:
+		flag |= os.O_APPEND
+	case http.StatusOK:
+		flag |= os.O_TRUNC
+	default:
+		return fmt.Errorf("unexpected status %d", resp.StatusCode)
 	}
 
-	if m.ThumbnailLocation == "" {
-		return content, nil, nil
+	f, err := os.OpenFile(path, flag, 0600)
+	if err != nil {
+		return err
 	}
+	defer f.Close()
+
+	if _, err = io.Copy(f, resp.Body); err != nil {
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	return nil
+}
 
-	req, err = http.NewRequest(http.MethodGet, m.ThumbnailLocation, nil)
+// fetchURL performs a GET against a CDN url and returns the response body,
+// HTTP status code, and ETag header, so the caller can act on a 403/410/304
+// without the error/nil-error ambiguity of a non-2xx http.Response.
+// ifNoneMatch, when non-empty, is sent as the If-None-Match header.
+func (s *MediaService) fetchURL(ctx context.Context, url, ifNoneMatch string) (content []byte, status int, etag string, err error) {
+	req, err := http.NewRequest(http.MethodGet, url, nil)
 	if err != nil {
-		return content, nil, err
+		return nil, 0, "", err
 	}
 	req = req.WithContext(ctx)
-	thumbResp, err := s.client.httpClient.Do(req)
+	if ifNoneMatch != "" {
+		req.Header.Set("If-None-Match", ifNoneMatch)
+	}
+
+	resp, err := s.client.httpClient.Do(req)
 	if err != nil {
-		return content, nil, fmt.Errorf("failed to download thumbnail: %w", err)
+		return nil, 0, "", err
 	}
-	defer thumbResp.Body.Close()
-	if thumbnail, err = ioutil.ReadAll(thumbResp.Body); err != nil {
-		return content, nil, fmt.Errorf("failed to read thumbnail: %w", err)
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, resp.StatusCode, "", err
 	}
+	return body, resp.StatusCode, resp.Header.Get("ETag"), nil
+}
 
-	return content, thumbnail, nil
+// mediaURLResp holds the current signed CDN url for a single media, as
+// returned when re-requesting its media_url field by ID.
+type mediaURLResp struct {
+	URL string `json:"media_url"`
+}
+
+// resolveURL re-requests a single media's media_url field by ID, since
+// Instagram signs and expires media_url after a period of time.
+func (s *MediaService) resolveURL(ctx context.Context, id string) (string, error) {
+	req, err := s.client.NewRequest(ctx, http.MethodGet, id, url.Values{"fields": []string{"media_url"}}, nil)
+	if err != nil {
+		return "", err
+	}
+
+	var v mediaURLResp
+	if _, err = s.client.Do(req, &v); err != nil {
+		return "", err
+	}
+	return v.URL, nil
 }
 
 // mediaListResp is a list of media as retrieved from the media API endpoint.
@@ -111,98 +499,302 @@ type mediaListResp struct {
 	Batch  []*media `json:"data"`
 	Paging struct {
 		Cursors struct {
-			After string `json:"after"`
+			After  string `json:"after"`
+			Before string `json:"before"`
 		} `json:"cursors"`
 	} `json:"paging"`
 }
 
+// fetchPage requests a single page for queryParams, and, when
+// WithAutoDropFields is set, retries once with an offending deprecated
+// field stripped from queryParams's "fields" if the API rejects the
+// request over it.
+func (s *MediaService) fetchPage(ctx context.Context, path string, queryParams url.Values) (*mediaListResp, error) {
+	req, err := s.client.NewRequest(ctx, http.MethodGet, path, queryParams, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	v := mediaListResp{}
+	_, err = s.client.Do(req, &v)
+	if err == nil {
+		return &v, nil
+	}
+	if !s.autoDropFields {
+		return nil, err
+	}
+	field, ok := deprecatedField(err)
+	if !ok {
+		return nil, err
+	}
+	s.logger.Log("msg", "dropping deprecated field and retrying the page", "field", field)
+	queryParams.Set("fields", dropField(queryParams.Get("fields"), field))
+
+	req, err = s.client.NewRequest(ctx, http.MethodGet, path, queryParams, nil)
+	if err != nil {
+		return nil, err
+	}
+	v = mediaListResp{}
+	if _, err = s.client.Do(req, &v); err != nil {
+		return nil, err
+	}
+	return &v, nil
+}
+
 // List returns an iterator to access the user's timeline.
 // It relies on API pagination to fetch batches of media.
 // You would have to start over if an error occurs during pagination (server timeout).
-func (s *MediaService) List(ctx context.Context) igshelf.MediaIter {
+// When since is non-zero, only media published after it is requested, so a
+// recurring backup can fetch just what's new.
+func (s *MediaService) List(ctx context.Context, since time.Time) igshelf.MediaIter {
 	path := fmt.Sprintf("%s/media", s.userID)
 	queryParams := url.Values{}
-	queryParams.Set("fields", "id,caption,media_type,media_url,permalink,thumbnail_url,timestamp,children{media_type,media_url,thumbnail_url}")
+	queryParams.Set("fields", s.fields)
+	if !since.IsZero() {
+		queryParams.Set("since", strconv.FormatInt(since.Unix(), 10))
+	}
 
-	return &MediaIter{fetch: func() ([]*igshelf.Media, error) {
+	it := MediaIter{ctx: ctx, prefetch: s.prefetch}
+	it.fetch = func() ([]*igshelf.Media, error) {
 		// Stop iterator when the next pagination token is empty.
 		if _, ok := queryParams["after"]; ok && queryParams.Get("after") == "" {
 			return nil, nil
 		}
 
-		req, err := s.client.NewRequest(ctx, http.MethodGet, path, queryParams, nil)
+		v, err := s.fetchPage(ctx, path, queryParams)
 		if err != nil {
 			return nil, err
 		}
 
-		v := mediaListResp{}
-		_, err = s.client.Do(req, &v)
+		queryParams.Set("after", v.Paging.Cursors.After)
+		it.page++
+		it.apiCursor = v.Paging.Cursors.After
+		it.apiCursorBefore = v.Paging.Cursors.Before
+
+		mm := make([]*igshelf.Media, len(v.Batch))
+		for i, raw := range v.Batch {
+			mm[i] = buildMedia(raw, s.logger, s.filenamePrefixLayout, s.thumbnails)
+		}
+		s.enrichInsights(ctx, mm)
+
+		return mm, nil
+	}
+	return &it
+}
+
+// ListBefore returns an iterator that walks the timeline backward from
+// before, a cursor previously captured with MediaIter.BeforeCursor, toward
+// the newest media. It's meant to catch up on posts published since a
+// prior List run stopped, without re-walking the whole timeline: unlike
+// List's since parameter, which only filters what's already been fetched,
+// before tells the API itself where to resume.
+func (s *MediaService) ListBefore(ctx context.Context, before string) igshelf.MediaIter {
+	path := fmt.Sprintf("%s/media", s.userID)
+	queryParams := url.Values{}
+	queryParams.Set("fields", s.fields)
+	queryParams.Set("before", before)
+
+	it := MediaIter{ctx: ctx, prefetch: s.prefetch}
+	it.fetch = func() ([]*igshelf.Media, error) {
+		// Stop iterator when the next pagination token is empty.
+		if queryParams.Get("before") == "" {
+			return nil, nil
+		}
+
+		v, err := s.fetchPage(ctx, path, queryParams)
 		if err != nil {
 			return nil, err
 		}
 
-		queryParams.Set("after", v.Paging.Cursors.After)
+		queryParams.Set("before", v.Paging.Cursors.Before)
+		it.page++
+		it.apiCursor = v.Paging.Cursors.After
+		it.apiCursorBefore = v.Paging.Cursors.Before
 
 		mm := make([]*igshelf.Media, len(v.Batch))
 		for i, raw := range v.Batch {
-			mm[i] = &igshelf.Media{
-				ID:                raw.ID,
-				Type:              raw.Type,
-				Caption:           raw.Caption,
-				Location:          raw.URL,
-				ThumbnailLocation: raw.ThumbnailURL,
-				Permalink:         raw.Permalink,
-				TakenAt:           time.Time(raw.TakenAt),
+			mm[i] = buildMedia(raw, s.logger, s.filenamePrefixLayout, s.thumbnails)
+		}
+		s.enrichInsights(ctx, mm)
+
+		return mm, nil
+	}
+	return &it
+}
+
+// buildMedia converts a single API node (and its album children, if any)
+// into an igshelf.Media, assigning local filenames used when storing
+// photos/videos on disk. It's shared by List and Get so both build media
+// the same way. logger is notified of any child with a media_type other
+// than IMAGE, ALBUM, or VIDEO, since that shouldn't happen per the API's
+// documented types but would otherwise silently drop the child.
+// prefixLayout is a time.Format layout prepended to filenames (empty
+// disables the prefix), see WithFilenamePrefix. thumbnails controls
+// whether a video gets a ThumbnailFilename assigned, see WithThumbnails.
+func buildMedia(raw *media, logger Logger, prefixLayout string, thumbnails bool) *igshelf.Media {
+	m := &igshelf.Media{
+		ID:                raw.ID,
+		Type:              raw.Type,
+		Caption:           igshelf.SanitizeCaption(raw.Caption),
+		Location:          raw.URL,
+		ThumbnailLocation: raw.ThumbnailURL,
+		Permalink:         raw.Permalink,
+		TakenAt:           time.Time(raw.TakenAt),
+		// A copyright-flagged media omits media_url entirely, so there's
+		// nothing to download. Get returns a flagged album child this way
+		// too, since fetching it directly by ID surfaces it as the
+		// top-level node rather than nested under its album's Children.
+		Unavailable: raw.URL == "",
+	}
+	// Assign file names which should be used when storing photos/videos locally.
+	// Year/month prefix helps to explore files. timeISO8601 keeps the
+	// original UTC offset rather than normalizing to UTC, so Format below
+	// reflects the poster's local date instead of possibly the following
+	// (or preceding) UTC day.
+	prefix := ""
+	if prefixLayout != "" {
+		prefix = m.TakenAt.Format(prefixLayout)
+	}
+	fname := prefix + raw.ID
+	switch raw.Type {
+	case igshelf.MediaTypeImage, igshelf.MediaTypeAlbum:
+		m.Filename = fname + ".jpg"
+	case igshelf.MediaTypeVideo:
+		m.Filename = fname + ".mp4"
+		if thumbnails {
+			m.ThumbnailFilename = fname + "_cover.jpg"
+		}
+	}
+
+	if len(raw.Children.Data) > 0 {
+		m.Children = make([]*igshelf.Media, len(raw.Children.Data))
+		for j, c := range raw.Children.Data {
+			m.Children[j] = &igshelf.Media{
+				ID:   c.ID,
+				Type: c.Type,
+				// Instagram doesn't return a caption/permalink for album
+				// children, so they inherit the parent's for display.
+				Caption:           m.Caption,
+				Permalink:         m.Permalink,
+				Location:          c.URL,
+				ThumbnailLocation: c.ThumbnailURL,
+				// A copyright-flagged child omits media_url entirely,
+				// so there's nothing to download.
+				Unavailable: c.URL == "",
 			}
-			// Assign file names which should be used when storing photos/videos locally.
-			// Year/month prefix helps to explore files.
-			fname := mm[i].TakenAt.Format("200601_") + raw.ID
-			switch raw.Type {
+			fname = prefix + c.ID
+			switch c.Type {
 			case igshelf.MediaTypeImage, igshelf.MediaTypeAlbum:
-				mm[i].Filename = fname + ".jpg"
+				m.Children[j].Filename = fname + ".jpg"
 			case igshelf.MediaTypeVideo:
-				mm[i].Filename = fname + ".mp4"
-				mm[i].ThumbnailFilename = fname + "_cover.jpg"
-			}
-
-			if len(raw.Children.Data) > 0 {
-				mm[i].Children = make([]*igshelf.Media, len(raw.Children.Data))
-				for j, c := range raw.Children.Data {
-					mm[i].Children[j] = &igshelf.Media{
-						ID:                c.ID,
-						Type:              c.Type,
-						Location:          c.URL,
-						ThumbnailLocation: c.ThumbnailURL,
-					}
-					fname = mm[i].TakenAt.Format("200601_") + c.ID
-					switch c.Type {
-					case igshelf.MediaTypeImage, igshelf.MediaTypeAlbum:
-						mm[i].Children[j].Filename = fname + ".jpg"
-					case igshelf.MediaTypeVideo:
-						mm[i].Children[j].Filename = fname + ".mp4"
-						mm[i].Children[j].ThumbnailFilename = fname + "_cover.jpg"
-					}
+				m.Children[j].Filename = fname + ".mp4"
+				if thumbnails {
+					m.Children[j].ThumbnailFilename = fname + "_cover.jpg"
 				}
+			default:
+				logger.Log("msg", "unexpected album child media_type", "id", c.ID, "media_type", c.Type)
+				m.Children[j].Filename = fname + extensionFromURL(c.URL)
 			}
 		}
+	}
 
-		return mm, nil
-	}}
+	return m
+}
+
+// extensionFromURL returns the file extension (with leading dot) of
+// rawurl's path, or ".bin" if it has none or rawurl doesn't parse, so a
+// child of an unrecognized media_type still gets a usable filename instead
+// of an empty one.
+func extensionFromURL(rawurl string) string {
+	u, err := url.Parse(rawurl)
+	if err != nil {
+		return ".bin"
+	}
+	if ext := path.Ext(u.Path); ext != "" {
+		return ext
+	}
+	return ".bin"
+}
+
+// Get fetches a single node's media by ID, requesting the same fields List
+// does. It's a building block for re-resolving an expired URL or
+// re-downloading one item without pulling the whole timeline.
+func (s *MediaService) Get(ctx context.Context, id string) (*igshelf.Media, error) {
+	queryParams := url.Values{}
+	queryParams.Set("fields", s.fields)
+
+	req, err := s.client.NewRequest(ctx, http.MethodGet, id, queryParams, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var raw media
+	if _, err = s.client.Do(req, &raw); err != nil {
+		return nil, err
+	}
+	m := buildMedia(&raw, s.logger, s.filenamePrefixLayout, s.thumbnails)
+	s.enrichInsights(ctx, []*igshelf.Media{m})
+	return m, nil
+}
+
+// fetchResult is a result of a (possibly backgrounded) page fetch.
+type fetchResult struct {
+	batch []*igshelf.Media
+	err   error
 }
 
 // MediaIter is an iterator for collection of media.
 type MediaIter struct {
 	fetch  func() ([]*igshelf.Media, error)
 	err    error
+	ctx    context.Context
 	cursor int
 	batch  []*igshelf.Media
+
+	// prefetch makes Next fetch the next page in the background
+	// while the caller processes the current batch.
+	prefetch bool
+	// pending is a page fetch that's already running in the background.
+	pending chan fetchResult
+
+	// page is the number of pages fetched so far, see Page.
+	page int
+	// apiCursor is the pagination cursor of the last fetched page, see Cursor.
+	apiCursor string
+	// apiCursorBefore is the "before" pagination cursor of the last fetched
+	// page, see BeforeCursor.
+	apiCursorBefore string
 }
 
+// Page returns the number of pages fetched so far, incremented each time
+// fetch runs, so an operator can see progress during a long pull.
+func (mi *MediaIter) Page() int { return mi.page }
+
+// Cursor returns the "after" pagination cursor of the last fetched page,
+// i.e. the value a subsequent List call would resume from.
+func (mi *MediaIter) Cursor() string { return mi.apiCursor }
+
+// BeforeCursor returns the "before" pagination cursor of the last fetched
+// page, i.e. the value a subsequent ListBefore call would resume from to
+// walk further toward the newest media.
+func (mi *MediaIter) BeforeCursor() string { return mi.apiCursorBefore }
+
 // Media returns the media which the iterator is currently pointing to.
 func (mi *MediaIter) Media() *igshelf.Media {
 	return mi.batch[mi.cursor]
 }
 
+// fetchAsync runs fetch in a goroutine and returns a channel that
+// receives its result once it's ready.
+func (mi *MediaIter) fetchAsync() chan fetchResult {
+	ch := make(chan fetchResult, 1)
+	go func() {
+		batch, err := mi.fetch()
+		ch <- fetchResult{batch: batch, err: err}
+	}()
+	return ch
+}
+
 // Next prepares the next media for reading with the Media method.
 // It returns true on success, or false if there is no next result or an error
 // happened while preparing it. Err should be consulted to distinguish between the two cases.
@@ -211,9 +803,29 @@ func (mi *MediaIter) Next() bool {
 	if mi.err != nil {
 		return false
 	}
+	if mi.ctx != nil && mi.ctx.Err() != nil {
+		mi.err = mi.ctx.Err()
+		return false
+	}
 
 	if mi.cursor >= len(mi.batch)-1 {
 		mi.cursor = 0
+
+		if mi.prefetch {
+			if mi.pending == nil {
+				mi.pending = mi.fetchAsync()
+			}
+			res := <-mi.pending
+			mi.batch, mi.err = res.batch, res.err
+			if mi.err != nil || len(mi.batch) == 0 {
+				mi.pending = nil
+				return false
+			}
+			// Start fetching the following page while the caller works through this batch.
+			mi.pending = mi.fetchAsync()
+			return true
+		}
+
 		mi.batch, mi.err = mi.fetch()
 		if mi.err != nil || len(mi.batch) == 0 {
 			return false
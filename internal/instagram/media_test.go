@@ -2,10 +2,15 @@ package instagram
 
 import (
 	"context"
+	"encoding/json"
+	"fmt"
 	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
+	"os"
 	"path/filepath"
+	"strconv"
+	"strings"
 	"testing"
 	"time"
 
@@ -14,6 +19,138 @@ import (
 	"github.com/marselester/igshelf"
 )
 
+func TestTimeISO8601UnmarshalJSON(t *testing.T) {
+	want := time.Date(2019, time.November, 10, 12, 20, 51, 0, time.UTC)
+
+	tt := map[string]string{
+		"no colon offset": `"2019-11-10T12:20:51+0000"`,
+		"colon offset":    `"2019-11-10T12:20:51+00:00"`,
+		"Z suffix":        `"2019-11-10T12:20:51Z"`,
+	}
+
+	for name, data := range tt {
+		t.Run(name, func(t *testing.T) {
+			var got timeISO8601
+			if err := got.UnmarshalJSON([]byte(data)); err != nil {
+				t.Fatal(err)
+			}
+			if !time.Time(got).Equal(want) {
+				t.Errorf("got %v, want %v", time.Time(got), want)
+			}
+		})
+	}
+}
+
+// TestBuildMediaFilenameUsesLocalDate checks that a media's filename
+// prefix is derived from TakenAt's original offset rather than UTC, so a
+// photo taken late at night in the poster's timezone doesn't get filed
+// under the following UTC day.
+func TestBuildMediaFilenameUsesLocalDate(t *testing.T) {
+	var raw media
+	// 00:30 on Nov 1st at +05:00 is 19:30 on Oct 31st in UTC, so a filename
+	// derived from UTC would land in the wrong month.
+	if err := json.Unmarshal([]byte(`{"id": "1", "media_type": "IMAGE", "timestamp": "2020-11-01T00:30:00+0500"}`), &raw); err != nil {
+		t.Fatal(err)
+	}
+
+	m := buildMedia(&raw, nopLogger{}, defaultFilenamePrefixLayout, true)
+
+	if got, want := m.Filename, "202011_1.jpg"; got != want {
+		t.Errorf("got filename %q, want %q", got, want)
+	}
+	if got, want := m.TakenAt.Format("-0700"), "+0500"; got != want {
+		t.Errorf("got offset %q, want %q", got, want)
+	}
+}
+
+// TestBuildMediaFilenamePrefix checks that buildMedia uses a custom
+// time.Format layout for the filename prefix, and that an empty layout
+// disables the prefix entirely.
+func TestBuildMediaFilenamePrefix(t *testing.T) {
+	var raw media
+	if err := json.Unmarshal([]byte(`{"id": "1", "media_type": "IMAGE", "timestamp": "2020-11-01T00:30:00+0500"}`), &raw); err != nil {
+		t.Fatal(err)
+	}
+
+	cases := map[string]struct {
+		layout string
+		want   string
+	}{
+		"custom layout": {layout: "2006-01-02_", want: "2020-11-01_1.jpg"},
+		"no prefix":     {layout: "", want: "1.jpg"},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			m := buildMedia(&raw, nopLogger{}, tc.layout, true)
+			if got := m.Filename; got != tc.want {
+				t.Errorf("got filename %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+// TestBuildMediaThumbnailsDisabled checks that buildMedia leaves
+// ThumbnailFilename unset for a video (and its album children) when
+// thumbnails is false, so the downloader has no path to write one to.
+func TestBuildMediaThumbnailsDisabled(t *testing.T) {
+	var raw media
+	if err := json.Unmarshal([]byte(`{
+		"id": "1",
+		"media_type": "VIDEO",
+		"media_url": "https://video.cdninstagram.com/1.mp4",
+		"thumbnail_url": "https://scontent.cdninstagram.com/1.jpg",
+		"timestamp": "2020-10-07T15:55:33+0000",
+		"children": {"data": [
+			{"id": "2", "media_type": "VIDEO", "media_url": "https://video.cdninstagram.com/2.mp4", "thumbnail_url": "https://scontent.cdninstagram.com/2.jpg"}
+		]}
+	}`), &raw); err != nil {
+		t.Fatal(err)
+	}
+
+	m := buildMedia(&raw, nopLogger{}, defaultFilenamePrefixLayout, false)
+
+	if m.ThumbnailFilename != "" {
+		t.Errorf("got ThumbnailFilename %q, want empty", m.ThumbnailFilename)
+	}
+	if got := m.Children[0].ThumbnailFilename; got != "" {
+		t.Errorf("got child ThumbnailFilename %q, want empty", got)
+	}
+}
+
+// TestBuildMediaUnexpectedChildType checks that an album child with a
+// media_type other than IMAGE, ALBUM, or VIDEO still gets a filename
+// (derived from its URL's extension) instead of being silently dropped,
+// and that the unexpected type is logged.
+func TestBuildMediaUnexpectedChildType(t *testing.T) {
+	var raw media
+	if err := json.Unmarshal([]byte(`{
+		"id": "1",
+		"media_type": "CAROUSEL_ALBUM",
+		"timestamp": "2020-10-07T15:55:33+0000",
+		"children": {"data": [
+			{"id": "2", "media_type": "TEXT", "media_url": "https://cdninstagram.com/v/t51/2.webp"}
+		]}
+	}`), &raw); err != nil {
+		t.Fatal(err)
+	}
+
+	var logged []interface{}
+	logger := LoggerFunc(func(keyvals ...interface{}) error {
+		logged = keyvals
+		return nil
+	})
+
+	m := buildMedia(&raw, logger, defaultFilenamePrefixLayout, true)
+
+	if got, want := m.Children[0].Filename, "202010_2.webp"; got != want {
+		t.Errorf("got filename %q, want %q", got, want)
+	}
+	if logged == nil {
+		t.Error("expected the unexpected media_type to be logged")
+	}
+}
+
 func TestMediaList_errors(t *testing.T) {
 	tt := map[string]struct {
 		body       string
@@ -62,7 +199,7 @@ func TestMediaList_errors(t *testing.T) {
 			client := NewClient("IGQVJ...", WithBaseURL(srv.URL))
 			s := NewService(client, "me")
 
-			iter := s.List(context.Background())
+			iter := s.List(context.Background(), time.Time{})
 			iter.Next()
 			if diff := cmp.Diff(tc.want, iter.Err().Error()); diff != "" {
 				t.Errorf(diff)
@@ -71,6 +208,102 @@ func TestMediaList_errors(t *testing.T) {
 	}
 }
 
+// TestMediaListSince checks that a non-zero since is sent as the API's
+// since query param, so a recurring backup only fetches new media.
+func TestMediaListSince(t *testing.T) {
+	since := time.Date(2020, time.October, 7, 15, 55, 33, 0, time.UTC)
+	var got string
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r.URL.Query().Get("since")
+		fmt.Fprint(w, `{"data": []}`)
+	}))
+	defer srv.Close()
+
+	client := NewClient("IGQVJ...", WithBaseURL(srv.URL))
+	s := NewService(client, "me")
+
+	iter := s.List(context.Background(), since)
+	iter.Next()
+	if iter.Err() != nil {
+		t.Fatal(iter.Err())
+	}
+
+	if want := strconv.FormatInt(since.Unix(), 10); got != want {
+		t.Errorf("got since %q, want %q", got, want)
+	}
+}
+
+// TestMediaListWithFields checks that WithFields overrides the default
+// fields query param, and that id/media_type are appended even though the
+// test omits them.
+func TestMediaListWithFields(t *testing.T) {
+	var got string
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r.URL.Query().Get("fields")
+		fmt.Fprint(w, `{"data": []}`)
+	}))
+	defer srv.Close()
+
+	client := NewClient("IGQVJ...", WithBaseURL(srv.URL))
+	s := NewService(client, "me", WithFields("caption", "media_product_type"))
+
+	iter := s.List(context.Background(), time.Time{})
+	iter.Next()
+	if iter.Err() != nil {
+		t.Fatal(iter.Err())
+	}
+
+	want := "caption,media_product_type,id,media_type"
+	if got != want {
+		t.Errorf("got fields %q, want %q", got, want)
+	}
+}
+
+// TestMediaListAutoDropFields checks that with WithAutoDropFields, a page
+// rejected because a field has been deprecated is retried once without that
+// field, and the retry's data still comes through.
+func TestMediaListAutoDropFields(t *testing.T) {
+	var requests int
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if requests == 1 {
+			if got, want := r.URL.Query().Get("fields"), "thumbnail_url,id,media_type"; got != want {
+				t.Errorf("got fields %q, want %q", got, want)
+			}
+			http.Error(w, `{"error": {
+	"message": "Tried accessing nonexisting field (thumbnail_url) on node type (Media)",
+	"type": "IGApiException",
+	"code": 100,
+	"fbtrace_id": "AT_sdfg081234CQ456-YY"
+}}`, http.StatusBadRequest)
+			return
+		}
+
+		if got, want := r.URL.Query().Get("fields"), "id,media_type"; got != want {
+			t.Errorf("got fields %q, want %q", got, want)
+		}
+		fmt.Fprint(w, `{"data": [{"id": "1", "media_type": "IMAGE"}]}`)
+	}))
+	defer srv.Close()
+
+	client := NewClient("IGQVJ...", WithBaseURL(srv.URL))
+	s := NewService(client, "me", WithFields("thumbnail_url"), WithAutoDropFields(true))
+
+	iter := s.List(context.Background(), time.Time{})
+	if !iter.Next() {
+		t.Fatal(iter.Err())
+	}
+	if got, want := iter.Media().ID, "1"; got != want {
+		t.Errorf("got media ID %q, want %q", got, want)
+	}
+	if requests != 2 {
+		t.Errorf("got %d requests, want 2", requests)
+	}
+}
+
 func TestMediaList(t *testing.T) {
 	filename := filepath.Join("testdata", "media_list.json")
 	content, err := ioutil.ReadFile(filename)
@@ -88,7 +321,7 @@ func TestMediaList(t *testing.T) {
 	client := NewClient("IGQVJ...", WithBaseURL(srv.URL))
 	s := NewService(client, "me")
 
-	iter := s.List(context.Background())
+	iter := s.List(context.Background(), time.Time{})
 	var got *igshelf.Media
 	for iter.Next() {
 		got = iter.Media()
@@ -109,6 +342,8 @@ func TestMediaList(t *testing.T) {
 			{
 				ID:                "17850885734317674",
 				Type:              "VIDEO",
+				Caption:           "Still jumping",
+				Permalink:         "https://www.instagram.com/p/CGDFCNqHJv1/",
 				Location:          "https://video.cdninstagram.com/v/t50.2886-16/1...",
 				ThumbnailLocation: "https://scontent.cdninstagram.com/v/t51.29350-15/1...",
 				Filename:          "202010_17850885734317674.mp4",
@@ -117,6 +352,8 @@ func TestMediaList(t *testing.T) {
 			{
 				ID:                "17863188140095492",
 				Type:              "VIDEO",
+				Caption:           "Still jumping",
+				Permalink:         "https://www.instagram.com/p/CGDFCNqHJv1/",
 				Location:          "https://video.cdninstagram.com/v/t50.2886-16/2...",
 				ThumbnailLocation: "https://scontent.cdninstagram.com/v/t51.29350-15/2...",
 				Filename:          "202010_17863188140095492.mp4",
@@ -125,6 +362,8 @@ func TestMediaList(t *testing.T) {
 			{
 				ID:                "17871183211965376",
 				Type:              "VIDEO",
+				Caption:           "Still jumping",
+				Permalink:         "https://www.instagram.com/p/CGDFCNqHJv1/",
 				Location:          "https://video.cdninstagram.com/v/t50.2886-16/3...",
 				ThumbnailLocation: "https://scontent.cdninstagram.com/v/t51.29350-15/3...",
 				Filename:          "202010_17871183211965376.mp4",
@@ -136,4 +375,635 @@ func TestMediaList(t *testing.T) {
 	if diff := cmp.Diff(want, got); diff != "" {
 		t.Errorf(diff)
 	}
+
+	// media_list.json's fixture page carries a "before" cursor, which List
+	// should capture alongside "after" so a caller can later resume toward
+	// the newest media with ListBefore.
+	if got, want := iter.(*MediaIter).BeforeCursor(), "QVF...1B"; got != want {
+		t.Errorf("got before cursor %q, want %q", got, want)
+	}
+}
+
+// TestMediaListBefore checks that ListBefore walks pages backward using
+// the "before" cursor, stopping once a page reports an empty one.
+func TestMediaListBefore(t *testing.T) {
+	const pages = 3
+	var served int
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		served++
+		before := ""
+		if served < pages {
+			before = "cursor" + string(rune('0'+served))
+		}
+		fmt.Fprintf(w, `{"data": [{"id": "%d"}], "paging": {"cursors": {"before": "%s"}}}`, served, before)
+	}))
+	defer srv.Close()
+
+	client := NewClient("IGQVJ...", WithBaseURL(srv.URL))
+	s := NewService(client, "me")
+
+	iter := s.ListBefore(context.Background(), "start-cursor").(*MediaIter)
+
+	var gotPages []int
+	for iter.Next() {
+		gotPages = append(gotPages, iter.Page())
+	}
+	if iter.Err() != nil {
+		t.Fatal(iter.Err())
+	}
+
+	want := []int{1, 2, 3}
+	if diff := cmp.Diff(want, gotPages); diff != "" {
+		t.Errorf(diff)
+	}
+	if want := ""; iter.BeforeCursor() != want {
+		t.Errorf("got before cursor %q, want %q", iter.BeforeCursor(), want)
+	}
+}
+
+// TestMediaListUnavailableChild checks that an album child missing
+// media_url (e.g. flagged for copyright) is flagged Unavailable instead
+// of ending up with an empty Location a downloader would fail to GET.
+func TestMediaListUnavailableChild(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"data": [{
+			"id": "17850307850323541",
+			"media_type": "CAROUSEL_ALBUM",
+			"media_url": "https://scontent.cdninstagram.com/v/t51.29350-15/...",
+			"timestamp": "2020-10-07T15:55:33+0000",
+			"children": {"data": [
+				{"id": "17850885734317674", "media_type": "IMAGE"}
+			]}
+		}]}`)
+	}))
+	defer srv.Close()
+
+	client := NewClient("IGQVJ...", WithBaseURL(srv.URL))
+	s := NewService(client, "me")
+
+	iter := s.List(context.Background(), time.Time{})
+	var got *igshelf.Media
+	for iter.Next() {
+		got = iter.Media()
+	}
+	if iter.Err() != nil {
+		t.Fatal(iter.Err())
+	}
+
+	if len(got.Children) != 1 {
+		t.Fatalf("got %d children, want 1", len(got.Children))
+	}
+	if !got.Children[0].Unavailable {
+		t.Errorf("got Unavailable = false, want true for a child missing media_url")
+	}
+}
+
+// TestMediaListPage checks that Page advances as List paginates through
+// several fixture pages, so an operator can log progress during a long pull.
+func TestMediaListPage(t *testing.T) {
+	const pages = 3
+	var served int
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		served++
+		after := ""
+		if served < pages {
+			after = "cursor" + string(rune('0'+served))
+		}
+		fmt.Fprintf(w, `{"data": [{"id": "%d"}], "paging": {"cursors": {"after": "%s"}}}`, served, after)
+	}))
+	defer srv.Close()
+
+	client := NewClient("IGQVJ...", WithBaseURL(srv.URL))
+	s := NewService(client, "me")
+
+	iter := s.List(context.Background(), time.Time{}).(*MediaIter)
+
+	var gotPages []int
+	for iter.Next() {
+		gotPages = append(gotPages, iter.Page())
+	}
+	if iter.Err() != nil {
+		t.Fatal(iter.Err())
+	}
+
+	want := []int{1, 2, 3}
+	if diff := cmp.Diff(want, gotPages); diff != "" {
+		t.Errorf(diff)
+	}
+	// The final page's response carries an empty "after" cursor, which is
+	// what tells the iterator to stop.
+	if want := ""; iter.Cursor() != want {
+		t.Errorf("got cursor %q, want %q", iter.Cursor(), want)
+	}
+}
+
+// TestMediaListPageSizes checks that MediaIter.Next yields every media
+// exactly once, in order, regardless of how items are split across pages,
+// including single-item pages, which used to trip up the
+// cursor >= len(batch)-1 bookkeeping between pages.
+func TestMediaListPageSizes(t *testing.T) {
+	cases := map[string]struct {
+		pageSizes []int
+	}{
+		"single-item pages":       {pageSizes: []int{1, 1, 1}},
+		"two-item pages":          {pageSizes: []int{2, 2}},
+		"mixed page sizes":        {pageSizes: []int{1, 2, 1}},
+		"one page, one item":      {pageSizes: []int{1}},
+		"one page, several items": {pageSizes: []int{3}},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			var (
+				served int
+				nextID int
+			)
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				size := tc.pageSizes[served]
+				served++
+
+				var items []string
+				for i := 0; i < size; i++ {
+					nextID++
+					items = append(items, fmt.Sprintf(`{"id": "%d"}`, nextID))
+				}
+				after := ""
+				if served < len(tc.pageSizes) {
+					after = fmt.Sprintf("cursor%d", served)
+				}
+				fmt.Fprintf(w, `{"data": [%s], "paging": {"cursors": {"after": "%s"}}}`, strings.Join(items, ","), after)
+			}))
+			defer srv.Close()
+
+			client := NewClient("IGQVJ...", WithBaseURL(srv.URL))
+			s := NewService(client, "me")
+
+			iter := s.List(context.Background(), time.Time{})
+			var got []string
+			for iter.Next() {
+				got = append(got, iter.Media().ID)
+			}
+			if iter.Err() != nil {
+				t.Fatal(iter.Err())
+			}
+
+			var want []string
+			for i := 1; i <= nextID; i++ {
+				want = append(want, fmt.Sprintf("%d", i))
+			}
+			if diff := cmp.Diff(want, got); diff != "" {
+				t.Errorf(diff)
+			}
+		})
+	}
+}
+
+func TestMediaServiceGet(t *testing.T) {
+	filename := filepath.Join("testdata", "media_node.json")
+	content, err := ioutil.ReadFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, err := w.Write(content); err != nil {
+			t.Fatal(err)
+		}
+	}))
+	defer srv.Close()
+
+	client := NewClient("IGQVJ...", WithBaseURL(srv.URL))
+	s := NewService(client, "me")
+
+	got, err := s.Get(context.Background(), "17850885734317674")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := &igshelf.Media{
+		ID:                "17850885734317674",
+		Caption:           "Still jumping",
+		Type:              "VIDEO",
+		Location:          "https://video.cdninstagram.com/v/t50.2886-16/1...",
+		ThumbnailLocation: "https://scontent.cdninstagram.com/v/t51.29350-15/1...",
+		Permalink:         "https://www.instagram.com/p/CGDFCNqHJv1/",
+		Filename:          "202010_17850885734317674.mp4",
+		ThumbnailFilename: "202010_17850885734317674_cover.jpg",
+		TakenAt:           time.Date(2020, time.October, 7, 15, 55, 33, 0, time.UTC),
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf(diff)
+	}
+}
+
+// TestMediaServiceGetUnavailable checks that Get marks a media Unavailable
+// when Instagram omits media_url, e.g. a copyright-flagged album child
+// fetched directly by ID rather than nested under its album's Children.
+func TestMediaServiceGetUnavailable(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"id": "17850885734317674", "media_type": "IMAGE", "timestamp": "2020-10-07T15:55:33+0000"}`)
+	}))
+	defer srv.Close()
+
+	client := NewClient("IGQVJ...", WithBaseURL(srv.URL))
+	s := NewService(client, "me")
+
+	got, err := s.Get(context.Background(), "17850885734317674")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !got.Unavailable {
+		t.Error("expected media with no media_url to be Unavailable")
+	}
+}
+
+// TestMediaServiceGetWithInsights checks that WithInsights populates
+// Likes/Comments from the media's insights edge on top of the fields Get
+// already returns.
+func TestMediaServiceGetWithInsights(t *testing.T) {
+	nodeContent, err := ioutil.ReadFile(filepath.Join("testdata", "media_node.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, "/insights") {
+			fmt.Fprint(w, `{"data": [
+				{"name": "likes", "period": "lifetime", "values": [{"value": 42}]},
+				{"name": "comments", "period": "lifetime", "values": [{"value": 7}]}
+			]}`)
+			return
+		}
+		w.Write(nodeContent)
+	}))
+	defer srv.Close()
+
+	client := NewClient("IGQVJ...", WithBaseURL(srv.URL))
+	s := NewService(client, "me", WithInsights(true))
+
+	got, err := s.Get(context.Background(), "17850885734317674")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got, want := got.Likes, 42; got != want {
+		t.Errorf("got %d likes, want %d", got, want)
+	}
+	if got, want := got.Comments, 7; got != want {
+		t.Errorf("got %d comments, want %d", got, want)
+	}
+}
+
+// TestMediaServiceGetWithInsightsUnsupportedAccount checks that Get still
+// succeeds, with Likes/Comments left at zero, when the insights request
+// fails, e.g. because the account isn't a business/creator account.
+func TestMediaServiceGetWithInsightsUnsupportedAccount(t *testing.T) {
+	nodeContent, err := ioutil.ReadFile(filepath.Join("testdata", "media_node.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, "/insights") {
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprint(w, `{"error": {"message": "Media insights aren't supported for this account", "type": "IGApiException", "code": 10}}`)
+			return
+		}
+		w.Write(nodeContent)
+	}))
+	defer srv.Close()
+
+	client := NewClient("IGQVJ...", WithBaseURL(srv.URL))
+	s := NewService(client, "me", WithInsights(true))
+
+	got, err := s.Get(context.Background(), "17850885734317674")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Likes != 0 || got.Comments != 0 {
+		t.Errorf("got Likes=%d Comments=%d, want both 0", got.Likes, got.Comments)
+	}
+}
+
+// TestMediaListPrefetch checks that WithPrefetch overlaps a page fetch
+// with the caller processing the previous page, instead of paying for
+// every page's latency serially.
+func TestMediaListPrefetch(t *testing.T) {
+	const (
+		pages       = 3
+		pageLatency = 50 * time.Millisecond
+	)
+	var served int
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(pageLatency)
+
+		served++
+		after := ""
+		if served < pages {
+			after = "cursor" + string(rune('0'+served))
+		}
+		fmt.Fprintf(w, `{"data": [{"id": "%d"}], "paging": {"cursors": {"after": "%s"}}}`, served, after)
+	}))
+	defer srv.Close()
+
+	client := NewClient("IGQVJ...", WithBaseURL(srv.URL))
+	s := NewService(client, "me", WithPrefetch(true))
+
+	start := time.Now()
+	iter := s.List(context.Background(), time.Time{})
+	var got int
+	for iter.Next() {
+		got++
+		// Simulate the caller doing work with the current page
+		// while the next one is prefetched in the background.
+		time.Sleep(pageLatency)
+	}
+	elapsed := time.Since(start)
+	if iter.Err() != nil {
+		t.Fatal(iter.Err())
+	}
+	if got != pages {
+		t.Fatalf("got %d media, want %d", got, pages)
+	}
+
+	if serial := pages * 2 * pageLatency; elapsed >= serial {
+		t.Errorf("elapsed %s should be less than the serial sum %s", elapsed, serial)
+	}
+}
+
+// TestDownloadURLRefresh checks that a CDN url which 403s because it has
+// expired gets re-resolved through the API and the download retried once.
+func TestDownloadURLRefresh(t *testing.T) {
+	var cdnRequests int
+	cdn := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		cdnRequests++
+		if r.URL.Path == "/stale" {
+			http.Error(w, "expired", http.StatusForbidden)
+			return
+		}
+		fmt.Fprint(w, "the content")
+	}))
+	defer cdn.Close()
+
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `{"media_url": "%s/fresh"}`, cdn.URL)
+	}))
+	defer api.Close()
+
+	client := NewClient("IGQVJ...", WithBaseURL(api.URL))
+	s := NewService(client, "me", WithURLRefresh(true))
+
+	m := &igshelf.Media{ID: "17850307850323541", Location: cdn.URL + "/stale"}
+	content, _, err := s.Download(context.Background(), m)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got, want := string(content), "the content"; got != want {
+		t.Errorf("got content %q, want %q", got, want)
+	}
+	if got, want := m.Location, cdn.URL+"/fresh"; got != want {
+		t.Errorf("got refreshed location %q, want %q", got, want)
+	}
+	if cdnRequests != 2 {
+		t.Errorf("got %d CDN requests, want 2 (stale then fresh)", cdnRequests)
+	}
+}
+
+// TestDownloadEmptyContent checks that a CDN 200 response with an empty
+// body is treated as an error, rather than silently written to disk as a
+// 0-byte file that would pass the skip-existing check forever.
+func TestDownloadEmptyContent(t *testing.T) {
+	cdn := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer cdn.Close()
+
+	client := NewClient("IGQVJ...", WithBaseURL(cdn.URL))
+	s := NewService(client, "me")
+
+	m := &igshelf.Media{ID: "17850307850323541", Location: cdn.URL}
+	if _, _, err := s.Download(context.Background(), m); err == nil {
+		t.Error("got no error for an empty response body, want one")
+	}
+}
+
+// TestDownloadEmptyContentAllowed checks that WithAllowEmptyMedia disables
+// the zero-byte content check.
+func TestDownloadEmptyContentAllowed(t *testing.T) {
+	cdn := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer cdn.Close()
+
+	client := NewClient("IGQVJ...", WithBaseURL(cdn.URL))
+	s := NewService(client, "me", WithAllowEmptyMedia(true))
+
+	m := &igshelf.Media{ID: "17850307850323541", Location: cdn.URL}
+	content, _, err := s.Download(context.Background(), m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(content) != 0 {
+		t.Errorf("got content %q, want empty", content)
+	}
+}
+
+// TestDownloadThumbnailsDisabled checks that WithThumbnails(false) makes
+// Download skip the thumbnail request entirely, instead of just discarding
+// the result.
+func TestDownloadThumbnailsDisabled(t *testing.T) {
+	var thumbnailRequested bool
+	cdn := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, "/thumbnail") {
+			thumbnailRequested = true
+			fmt.Fprint(w, "cover")
+			return
+		}
+		fmt.Fprint(w, "the content")
+	}))
+	defer cdn.Close()
+
+	client := NewClient("IGQVJ...", WithBaseURL(cdn.URL))
+	s := NewService(client, "me", WithThumbnails(false))
+
+	m := &igshelf.Media{
+		ID:                "17850885734317674",
+		Location:          cdn.URL,
+		ThumbnailLocation: cdn.URL + "/thumbnail",
+	}
+	content, thumbnail, err := s.Download(context.Background(), m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := string(content), "the content"; got != want {
+		t.Errorf("got content %q, want %q", got, want)
+	}
+	if thumbnail != nil {
+		t.Errorf("got thumbnail %q, want nil", thumbnail)
+	}
+	if thumbnailRequested {
+		t.Error("expected no thumbnail request when WithThumbnails(false)")
+	}
+}
+
+// TestDownloadConditionalRequestNotModified checks that WithConditionalRequests
+// sends the stored ETag as If-None-Match, and that a 304 response yields a
+// nil content and nil error instead of an empty file.
+func TestDownloadConditionalRequestNotModified(t *testing.T) {
+	var ifNoneMatch string
+	cdn := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ifNoneMatch = r.Header.Get("If-None-Match")
+		if ifNoneMatch == `"abc123"` {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", `"abc123"`)
+		fmt.Fprint(w, "the content")
+	}))
+	defer cdn.Close()
+
+	client := NewClient("IGQVJ...", WithBaseURL(cdn.URL))
+	s := NewService(client, "me", WithConditionalRequests(true))
+
+	m := &igshelf.Media{ID: "17850307850323541", Location: cdn.URL}
+	content, _, err := s.Download(context.Background(), m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := string(content), "the content"; got != want {
+		t.Errorf("got content %q, want %q", got, want)
+	}
+
+	content, thumbnail, err := s.Download(context.Background(), m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if content != nil || thumbnail != nil {
+		t.Errorf("got content %q, thumbnail %q, want both nil on a 304", content, thumbnail)
+	}
+	if got, want := ifNoneMatch, `"abc123"`; got != want {
+		t.Errorf("got If-None-Match %q, want %q", got, want)
+	}
+}
+
+// TestMediaListCancel checks that Next stops as soon as the caller's
+// context is canceled, instead of issuing another API request.
+func TestMediaListCancel(t *testing.T) {
+	var served int
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		served++
+		fmt.Fprintf(w, `{"data": [{"id": "%d"}], "paging": {"cursors": {"after": "cursor%d"}}}`, served, served)
+	}))
+	defer srv.Close()
+
+	client := NewClient("IGQVJ...", WithBaseURL(srv.URL))
+	s := NewService(client, "me")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	iter := s.List(ctx, time.Time{})
+
+	if !iter.Next() {
+		t.Fatalf("expected the first page to be fetched, got err: %v", iter.Err())
+	}
+	cancel()
+
+	if iter.Next() {
+		t.Fatal("expected iteration to stop after the context was canceled")
+	}
+	if iter.Err() != context.Canceled {
+		t.Errorf("got err %v, want %v", iter.Err(), context.Canceled)
+	}
+	if served != 1 {
+		t.Errorf("got %d requests, want 1", served)
+	}
+}
+
+func TestMediaServiceDownloadResumable(t *testing.T) {
+	const full = "0123456789"
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rng := r.Header.Get("Range")
+		if rng == "" {
+			w.Write([]byte(full))
+			return
+		}
+
+		var start int
+		if _, err := fmt.Sscanf(rng, "bytes=%d-", &start); err != nil {
+			t.Fatalf("failed to parse Range header %q: %v", rng, err)
+		}
+		w.WriteHeader(http.StatusPartialContent)
+		w.Write([]byte(full[start:]))
+	}))
+	defer srv.Close()
+
+	dir, err := ioutil.TempDir("", "igshelf")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "video.mp4")
+
+	if err := ioutil.WriteFile(path, []byte(full[:4]), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	client := NewClient("IGQVJ...")
+	s := NewService(client, "me")
+	m := &igshelf.Media{ID: "1", Location: srv.URL}
+
+	if err := s.DownloadResumable(context.Background(), m, path); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(full, string(got)); diff != "" {
+		t.Errorf(diff)
+	}
+}
+
+func TestMediaServiceDownloadResumableFallsBackToFull(t *testing.T) {
+	const full = "0123456789"
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// The CDN doesn't support ranges: it ignores the Range header
+		// and always returns the whole file with a 200.
+		w.Write([]byte(full))
+	}))
+	defer srv.Close()
+
+	dir, err := ioutil.TempDir("", "igshelf")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "video.mp4")
+
+	if err := ioutil.WriteFile(path, []byte("stale partial content"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	client := NewClient("IGQVJ...")
+	s := NewService(client, "me")
+	m := &igshelf.Media{ID: "1", Location: srv.URL}
+
+	if err := s.DownloadResumable(context.Background(), m, path); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(full, string(got)); diff != "" {
+		t.Errorf(diff)
+	}
 }
@@ -0,0 +1,36 @@
+package instagram
+
+import (
+	"context"
+	"log/slog"
+)
+
+// WithSlog configures the media service to log through a standard library
+// log/slog.Logger instead of implementing Logger directly.
+func WithSlog(l *slog.Logger) ServiceOption {
+	return func(s *MediaService) {
+		s.logger = slogLogger{l}
+	}
+}
+
+// slogLogger adapts a *slog.Logger to Logger, translating go-kit style
+// "msg", <text>, key, value, ... pairs into a single slog record.
+type slogLogger struct {
+	l *slog.Logger
+}
+
+func (a slogLogger) Log(keyvals ...interface{}) error {
+	msg := ""
+	attrs := make([]interface{}, 0, len(keyvals))
+	for i := 0; i+1 < len(keyvals); i += 2 {
+		key, _ := keyvals[i].(string)
+		val := keyvals[i+1]
+		if key == "msg" {
+			msg, _ = val.(string)
+			continue
+		}
+		attrs = append(attrs, key, val)
+	}
+	a.l.Log(context.Background(), slog.LevelWarn, msg, attrs...)
+	return nil
+}
@@ -2,46 +2,140 @@
 package jsonfile
 
 import (
+	"bytes"
+	"compress/gzip"
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
+	"os"
+	"strings"
 
 	"github.com/marselester/igshelf"
 )
 
+// defaultFileMode is the permission bits the timeline file is written with
+// unless a different one is set with WithFileMode.
+const defaultFileMode = 0600
+
+// currentVersion is the envelope version Store writes. Bump it whenever the
+// Media model changes in a way List needs to migrate from.
+const currentVersion = 2
+
+// envelope is the versioned form of timeline.json, i.e. {"version": 2,
+// "media": [...]}. List also accepts a legacy bare array (no envelope),
+// treating it as version 1.
+type envelope struct {
+	Version int              `json:"version"`
+	Media   []*igshelf.Media `json:"media"`
+}
+
 // MediaRepository stores Instagram timeline in a JSON file.
 type MediaRepository struct {
 	filename string
+	fileMode os.FileMode
+
+	// gzip makes Store gzip the file it writes and List gunzip the file it
+	// reads, see WithGzip. It defaults to on when filename ends in ".gz".
+	gzip bool
+}
+
+// Option configures the MediaRepository.
+type Option func(*MediaRepository)
+
+// WithFileMode sets the permission bits the timeline file is written with.
+func WithFileMode(mode os.FileMode) Option {
+	return func(r *MediaRepository) {
+		r.fileMode = mode
+	}
+}
+
+// WithGzip makes Store gzip the timeline it writes and List transparently
+// gunzip it back, which shrinks a text-heavy timeline.json considerably for
+// large accounts. It overrides the default of enabling gzip whenever
+// filename already ends in ".gz".
+func WithGzip(enabled bool) Option {
+	return func(r *MediaRepository) {
+		r.gzip = enabled
+	}
 }
 
 // NewMediaRepository creates new MediaRepository.
-func NewMediaRepository(filename string) *MediaRepository {
-	return &MediaRepository{filename: filename}
+func NewMediaRepository(filename string, options ...Option) *MediaRepository {
+	r := MediaRepository{
+		filename: filename,
+		fileMode: defaultFileMode,
+		gzip:     strings.HasSuffix(filename, ".gz"),
+	}
+	for _, opt := range options {
+		opt(&r)
+	}
+	return &r
 }
 
-// List returns all the media description as it was stored.
+// List returns all the media description as it was stored. It reads both
+// a legacy bare array and the versioned envelope Store writes, so a
+// timeline.json from an older release still loads.
 func (r *MediaRepository) List() ([]*igshelf.Media, error) {
 	b, err := ioutil.ReadFile(r.filename)
 	if err != nil {
 		return nil, fmt.Errorf("failed to read timeline from disk %s: %w", r.filename, err)
 	}
 
-	var timeline []*igshelf.Media
-	if err = json.Unmarshal(b, &timeline); err != nil {
+	if r.gzip {
+		gr, err := gzip.NewReader(bytes.NewReader(b))
+		if err != nil {
+			return nil, fmt.Errorf("failed to gunzip timeline %s: %w", r.filename, err)
+		}
+		defer gr.Close()
+		if b, err = ioutil.ReadAll(gr); err != nil {
+			return nil, fmt.Errorf("failed to gunzip timeline %s: %w", r.filename, err)
+		}
+	}
+
+	if bytes.HasPrefix(bytes.TrimSpace(b), []byte("[")) {
+		var timeline []*igshelf.Media
+		if err = json.Unmarshal(b, &timeline); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal timeline %s: %w", r.filename, err)
+		}
+		return timeline, nil
+	}
+
+	var e envelope
+	if err = json.Unmarshal(b, &e); err != nil {
 		return nil, fmt.Errorf("failed to unmarshal timeline %s: %w", r.filename, err)
 	}
-	return timeline, nil
+	return e.Media, nil
 }
 
-// Store persists the media timeline on disk.
-// The file is always overwritten.
+// Store persists the media timeline on disk, wrapped in the current
+// envelope version. The file is always overwritten. Output is
+// byte-identical for an unchanged timeline: encoding/json marshals struct
+// fields in declaration order, so this holds as long as igshelf.Media
+// stays map-free; a map field added later would need explicit key sorting
+// to keep a git-tracked timeline.json diff-quiet across runs.
 func (r *MediaRepository) Store(timeline []*igshelf.Media) error {
-	b, err := json.Marshal(&timeline)
+	e := envelope{
+		Version: currentVersion,
+		Media:   timeline,
+	}
+	b, err := json.Marshal(&e)
 	if err != nil {
 		return fmt.Errorf("failed to marshal timeline %s: %w", r.filename, err)
 	}
 
-	if err = ioutil.WriteFile(r.filename, b, 0600); err != nil {
+	if r.gzip {
+		var buf bytes.Buffer
+		gw := gzip.NewWriter(&buf)
+		if _, err = gw.Write(b); err != nil {
+			return fmt.Errorf("failed to gzip timeline %s: %w", r.filename, err)
+		}
+		if err = gw.Close(); err != nil {
+			return fmt.Errorf("failed to gzip timeline %s: %w", r.filename, err)
+		}
+		b = buf.Bytes()
+	}
+
+	if err = ioutil.WriteFile(r.filename, b, r.fileMode); err != nil {
 		return fmt.Errorf("failed to write timeline on disk %s: %w", r.filename, err)
 	}
 
@@ -0,0 +1,169 @@
+package jsonfile
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/marselester/igshelf"
+)
+
+func TestStoreFileMode(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "timeline.json")
+	r := NewMediaRepository(filename, WithFileMode(0644))
+
+	if err := r.Store([]*igshelf.Media{{ID: "1"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	fi, err := os.Stat(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := fi.Mode().Perm(), os.FileMode(0644); got != want {
+		t.Errorf("got mode %v, want %v", got, want)
+	}
+}
+
+func TestListLegacyArray(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "timeline.json")
+	if err := ioutil.WriteFile(filename, []byte(`[{"id": "1"}, {"id": "2"}]`), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	r := NewMediaRepository(filename)
+	timeline, err := r.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []*igshelf.Media{{ID: "1"}, {ID: "2"}}
+	if diff := cmp.Diff(want, timeline); diff != "" {
+		t.Errorf("List() mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestListVersionedEnvelope(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "timeline.json")
+	if err := ioutil.WriteFile(filename, []byte(`{"version": 2, "media": [{"id": "1"}, {"id": "2"}]}`), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	r := NewMediaRepository(filename)
+	timeline, err := r.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []*igshelf.Media{{ID: "1"}, {ID: "2"}}
+	if diff := cmp.Diff(want, timeline); diff != "" {
+		t.Errorf("List() mismatch (-want +got):\n%s", diff)
+	}
+}
+
+// TestGzipRoundtrip checks that a timeline stored with WithGzip can be
+// read back via List, and that the file on disk is actually gzip-compressed.
+func TestGzipRoundtrip(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "timeline.json.gz")
+	r := NewMediaRepository(filename, WithGzip(true))
+
+	want := []*igshelf.Media{{ID: "1"}, {ID: "2"}}
+	if err := r.Store(want); err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := ioutil.ReadFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(b) < 2 || b[0] != 0x1f || b[1] != 0x8b {
+		t.Fatalf("expected file to start with the gzip magic number, got %x", b[:2])
+	}
+
+	timeline, err := r.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(want, timeline); diff != "" {
+		t.Errorf("List() mismatch (-want +got):\n%s", diff)
+	}
+}
+
+// TestGzipInferredFromExtension checks that a ".gz" filename enables gzip
+// without WithGzip being passed explicitly.
+func TestGzipInferredFromExtension(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "timeline.json.gz")
+	r := NewMediaRepository(filename)
+
+	want := []*igshelf.Media{{ID: "1"}}
+	if err := r.Store(want); err != nil {
+		t.Fatal(err)
+	}
+
+	timeline, err := r.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(want, timeline); diff != "" {
+		t.Errorf("List() mismatch (-want +got):\n%s", diff)
+	}
+}
+
+// TestStoreDeterministicOutput checks that storing the same timeline twice
+// produces byte-identical files, so a git-tracked gallery doesn't churn its
+// timeline.json across runs that didn't actually change anything.
+func TestStoreDeterministicOutput(t *testing.T) {
+	timeline := []*igshelf.Media{
+		{
+			ID:       "2",
+			Filename: "2.jpg",
+			Children: []*igshelf.Media{
+				{ID: "2b", Filename: "2b.jpg"},
+				{ID: "2a", Filename: "2a.jpg"},
+			},
+		},
+		{ID: "1", Filename: "1.jpg"},
+	}
+
+	filename1 := filepath.Join(t.TempDir(), "timeline.json")
+	if err := NewMediaRepository(filename1).Store(timeline); err != nil {
+		t.Fatal(err)
+	}
+	b1, err := ioutil.ReadFile(filename1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	filename2 := filepath.Join(t.TempDir(), "timeline.json")
+	if err := NewMediaRepository(filename2).Store(timeline); err != nil {
+		t.Fatal(err)
+	}
+	b2, err := ioutil.ReadFile(filename2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(b1) != string(b2) {
+		t.Errorf("expected two stores of the same timeline to be byte-identical, got:\n%s\nand:\n%s", b1, b2)
+	}
+}
+
+func TestStoreWritesVersionedEnvelope(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "timeline.json")
+	r := NewMediaRepository(filename)
+
+	if err := r.Store([]*igshelf.Media{{ID: "1"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := ioutil.ReadFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := string(b), `{"version":2,"media":[{"ID":"1"`; !cmp.Equal(got[:len(want)], want) {
+		t.Errorf("got %q, want prefix %q", got, want)
+	}
+}
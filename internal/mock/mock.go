@@ -3,22 +3,30 @@ package mock
 
 import (
 	"context"
+	"sync"
+	"time"
 
 	"github.com/marselester/igshelf"
 )
 
 // MediaService is a mock that implements igshelf.MediaService interface.
 type MediaService struct {
-	ListFn     func() (iter igshelf.MediaIter)
+	ListFn     func(since time.Time) (iter igshelf.MediaIter)
 	DownloadFn func(m *igshelf.Media) (content, thumbnail []byte, err error)
+	GetFn      func(id string) (*igshelf.Media, error)
+
+	// DownloadResumableFn, if set, makes MediaService additionally satisfy
+	// downloader's resumableDownloader interface, e.g. to test
+	// ExistingFileResume.
+	DownloadResumableFn func(m *igshelf.Media, path string) error
 }
 
 // List calls ListFn to inspect the mock.
-func (s *MediaService) List(ctx context.Context) (iter igshelf.MediaIter) {
+func (s *MediaService) List(ctx context.Context, since time.Time) (iter igshelf.MediaIter) {
 	if s.ListFn == nil {
 		return nil
 	}
-	return s.ListFn()
+	return s.ListFn(since)
 }
 
 // Download calls DownloadFn to inspect the mock.
@@ -29,11 +37,31 @@ func (s *MediaService) Download(ctx context.Context, m *igshelf.Media) (content,
 	return s.DownloadFn(m)
 }
 
+// Get calls GetFn to inspect the mock.
+func (s *MediaService) Get(ctx context.Context, id string) (*igshelf.Media, error) {
+	if s.GetFn == nil {
+		return nil, nil
+	}
+	return s.GetFn(id)
+}
+
+// DownloadResumable calls DownloadResumableFn to inspect the mock.
+func (s *MediaService) DownloadResumable(ctx context.Context, m *igshelf.Media, path string) error {
+	if s.DownloadResumableFn == nil {
+		return nil
+	}
+	return s.DownloadResumableFn(m, path)
+}
+
 // MediaIter is a mock that implements igshelf.MediaIter interface.
+// Its Batch-backed state is guarded by a mutex, since a caller may drive
+// Next and Media from different goroutines (e.g. downloader's prefetch mode).
 type MediaIter struct {
 	// Batch of media the iterator will work with by default.
 	Batch []*igshelf.Media
 	err   error
+
+	mu sync.Mutex
 	// cursor is a current cursor position in Batch.
 	cursor int
 	// current is a current media returned by this iterator.
@@ -51,7 +79,10 @@ func (it *MediaIter) Next() bool {
 		return it.NextFn()
 	}
 
-	if it.Media() != nil {
+	it.mu.Lock()
+	defer it.mu.Unlock()
+
+	if it.current != nil {
 		it.cursor++
 	}
 
@@ -63,13 +94,16 @@ func (it *MediaIter) Next() bool {
 	return true
 }
 
-// Media calls MediaFn to inspect the mock if the func was configured.
-// Otherwise it provides an iterator over the Batch implementation.
+// Media calls MediaFn to inspect the mock if the func was configured,
+// returning its result. Otherwise it provides an iterator over the Batch
+// implementation.
 func (it *MediaIter) Media() *igshelf.Media {
 	if it.MediaFn != nil {
-		it.MediaFn()
+		return it.MediaFn()
 	}
 
+	it.mu.Lock()
+	defer it.mu.Unlock()
 	return it.current
 }
 
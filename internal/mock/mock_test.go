@@ -0,0 +1,48 @@
+package mock
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/marselester/igshelf"
+)
+
+// TestMediaIterMediaFnResult checks that Media returns MediaFn's result
+// when it's configured, instead of discarding it.
+func TestMediaIterMediaFnResult(t *testing.T) {
+	want := &igshelf.Media{ID: "custom"}
+	it := MediaIter{MediaFn: func() *igshelf.Media { return want }}
+
+	if got := it.Media(); got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+// TestMediaIterConcurrentAccess drives Next and Media from different
+// goroutines under -race, to catch a regression in MediaIter's
+// synchronization of cursor/current.
+func TestMediaIterConcurrentAccess(t *testing.T) {
+	it := MediaIter{Batch: []*igshelf.Media{{ID: "1"}, {ID: "2"}, {ID: "3"}}}
+
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-done:
+					return
+				default:
+					it.Media()
+				}
+			}
+		}()
+	}
+
+	for it.Next() {
+	}
+	close(done)
+	wg.Wait()
+}
@@ -0,0 +1,94 @@
+// Package ndjson provides a newline-delimited JSON repository implementation
+// to store Instagram timeline, one Media per line.
+package ndjson
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/marselester/igshelf"
+)
+
+// defaultFileMode is the permission bits the timeline file is written with
+// unless a different one is set with WithFileMode.
+const defaultFileMode = 0600
+
+// MediaRepository stores Instagram timeline as newline-delimited JSON,
+// which scales better than jsonfile.MediaRepository's single JSON array
+// when the timeline is meant to be appended to or streamed.
+type MediaRepository struct {
+	filename string
+	fileMode os.FileMode
+}
+
+// Option configures the MediaRepository.
+type Option func(*MediaRepository)
+
+// WithFileMode sets the permission bits the timeline file is written with.
+func WithFileMode(mode os.FileMode) Option {
+	return func(r *MediaRepository) {
+		r.fileMode = mode
+	}
+}
+
+// NewMediaRepository creates new MediaRepository.
+func NewMediaRepository(filename string, options ...Option) *MediaRepository {
+	r := MediaRepository{
+		filename: filename,
+		fileMode: defaultFileMode,
+	}
+	for _, opt := range options {
+		opt(&r)
+	}
+	return &r
+}
+
+// List reads the media timeline, one Media per line.
+func (r *MediaRepository) List() ([]*igshelf.Media, error) {
+	f, err := os.Open(r.filename)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read timeline from disk %s: %w", r.filename, err)
+	}
+	defer f.Close()
+
+	var timeline []*igshelf.Media
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		var m igshelf.Media
+		if err = json.Unmarshal(sc.Bytes(), &m); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal timeline %s: %w", r.filename, err)
+		}
+		timeline = append(timeline, &m)
+	}
+	if err = sc.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read timeline from disk %s: %w", r.filename, err)
+	}
+	return timeline, nil
+}
+
+// Store persists the media timeline on disk, one Media per line.
+// The file is always overwritten.
+func (r *MediaRepository) Store(timeline []*igshelf.Media) error {
+	f, err := os.OpenFile(r.filename, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, r.fileMode)
+	if err != nil {
+		return fmt.Errorf("failed to write timeline on disk %s: %w", r.filename, err)
+	}
+	defer f.Close()
+
+	w := bufio.NewWriter(f)
+	for _, m := range timeline {
+		b, err := json.Marshal(m)
+		if err != nil {
+			return fmt.Errorf("failed to marshal timeline %s: %w", r.filename, err)
+		}
+		if _, err = w.Write(b); err != nil {
+			return fmt.Errorf("failed to write timeline on disk %s: %w", r.filename, err)
+		}
+		if err = w.WriteByte('\n'); err != nil {
+			return fmt.Errorf("failed to write timeline on disk %s: %w", r.filename, err)
+		}
+	}
+	return w.Flush()
+}
@@ -0,0 +1,105 @@
+package ndjson
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/marselester/igshelf"
+)
+
+func TestMediaRepositoryStoreList(t *testing.T) {
+	want := []*igshelf.Media{
+		{ID: "1", Type: "IMAGE", Caption: "Sunset walk", Filename: "1.jpg"},
+		{ID: "2", Type: "VIDEO", Caption: "Still jumping", Filename: "2.mp4"},
+	}
+
+	filename := filepath.Join(t.TempDir(), "timeline.ndjson")
+	r := NewMediaRepository(filename)
+
+	if err := r.Store(want); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := r.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf(diff)
+	}
+}
+
+func TestMediaRepositoryStoreLinesIndependentlyParseable(t *testing.T) {
+	timeline := []*igshelf.Media{
+		{ID: "1", Type: "IMAGE", Filename: "1.jpg"},
+		{ID: "2", Type: "VIDEO", Filename: "2.mp4"},
+	}
+
+	filename := filepath.Join(t.TempDir(), "timeline.ndjson")
+	r := NewMediaRepository(filename)
+	if err := r.Store(timeline); err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := ioutil.ReadFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	lines := splitLines(b)
+	if len(lines) != len(timeline) {
+		t.Fatalf("got %d lines, want %d", len(lines), len(timeline))
+	}
+	for i, line := range lines {
+		var m igshelf.Media
+		if err := json.Unmarshal(line, &m); err != nil {
+			t.Errorf("line %d isn't independently parseable: %s", i, err)
+		}
+		if diff := cmp.Diff(timeline[i], &m); diff != "" {
+			t.Errorf(diff)
+		}
+	}
+}
+
+func splitLines(b []byte) [][]byte {
+	var lines [][]byte
+	start := 0
+	for i, c := range b {
+		if c == '\n' {
+			lines = append(lines, b[start:i])
+			start = i + 1
+		}
+	}
+	return lines
+}
+
+func TestMediaRepositoryListMissingFile(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "missing.ndjson")
+	r := NewMediaRepository(filename)
+
+	if _, err := r.List(); err == nil {
+		t.Error("expected an error for a missing file")
+	}
+}
+
+func TestMediaRepositoryWithFileMode(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "timeline.ndjson")
+	r := NewMediaRepository(filename, WithFileMode(0644))
+
+	if err := r.Store([]*igshelf.Media{{ID: "1"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	fi, err := os.Stat(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := fi.Mode(), os.FileMode(0644); got != want {
+		t.Errorf("got file mode %v, want %v", got, want)
+	}
+}